@@ -0,0 +1,296 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ruhan/internal/export"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+const (
+	// accountDeletionGracePeriod is how long a deleted account can still be
+	// restored.
+	accountDeletionGracePeriod = 30 * 24 * time.Hour
+	// archiveLifetime is how long a built export archive can be downloaded.
+	archiveLifetime = 7 * 24 * time.Hour
+	// staleArchiveAfter fails archives whose build a restart interrupted.
+	staleArchiveAfter = time.Hour
+)
+
+// AccountHandler serves what users can do with their account as a whole:
+// download everything stored about them and delete it.
+type AccountHandler struct {
+	userStore    store.UserStore
+	workoutStore store.WorkoutStore
+	tokenStore   store.TokenStore
+	auditStore   store.AuditStore
+	archiveStore store.ArchiveStore
+	logger       *log.Logger
+}
+
+func NewAccountHandler(userStore store.UserStore, workoutStore store.WorkoutStore, tokenStore store.TokenStore, auditStore store.AuditStore, archiveStore store.ArchiveStore, logger *log.Logger) *AccountHandler {
+	return &AccountHandler{
+		userStore,
+		workoutStore,
+		tokenStore,
+		auditStore,
+		archiveStore,
+		logger,
+	}
+}
+
+// clientIP returns the address the request came from, without the port.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// recordEvent adds an audit event for the user. A failure is logged rather
+// than failing the request it describes.
+func recordEvent(auditStore store.AuditStore, logger *log.Logger, req *http.Request, userID int, event string) {
+	err := auditStore.RecordEvent(&store.AuditEvent{UserID: userID, Event: event, IPAddress: clientIP(req)})
+	if err != nil {
+		logger.Printf("ERROR: RecordEvent %s: %v", event, err)
+	}
+}
+
+// HandleRequestExportArchive starts building a ZIP of everything stored
+// about the user. The archive is built in the background; poll it with
+// GET /users/me/export-archive/{id} until it is ready to download.
+func (h *AccountHandler) HandleRequestExportArchive(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	archive := &store.ExportArchive{UserID: currentUser.ID, ExpiresAt: time.Now().Add(archiveLifetime)}
+	err := h.archiveStore.CreateArchive(archive)
+	if errors.Is(err, store.ErrArchivePending) {
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: CreateArchive: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditExportArchiveRequested)
+
+	go h.buildArchive(archive.ID, currentUser)
+
+	utils.WriteJSON(res, http.StatusAccepted, utils.Envelope{"archive": archive})
+}
+
+// buildArchive builds and stores the archive, marking it failed on any error.
+func (h *AccountHandler) buildArchive(archiveID int, user *store.User) {
+	err := func() (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = fmt.Errorf("panic: %v", recovered)
+			}
+		}()
+
+		tokens, err := h.tokenStore.ListTokens(user.ID)
+		if err != nil {
+			return err
+		}
+		events, err := h.auditStore.ListEvents(user.ID)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		err = export.WriteArchive(&buf, export.Meta{
+			UserName:   user.UserName,
+			ExportedAt: time.Now(),
+			Location:   userLocation(user),
+		}, export.Archive{
+			User:        user,
+			Tokens:      tokens,
+			AuditEvents: events,
+			Workouts: func(fn func(*store.Workout) error) error {
+				return h.workoutStore.ExportWorkouts(user.ID, fn)
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return h.archiveStore.CompleteArchive(archiveID, buf.Bytes())
+	}()
+	if err == nil {
+		return
+	}
+
+	h.logger.Printf("ERROR: building export archive %d: %v", archiveID, err)
+	err = h.archiveStore.FailArchive(archiveID)
+	if err != nil {
+		h.logger.Printf("ERROR: FailArchive: %v", err)
+	}
+}
+
+// loadArchive returns the requested archive if it belongs to the current
+// user, or writes the error response and returns nil.
+func (h *AccountHandler) loadArchive(res http.ResponseWriter, req *http.Request) *store.ExportArchive {
+	archiveID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid archive id"})
+		return nil
+	}
+
+	archive, err := h.archiveStore.GetArchive(archiveID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetArchive: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	// someone else's archive is as good as missing
+	if archive == nil || archive.UserID != middleware.GetUser(req).ID {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "archive not found"})
+		return nil
+	}
+	return archive
+}
+
+func (h *AccountHandler) HandleGetExportArchive(res http.ResponseWriter, req *http.Request) {
+	archive := h.loadArchive(res, req)
+	if archive == nil {
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"archive": archive})
+}
+
+func (h *AccountHandler) HandleDownloadExportArchive(res http.ResponseWriter, req *http.Request) {
+	archive := h.loadArchive(res, req)
+	if archive == nil {
+		return
+	}
+
+	if archive.Status != store.ArchiveStatusReady {
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": "archive is " + archive.Status})
+		return
+	}
+	if !archive.ExpiresAt.After(time.Now()) {
+		utils.WriteJSON(res, http.StatusGone, utils.Envelope{"error": "archive has expired"})
+		return
+	}
+
+	data, err := h.archiveStore.GetArchiveData(int64(archive.ID))
+	if err != nil {
+		h.logger.Printf("ERROR: GetArchiveData: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	filename := fmt.Sprintf("account-archive-%s.zip", archive.CreatedAt.Format(time.DateOnly))
+	res.Header().Set("Content-Type", "application/zip")
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	res.Write(data)
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// HandleDeleteAccount schedules the account for deletion once the grace
+// period is over. The password has to be confirmed; until the deletion runs
+// the user can still log in and cancel it.
+func (h *AccountHandler) HandleDeleteAccount(res http.ResponseWriter, req *http.Request) {
+	var body deleteAccountRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding delete account request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	passwordDoMatch, err := currentUser.PasswordHash.Matches(body.Password)
+	if err != nil {
+		h.logger.Printf("ERROR: Password hash match %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !passwordDoMatch {
+		utils.WriteJSON(res, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		return
+	}
+
+	err = h.userStore.ScheduleDeletion(currentUser, time.Now().Add(accountDeletionGracePeriod))
+	if errors.Is(err, store.ErrDeletionScheduled) {
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: ScheduleDeletion: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditDeletionRequested)
+
+	utils.WriteJSON(res, http.StatusAccepted, utils.Envelope{"deletion_scheduled_for": currentUser.DeletionScheduledFor})
+}
+
+func (h *AccountHandler) HandleCancelDeletion(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	err := h.userStore.CancelDeletion(currentUser.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "no account deletion is scheduled"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: CancelDeletion: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditDeletionCancelled)
+
+	currentUser.DeletionScheduledFor = nil
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"user": currentUser})
+}
+
+// RunMaintenance deletes the accounts whose grace period is over and
+// cleans up expired archives and tokens. It is meant to run periodically.
+func (h *AccountHandler) RunMaintenance() {
+	userIDs, err := h.userStore.ListDueDeletions()
+	if err != nil {
+		h.logger.Printf("ERROR: ListDueDeletions: %v", err)
+	}
+	for _, userID := range userIDs {
+		anonymized, err := h.userStore.PurgeUser(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			h.logger.Printf("ERROR: PurgeUser %d: %v", userID, err)
+			continue
+		}
+		if anonymized {
+			h.logger.Printf("anonymized account %d", userID)
+		} else {
+			h.logger.Printf("deleted account %d", userID)
+		}
+	}
+
+	_, err = h.archiveStore.CleanUpArchives(staleArchiveAfter)
+	if err != nil {
+		h.logger.Printf("ERROR: CleanUpArchives: %v", err)
+	}
+
+	_, err = h.tokenStore.CleanUpTokens()
+	if err != nil {
+		h.logger.Printf("ERROR: CleanUpTokens: %v", err)
+	}
+}
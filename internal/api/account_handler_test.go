@@ -0,0 +1,49 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubArchiveStore struct {
+	store.ArchiveStore
+	archives map[int64]*store.ExportArchive
+}
+
+func (s *stubArchiveStore) GetArchive(id int64) (*store.ExportArchive, error) {
+	return s.archives[id], nil
+}
+
+func TestHandleGetExportArchive(t *testing.T) {
+	owner := &store.User{ID: 1, UserName: "owner"}
+	other := &store.User{ID: 2, UserName: "other"}
+
+	archives := &stubArchiveStore{archives: map[int64]*store.ExportArchive{
+		3: {ID: 3, UserID: owner.ID, Status: store.ArchiveStatusReady},
+	}}
+	handler := NewAccountHandler(nil, nil, nil, nil, archives, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name   string
+		user   *store.User
+		path   string
+		status int
+	}{
+		{name: "owner", user: owner, path: "/users/me/export-archive/3", status: http.StatusOK},
+		{name: "another user", user: other, path: "/users/me/export-archive/3", status: http.StatusNotFound},
+		{name: "missing archive", user: owner, path: "/users/me/export-archive/4", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveAs(tt.user, "/users/me/export-archive/{id}", handler.HandleGetExportArchive, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.status, res.Code)
+		})
+	}
+}
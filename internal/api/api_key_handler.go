@@ -0,0 +1,148 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/tokens"
+	"github.com/ruhan/internal/utils"
+)
+
+const (
+	defaultAPIKeyDays = 90
+	maxAPIKeyDays     = 365
+)
+
+type APIKeyHandler struct {
+	tokenStore store.TokenStore
+	auditStore store.AuditStore
+	logger     *log.Logger
+}
+
+func NewAPIKeyHandler(tokenStore store.TokenStore, auditStore store.AuditStore, logger *log.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		tokenStore,
+		auditStore,
+		logger,
+	}
+}
+
+type createAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Permissions   []string `json:"permissions"`
+	ExpiresInDays *int     `json:"expires_in_days"`
+}
+
+// validate checks the request and drops duplicate permissions.
+func (r *createAPIKeyRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Name) > 100 {
+		return errors.New("name cannot be longer than 100 characters")
+	}
+
+	if len(r.Permissions) == 0 {
+		return errors.New("permissions must name at least one of " + strings.Join(tokens.Permissions, ", "))
+	}
+	permissions := []string{}
+	for _, permission := range r.Permissions {
+		if !slices.Contains(tokens.Permissions, permission) {
+			return errors.New("unknown permission " + permission + "; use " + strings.Join(tokens.Permissions, ", "))
+		}
+		if !slices.Contains(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	r.Permissions = permissions
+
+	if r.ExpiresInDays != nil && (*r.ExpiresInDays < 1 || *r.ExpiresInDays > maxAPIKeyDays) {
+		return errors.New("expires_in_days must be between 1 and 365")
+	}
+	return nil
+}
+
+// HandleCreateAPIKey creates an API key. Its plaintext is in this response
+// only; afterwards just its name and metadata can be seen.
+func (h *APIKeyHandler) HandleCreateAPIKey(res http.ResponseWriter, req *http.Request) {
+	var body createAPIKeyRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create API key request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	err = body.validate()
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	days := defaultAPIKeyDays
+	if body.ExpiresInDays != nil {
+		days = *body.ExpiresInDays
+	}
+
+	currentUser := middleware.GetUser(req)
+	token, err := tokens.GenerateAPIKey(currentUser.ID, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		h.logger.Printf("ERROR: GenerateAPIKey %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	key := &store.APIKey{Name: body.Name, Permissions: body.Permissions}
+	err = h.tokenStore.CreateAPIKey(key, token)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateAPIKey %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditAPIKeyCreated)
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"api_key": key, "token": token.PlainText})
+}
+
+func (h *APIKeyHandler) HandleListAPIKeys(res http.ResponseWriter, req *http.Request) {
+	keys, err := h.tokenStore.ListAPIKeys(middleware.GetUser(req).ID)
+	if err != nil {
+		h.logger.Printf("ERROR: ListAPIKeys %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"api_keys": keys})
+}
+
+func (h *APIKeyHandler) HandleDeleteAPIKey(res http.ResponseWriter, req *http.Request) {
+	id, err := utils.ReadIdParam(req)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid API key id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	err = h.tokenStore.DeleteAPIKey(currentUser.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: DeleteAPIKey %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditAPIKeyRevoked)
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"success": true})
+}
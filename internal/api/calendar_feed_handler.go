@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ruhan/internal/ical"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/tokens"
+	"github.com/ruhan/internal/utils"
+)
+
+const (
+	// feed tokens live until they are regenerated or revoked
+	calendarFeedTTL = 10 * 365 * 24 * time.Hour
+
+	calendarFeedPastDays   = 365
+	calendarFeedFutureDays = 180
+)
+
+// HandleCreateCalendarFeed issues a new secret feed URL. Any previous feed
+// URL stops working.
+func (h *CalendarHandler) HandleCreateCalendarFeed(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	err := h.tokenStore.DeleteAllTokens(currentUser.ID, tokens.ScopeCalendarFeed)
+	if err != nil {
+		h.logger.Printf("ERROR: DeleteAllTokens: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	token, err := h.tokenStore.CreateNewToken(currentUser.ID, calendarFeedTTL, tokens.ScopeCalendarFeed)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateNewToken: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{
+		"calendar_feed": token,
+		"url":           fmt.Sprintf("%s://%s/calendar/%s.ics", scheme, req.Host, token.PlainText),
+	})
+}
+
+func (h *CalendarHandler) HandleRevokeCalendarFeed(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	err := h.tokenStore.DeleteAllTokens(currentUser.ID, tokens.ScopeCalendarFeed)
+	if err != nil {
+		h.logger.Printf("ERROR: DeleteAllTokens: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"success": true})
+}
+
+// HandleGetCalendarFeed serves the iCalendar feed behind a secret URL, so
+// calendar apps can subscribe without an Authorization header. Logged
+// workouts become timed events and planned occurrences that were not yet
+// completed become all-day events.
+func (h *CalendarHandler) HandleGetCalendarFeed(res http.ResponseWriter, req *http.Request) {
+	user, err := h.userStore.GetUserToken(tokens.ScopeCalendarFeed, chi.URLParam(req, "feedToken"))
+	if err != nil {
+		h.logger.Printf("ERROR: GetUserToken: %v", err)
+		http.Error(res, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.NotFound(res, req)
+		return
+	}
+
+	today := localToday(user)
+	from := today.AddDate(0, 0, -calendarFeedPastDays)
+	to := today.AddDate(0, 0, calendarFeedFutureDays)
+
+	plans, err := h.plannedStore.ListPlannedWorkouts(user.ID, from, to)
+	if err != nil {
+		h.logger.Printf("ERROR: ListPlannedWorkouts: %v", err)
+		http.Error(res, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	completions, err := h.plannedStore.ListCompletions(user.ID, from, to)
+	if err != nil {
+		h.logger.Printf("ERROR: ListCompletions: %v", err)
+		http.Error(res, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	workouts, err := h.plannedStore.ListLoggedWorkouts(user.ID, userLocation(user).String(), from, today)
+	if err != nil {
+		h.logger.Printf("ERROR: ListLoggedWorkouts: %v", err)
+		http.Error(res, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	completed := map[string]bool{}
+	for _, completion := range completions {
+		completed[strconv.Itoa(completion.PlannedWorkoutID)+"-"+completion.OccurrenceDate.Format("20060102")] = true
+	}
+
+	events := []ical.Event{}
+	for _, workout := range workouts {
+		events = append(events, ical.Event{
+			UID:     fmt.Sprintf("workout-%d@%s", workout.ID, req.Host),
+			Summary: workout.Title,
+			Start:   workout.CreatedAt,
+			End:     workout.CreatedAt.Add(time.Duration(workout.DurationMinutes) * time.Minute),
+		})
+	}
+
+	for _, planned := range plans {
+		for _, date := range plannedOccurrences(planned, from, to) {
+			occurrence := strconv.Itoa(planned.ID) + "-" + date.Format("20060102")
+			if completed[occurrence] {
+				continue
+			}
+			events = append(events, ical.Event{
+				UID:         fmt.Sprintf("planned-%s@%s", occurrence, req.Host),
+				Summary:     planned.Title,
+				Description: planned.Description,
+				Start:       date,
+				End:         date.AddDate(0, 0, 1),
+				AllDay:      true,
+			})
+		}
+	}
+
+	res.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	res.Header().Set("Cache-Control", "private, max-age=900")
+	err = ical.Write(res, user.UserName+"'s workouts", time.Now(), events)
+	if err != nil {
+		h.logger.Printf("ERROR: writing calendar feed: %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/rrule"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+type CalendarHandler struct {
+	plannedStore  store.PlannedWorkoutStore
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	tokenStore    store.TokenStore
+	userStore     store.UserStore
+	logger        *log.Logger
+}
+
+func NewCalendarHandler(plannedStore store.PlannedWorkoutStore, templateStore store.TemplateStore, workoutStore store.WorkoutStore, tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *CalendarHandler {
+	return &CalendarHandler{
+		plannedStore,
+		templateStore,
+		workoutStore,
+		tokenStore,
+		userStore,
+		logger,
+	}
+}
+
+const (
+	defaultCalendarDays = 28
+	maxCalendarRange    = 366 * 24 * time.Hour
+)
+
+func (h *CalendarHandler) HandleCreatePlannedWorkout(res http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Title         string `json:"title"`
+		Description   string `json:"description"`
+		TemplateID    *int   `json:"template_id"`
+		ScheduledDate string `json:"scheduled_date"`
+		RRule         string `json:"rrule"`
+	}
+
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create planned workout request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	planned := &store.PlannedWorkout{
+		UserID:      currentUser.ID,
+		Title:       strings.TrimSpace(body.Title),
+		Description: body.Description,
+		TemplateID:  body.TemplateID,
+	}
+
+	scheduledDate, err := parseOptionalDate(body.ScheduledDate, "scheduled_date")
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if scheduledDate == nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "scheduled_date is required"})
+		return
+	}
+	planned.ScheduledDate = *scheduledDate
+
+	if strings.TrimSpace(body.RRule) != "" {
+		rule, err := rrule.Parse(body.RRule)
+		if err != nil {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		planned.RRule = rule.String()
+	}
+
+	if planned.TemplateID != nil {
+		template, err := h.templateStore.GetTemplateByID(int64(*planned.TemplateID))
+		if err != nil {
+			h.logger.Printf("ERROR: GetTemplateByID: %v", err)
+			utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if template == nil || (template.UserID != currentUser.ID && !template.IsPublic) {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "template not found"})
+			return
+		}
+		if planned.Title == "" {
+			planned.Title = template.Title
+		}
+	}
+
+	if planned.Title == "" {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "title is required"})
+		return
+	}
+
+	err = h.plannedStore.CreatePlannedWorkout(planned)
+	if err != nil {
+		h.logger.Printf("ERROR: CreatePlannedWorkout: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"planned_workout": planned})
+}
+
+// loadOwnPlannedWorkout fetches the planned workout named by the id param if
+// the current user owns it. It writes the error response itself and returns
+// nil.
+func (h *CalendarHandler) loadOwnPlannedWorkout(res http.ResponseWriter, req *http.Request) *store.PlannedWorkout {
+	plannedID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid planned workout id"})
+		return nil
+	}
+
+	planned, err := h.plannedStore.GetPlannedWorkoutByID(plannedID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetPlannedWorkoutByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(req)
+	if planned == nil || planned.UserID != currentUser.ID {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "planned workout not found"})
+		return nil
+	}
+
+	return planned
+}
+
+func (h *CalendarHandler) HandleGetPlannedWorkoutByID(res http.ResponseWriter, req *http.Request) {
+	planned := h.loadOwnPlannedWorkout(res, req)
+	if planned == nil {
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"planned_workout": planned})
+}
+
+func (h *CalendarHandler) HandleDeletePlannedWorkout(res http.ResponseWriter, req *http.Request) {
+	planned := h.loadOwnPlannedWorkout(res, req)
+	if planned == nil {
+		return
+	}
+
+	err := h.plannedStore.DeletePlannedWorkout(int64(planned.ID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: DeletePlannedWorkout: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"success": true})
+}
+
+// plannedOccurrences expands a planned workout into its dates between from
+// and to. A rule that no longer parses yields nothing.
+func plannedOccurrences(planned *store.PlannedWorkout, from, to time.Time) []time.Time {
+	start := planned.ScheduledDate
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+
+	if planned.RRule == "" {
+		if start.Before(from) || start.After(to) {
+			return nil
+		}
+		return []time.Time{start}
+	}
+
+	rule, err := rrule.Parse(planned.RRule)
+	if err != nil {
+		return nil
+	}
+	return rule.Between(start, from, to)
+}
+
+// HandleCompletePlannedWorkout marks one occurrence of a planned workout as
+// done by linking it to a workout the user logged.
+func (h *CalendarHandler) HandleCompletePlannedWorkout(res http.ResponseWriter, req *http.Request) {
+	planned := h.loadOwnPlannedWorkout(res, req)
+	if planned == nil {
+		return
+	}
+
+	var body struct {
+		Date      string `json:"date"`
+		WorkoutID *int   `json:"workout_id"`
+	}
+
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding complete planned workout request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	if body.WorkoutID == nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "workout_id is required"})
+		return
+	}
+
+	completion := &store.PlannedWorkoutCompletion{
+		PlannedWorkoutID: planned.ID,
+		OccurrenceDate:   planned.ScheduledDate,
+		WorkoutID:        *body.WorkoutID,
+	}
+
+	date, err := parseOptionalDate(body.Date, "date")
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if date != nil {
+		completion.OccurrenceDate = *date
+	} else if planned.RRule != "" {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "date is required for recurring planned workouts"})
+		return
+	}
+
+	if len(plannedOccurrences(planned, completion.OccurrenceDate, completion.OccurrenceDate)) == 0 {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "the planned workout does not occur on that date"})
+		return
+	}
+
+	workout, err := h.workoutStore.GetWorkoutByID(int64(completion.WorkoutID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: GetWorkoutByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	if workout == nil || workout.UserID != currentUser.ID {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "workout doesn't exists"})
+		return
+	}
+
+	err = h.plannedStore.CompleteOccurrence(completion)
+	if err != nil {
+		if errors.Is(err, store.ErrOccurrenceAlreadyCompleted) || errors.Is(err, store.ErrWorkoutAlreadyLinked) {
+			utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+			return
+		}
+		h.logger.Printf("ERROR: CompleteOccurrence: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"completion": completion})
+}
+
+const (
+	calendarItemPlanned = "planned"
+	calendarItemWorkout = "workout"
+
+	plannedStatusScheduled = "scheduled"
+	plannedStatusCompleted = "completed"
+	plannedStatusMissed    = "missed"
+)
+
+// calendarItem is either an occurrence of a planned workout or a logged
+// workout that did not complete one.
+type calendarItem struct {
+	Type             string `json:"type"`
+	Title            string `json:"title"`
+	Status           string `json:"status,omitempty"`
+	PlannedWorkoutID *int   `json:"planned_workout_id,omitempty"`
+	TemplateID       *int   `json:"template_id,omitempty"`
+	WorkoutID        *int   `json:"workout_id,omitempty"`
+	DurationMinutes  *int   `json:"duration_minutes,omitempty"`
+}
+
+type calendarDay struct {
+	Date  string         `json:"date"`
+	Items []calendarItem `json:"items"`
+}
+
+func readCalendarRange(req *http.Request, user *store.User) (time.Time, time.Time, error) {
+	params := req.URL.Query()
+
+	from := localToday(user)
+	rawFrom, err := parseOptionalDate(params.Get("from"), "from")
+	if err != nil {
+		return from, from, err
+	}
+	if rawFrom != nil {
+		from = *rawFrom
+	}
+
+	to := from.AddDate(0, 0, defaultCalendarDays-1)
+	rawTo, err := parseOptionalDate(params.Get("to"), "to")
+	if err != nil {
+		return from, to, err
+	}
+	if rawTo != nil {
+		to = *rawTo
+	}
+
+	switch span := to.Sub(from); {
+	case span < 0:
+		return from, to, errors.New("from must not be after to")
+	case span > maxCalendarRange:
+		return from, to, errors.New("the calendar cannot span more than a year")
+	}
+
+	return from, to, nil
+}
+
+// HandleGetCalendar lays out planned workouts, with recurrences expanded,
+// and logged workouts day by day. A logged workout that completes a planned
+// occurrence in range is shown on that occurrence rather than on its own.
+func (h *CalendarHandler) HandleGetCalendar(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	from, to, err := readCalendarRange(req, currentUser)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	plans, err := h.plannedStore.ListPlannedWorkouts(currentUser.ID, from, to)
+	if err != nil {
+		h.logger.Printf("ERROR: ListPlannedWorkouts: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	completions, err := h.plannedStore.ListCompletions(currentUser.ID, from, to)
+	if err != nil {
+		h.logger.Printf("ERROR: ListCompletions: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	location := userLocation(currentUser)
+	workouts, err := h.plannedStore.ListLoggedWorkouts(currentUser.ID, location.String(), from, to)
+	if err != nil {
+		h.logger.Printf("ERROR: ListLoggedWorkouts: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	type occurrenceKey struct {
+		plannedID int
+		date      string
+	}
+	completedBy := map[occurrenceKey]int{}
+	linkedWorkouts := map[int]bool{}
+	for _, completion := range completions {
+		completedBy[occurrenceKey{completion.PlannedWorkoutID, completion.OccurrenceDate.Format(time.DateOnly)}] = completion.WorkoutID
+		linkedWorkouts[completion.WorkoutID] = true
+	}
+
+	days := []calendarDay{}
+	dayIndex := map[string]int{}
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		dayIndex[date.Format(time.DateOnly)] = len(days)
+		days = append(days, calendarDay{Date: date.Format(time.DateOnly), Items: []calendarItem{}})
+	}
+
+	today := localToday(currentUser)
+	for _, planned := range plans {
+		for _, date := range plannedOccurrences(planned, from, to) {
+			key := date.Format(time.DateOnly)
+			item := calendarItem{
+				Type:             calendarItemPlanned,
+				Title:            planned.Title,
+				Status:           plannedStatusScheduled,
+				PlannedWorkoutID: &planned.ID,
+				TemplateID:       planned.TemplateID,
+			}
+
+			if workoutID, ok := completedBy[occurrenceKey{planned.ID, key}]; ok {
+				item.Status = plannedStatusCompleted
+				item.WorkoutID = &workoutID
+			} else if date.Before(today) {
+				item.Status = plannedStatusMissed
+			}
+
+			days[dayIndex[key]].Items = append(days[dayIndex[key]].Items, item)
+		}
+	}
+
+	for _, workout := range workouts {
+		if linkedWorkouts[workout.ID] {
+			continue
+		}
+
+		key := workout.Date.Format(time.DateOnly)
+		i, ok := dayIndex[key]
+		if !ok {
+			continue
+		}
+		days[i].Items = append(days[i].Items, calendarItem{
+			Type:            calendarItemWorkout,
+			Title:           workout.Title,
+			WorkoutID:       &workout.ID,
+			DurationMinutes: &workout.DurationMinutes,
+		})
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{
+		"from":     from.Format(time.DateOnly),
+		"to":       to.Format(time.DateOnly),
+		"timezone": location.String(),
+		"days":     days,
+	})
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+type ExerciseHandler struct {
+	exerciseStore store.ExerciseStore
+	logger        *log.Logger
+}
+
+func NewExerciseHandler(exerciseStore store.ExerciseStore, logger *log.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		exerciseStore,
+		logger,
+	}
+}
+
+var movementTypes = map[string]bool{
+	"push": true, "pull": true, "squat": true, "hinge": true, "lunge": true,
+	"carry": true, "rotation": true, "isometric": true, "cardio": true,
+}
+
+type exerciseRequest struct {
+	Name             string   `json:"name"`
+	Aliases          []string `json:"aliases"`
+	PrimaryMuscles   []string `json:"primary_muscles"`
+	SecondaryMuscles []string `json:"secondary_muscles"`
+	Equipment        string   `json:"equipment"`
+	MovementType     string   `json:"movement_type"`
+}
+
+func (h *ExerciseHandler) validateExerciseRequest(req *exerciseRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(req.Name) > 255 {
+		return errors.New("name cannot be greater than 255 characters")
+	}
+
+	for i, alias := range req.Aliases {
+		req.Aliases[i] = strings.TrimSpace(alias)
+		if req.Aliases[i] == "" || len(req.Aliases[i]) > 255 {
+			return errors.New("aliases must be between 1 and 255 characters")
+		}
+	}
+
+	if len(req.Equipment) > 50 {
+		return errors.New("equipment cannot be greater than 50 characters")
+	}
+
+	if req.MovementType != "" && !movementTypes[req.MovementType] {
+		return errors.New("movement_type must be one of push, pull, squat, hinge, lunge, carry, rotation, isometric, cardio")
+	}
+
+	return nil
+}
+
+func (h *ExerciseHandler) HandleListExercises(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	currentUser := middleware.GetUser(req)
+
+	exercises, err := h.exerciseStore.ListExercises(store.ExerciseFilter{
+		UserID:      currentUser.ID,
+		Search:      strings.TrimSpace(query.Get("q")),
+		MuscleGroup: query.Get("muscle"),
+	})
+	if err != nil {
+		h.logger.Printf("ERROR: ListExercises: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+func (h *ExerciseHandler) HandleGetExerciseByID(res http.ResponseWriter, req *http.Request) {
+	exerciseID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	exercise, err := h.exerciseStore.GetExerciseByID(exerciseID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetExerciseByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	if exercise == nil || !exerciseVisibleTo(exercise, currentUser) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (h *ExerciseHandler) HandleCreateExercise(res http.ResponseWriter, req *http.Request) {
+	var body exerciseRequest
+
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create exercise request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	err = h.validateExerciseRequest(&body)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+
+	exercise := &store.Exercise{
+		Name:             body.Name,
+		Aliases:          body.Aliases,
+		PrimaryMuscles:   body.PrimaryMuscles,
+		SecondaryMuscles: body.SecondaryMuscles,
+		Equipment:        body.Equipment,
+		MovementType:     body.MovementType,
+		CreatedBy:        &currentUser.ID,
+	}
+
+	err = h.exerciseStore.CreateExercise(exercise)
+	if err != nil {
+		if errors.Is(err, store.ErrExerciseExists) {
+			utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+			return
+		}
+		h.logger.Printf("ERROR: CreateExercise: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"exercise": exercise})
+}
+
+// exerciseVisibleTo reports whether user may see exercise: seeded catalog
+// entries are shared, custom exercises belong to their creator alone.
+func exerciseVisibleTo(exercise *store.Exercise, user *store.User) bool {
+	return exercise.CreatedBy == nil || *exercise.CreatedBy == user.ID
+}
+
+// loadOwnedExercise fetches the exercise named by the id param and checks
+// the current user created it; seeded catalog entries have no owner and
+// are read-only. It writes the error response itself and returns nil.
+func (h *ExerciseHandler) loadOwnedExercise(res http.ResponseWriter, req *http.Request) *store.Exercise {
+	exerciseID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return nil
+	}
+
+	exercise, err := h.exerciseStore.GetExerciseByID(exerciseID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetExerciseByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(req)
+	if exercise == nil || !exerciseVisibleTo(exercise, currentUser) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return nil
+	}
+
+	if exercise.CreatedBy == nil || *exercise.CreatedBy != currentUser.ID {
+		utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to modify this exercise"})
+		return nil
+	}
+
+	return exercise
+}
+
+func (h *ExerciseHandler) HandleUpdateExercise(res http.ResponseWriter, req *http.Request) {
+	exercise := h.loadOwnedExercise(res, req)
+	if exercise == nil {
+		return
+	}
+
+	var body exerciseRequest
+
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update exercise request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	err = h.validateExerciseRequest(&body)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	exercise.Name = body.Name
+	exercise.Aliases = body.Aliases
+	exercise.PrimaryMuscles = body.PrimaryMuscles
+	exercise.SecondaryMuscles = body.SecondaryMuscles
+	exercise.Equipment = body.Equipment
+	exercise.MovementType = body.MovementType
+
+	err = h.exerciseStore.UpdateExercise(exercise)
+	if err != nil {
+		if errors.Is(err, store.ErrExerciseExists) {
+			utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+			return
+		}
+		h.logger.Printf("ERROR: UpdateExercise: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (h *ExerciseHandler) HandleDeleteExercise(res http.ResponseWriter, req *http.Request) {
+	exercise := h.loadOwnedExercise(res, req)
+	if exercise == nil {
+		return
+	}
+
+	err := h.exerciseStore.DeleteExercise(int64(exercise.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+			return
+		}
+		h.logger.Printf("ERROR: DeleteExercise: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"success": true})
+}
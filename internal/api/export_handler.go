@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ruhan/internal/export"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+type ExportHandler struct {
+	workoutStore store.WorkoutStore
+	logger       *log.Logger
+}
+
+func NewExportHandler(workoutStore store.WorkoutStore, logger *log.Logger) *ExportHandler {
+	return &ExportHandler{
+		workoutStore,
+		logger,
+	}
+}
+
+// exportWriteTimeout replaces the server's write timeout for exports, which
+// stream a user's whole history and can outlast it.
+const exportWriteTimeout = 10 * time.Minute
+
+// HandleExportMyData streams all of the user's workouts as a download in
+// format csv, json (the default, which POST /workouts/import reads back) or
+// md, a Markdown training journal.
+func (h *ExportHandler) HandleExportMyData(res http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatJSON
+	}
+
+	currentUser := middleware.GetUser(req)
+	now := time.Now()
+	location := userLocation(currentUser)
+
+	writer, err := export.NewWriter(format, res, export.Meta{
+		UserName:   currentUser.UserName,
+		ExportedAt: now,
+		Location:   location,
+	})
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = http.NewResponseController(res).SetWriteDeadline(now.Add(exportWriteTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.Printf("ERROR: SetWriteDeadline: %v", err)
+	}
+
+	filename := fmt.Sprintf("workouts-%s.%s", now.In(location).Format(time.DateOnly), format)
+	res.Header().Set("Content-Type", export.ContentType(format))
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	err = h.workoutStore.ExportWorkouts(currentUser.ID, writer.WriteWorkout)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// the status line is long gone; abort the response so the client
+		// sees a failed download instead of a truncated file
+		h.logger.Printf("ERROR: ExportWorkouts: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
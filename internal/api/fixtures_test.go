@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+)
+
+// serveAs routes req to handler at pattern with user logged in.
+func serveAs(user *store.User, pattern string, handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.HandleFunc(pattern, func(res http.ResponseWriter, req *http.Request) {
+		handler(res, middleware.SetUser(req, user))
+	})
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func IntPtr(i int) *int {
+	return &i
+}
+
+func FloatPtr(f float64) *float64 {
+	return &f
+}
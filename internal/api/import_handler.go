@@ -0,0 +1,225 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ruhan/internal/csvimport"
+	"github.com/ruhan/internal/export"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+type ImportHandler struct {
+	workoutStore  store.WorkoutStore
+	exerciseStore store.ExerciseStore
+	logger        *log.Logger
+}
+
+func NewImportHandler(workoutStore store.WorkoutStore, exerciseStore store.ExerciseStore, logger *log.Logger) *ImportHandler {
+	return &ImportHandler{
+		workoutStore,
+		exerciseStore,
+		logger,
+	}
+}
+
+const maxImportUploadBytes = 10 << 20
+
+// readUpload returns the uploaded file, taken from the "file" field of a
+// multipart form or else the raw request body, and a func to close it. It
+// writes the error response itself and returns nil.
+func readUpload(res http.ResponseWriter, req *http.Request, maxBytes int64, logger *log.Logger) (io.Reader, func()) {
+	req.Body = http.MaxBytesReader(res, req.Body, maxBytes)
+
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		return req.Body, func() {}
+	}
+
+	file, _, err := req.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.WriteJSON(res, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "file is too large"})
+		return nil, nil
+	}
+	if err != nil {
+		logger.Printf("ERROR: FormFile: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "upload the file as the file field"})
+		return nil, nil
+	}
+	return file, func() { file.Close() }
+}
+
+// importedWorkout is one workout of an import preview.
+type importedWorkout struct {
+	*store.Workout
+	AlreadyImported bool   `json:"already_imported"`
+	Error           string `json:"error,omitempty"`
+}
+
+// HandleImportWorkouts imports a CSV export from Strong, Hevy or FitNotes,
+// or one of our own JSON exports.
+// By default it only previews what would be imported: the parsed workouts,
+// which were imported before, and which exercise names have no catalog
+// match. With commit=true it creates the new, valid workouts; workouts
+// imported before are skipped, so the same file can be uploaded again.
+//
+// format (strong, hevy, fitnotes or json) overrides detection from the file
+// and distance_unit sets the unit of Strong's and FitNotes' distances.
+func (h *ImportHandler) HandleImportWorkouts(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	commit := query.Get("commit") == "true"
+
+	distanceUnit := query.Get("distance_unit")
+	if _, ok := store.DistanceUnits[distanceUnit]; distanceUnit != "" && !ok {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "distance_unit must be one of m, km, mi"})
+		return
+	}
+
+	upload, closeUpload := readUpload(res, req, maxImportUploadBytes, h.logger)
+	if upload == nil {
+		return
+	}
+	defer closeUpload()
+
+	currentUser := middleware.GetUser(req)
+	format := query.Get("format")
+	reader := bufio.NewReader(upload)
+
+	var result *csvimport.Result
+	var err error
+	if format == export.FormatJSON || (format == "" && looksLikeJSON(reader)) {
+		var workouts []*store.Workout
+		workouts, err = export.ReadJSON(reader)
+		result = &csvimport.Result{Format: export.FormatJSON, Workouts: workouts}
+	} else {
+		result, err = csvimport.Parse(reader, format, csvimport.Options{
+			Location:     userLocation(currentUser),
+			DistanceUnit: distanceUnit,
+		})
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.WriteJSON(res, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "file is too large"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	externalIDs := []string{}
+	exerciseNames := []string{}
+	for _, workout := range result.Workouts {
+		externalIDs = append(externalIDs, workout.ExternalID)
+		for _, entry := range workout.Entries {
+			if !slices.Contains(exerciseNames, entry.ExerciseName) {
+				exerciseNames = append(exerciseNames, entry.ExerciseName)
+			}
+		}
+	}
+
+	existing, err := h.workoutStore.FindImportedWorkouts(currentUser.ID, externalIDs)
+	if err != nil {
+		h.logger.Printf("ERROR: FindImportedWorkouts: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	resolved, err := h.exerciseStore.ResolveExerciseNames(currentUser.ID, exerciseNames)
+	if err != nil {
+		h.logger.Printf("ERROR: ResolveExerciseNames: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	unmatched := []string{}
+	for _, name := range exerciseNames {
+		if _, ok := resolved[name]; !ok {
+			unmatched = append(unmatched, name)
+		}
+	}
+
+	preview := []importedWorkout{}
+	valid := []*store.Workout{}
+	invalid := 0
+	for _, workout := range result.Workouts {
+		item := importedWorkout{Workout: workout}
+		_, item.AlreadyImported = existing[workout.ExternalID]
+
+		err := validateWorkoutEntries(workout.Entries)
+		if err == nil {
+			err = validateEntryGroups(workout.Groups, workout.Entries)
+		}
+		if err != nil {
+			item.Error = err.Error()
+			invalid++
+		} else if !item.AlreadyImported {
+			valid = append(valid, workout)
+		}
+		preview = append(preview, item)
+	}
+
+	rowErrors := result.Errors
+	if rowErrors == nil {
+		rowErrors = []csvimport.RowError{}
+	}
+
+	if !commit {
+		utils.WriteJSON(res, http.StatusOK, utils.Envelope{
+			"format":              result.Format,
+			"dry_run":             true,
+			"workouts":            preview,
+			"new":                 len(valid),
+			"already_imported":    len(existing),
+			"invalid":             invalid,
+			"unmatched_exercises": unmatched,
+			"errors":              rowErrors,
+		})
+		return
+	}
+
+	imported, duplicates, err := h.workoutStore.ImportWorkouts(currentUser.ID, valid)
+	if errors.Is(err, store.ErrImportConflict) {
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: ImportWorkouts: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workouts"})
+		return
+	}
+
+	workoutIDs := []int{}
+	for _, workout := range imported {
+		workoutIDs = append(workoutIDs, workout.ID)
+	}
+
+	status := http.StatusOK
+	if len(imported) > 0 {
+		status = http.StatusCreated
+	}
+	utils.WriteJSON(res, status, utils.Envelope{
+		"format":              result.Format,
+		"dry_run":             false,
+		"imported":            len(imported),
+		"workout_ids":         workoutIDs,
+		"already_imported":    len(existing) + duplicates,
+		"invalid":             invalid,
+		"unmatched_exercises": unmatched,
+		"errors":              rowErrors,
+	})
+}
+
+// looksLikeJSON reports whether the upload starts with a JSON object.
+func looksLikeJSON(reader *bufio.Reader) bool {
+	start, _ := reader.Peek(512)
+	start = bytes.TrimLeft(bytes.TrimPrefix(start, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(start) > 0 && start[0] == '{'
+}
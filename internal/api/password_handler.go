@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ruhan/internal/mailer"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/tokens"
+	"github.com/ruhan/internal/utils"
+)
+
+// passwordResetTTL is how long a password reset token can be used.
+const passwordResetTTL = 45 * time.Minute
+
+type PasswordHandler struct {
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	auditStore store.AuditStore
+	mailer     mailer.Mailer
+	logger     *log.Logger
+}
+
+func NewPasswordHandler(userStore store.UserStore, tokenStore store.TokenStore, auditStore store.AuditStore, mailer mailer.Mailer, logger *log.Logger) *PasswordHandler {
+	return &PasswordHandler{
+		userStore,
+		tokenStore,
+		auditStore,
+		mailer,
+		logger,
+	}
+}
+
+func validatePassword(password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		return errors.New("password cannot be longer than 72 bytes")
+	}
+	return nil
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// HandleChangePassword sets a new password after confirming the current one.
+// Every other session is logged out; the one making the request stays
+// logged in with its current tokens. API keys are listed, not revoked.
+func (h *PasswordHandler) HandleChangePassword(res http.ResponseWriter, req *http.Request) {
+	var body changePasswordRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding change password request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	passwordDoMatch, err := currentUser.PasswordHash.Matches(body.CurrentPassword)
+	if err != nil {
+		h.logger.Printf("ERROR: Password hash match %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !passwordDoMatch {
+		utils.WriteJSON(res, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		return
+	}
+
+	err = validatePassword(body.NewPassword)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if !h.setPassword(res, currentUser, body.NewPassword, middleware.GetSession(req).ID) {
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditPasswordChanged)
+
+	// API keys don't depend on the password and keep working, so point
+	// them out in case one of them is why the password is being changed
+	apiKeys, err := h.tokenStore.ListAPIKeys(currentUser.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: ListAPIKeys: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	response := utils.Envelope{"message": "password changed, other sessions were logged out"}
+	if len(apiKeys) > 0 {
+		response["api_keys"] = apiKeys
+		response["warning"] = "your API keys still work; revoke any you don't recognise with DELETE /users/me/api-keys/{id}"
+	}
+	utils.WriteJSON(res, http.StatusOK, response)
+}
+
+// setPassword saves the new password and revokes the user's reset tokens
+// and every session but keepSession, which is zero to revoke them all. It
+// writes the error response itself and returns false.
+func (h *PasswordHandler) setPassword(res http.ResponseWriter, user *store.User, password string, keepSession int64) bool {
+	err := user.PasswordHash.Set(password)
+	if err != nil {
+		h.logger.Printf("ERROR: hashing password %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+
+	err = h.userStore.UpdatePassword(user, keepSession)
+	if err != nil {
+		h.logger.Printf("ERROR: UpdatePassword %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	return true
+}
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// HandleRequestPasswordReset emails a password reset token to the account
+// with the given email. The response is the same whether or not there is
+// such an account, so it can't be used to find out who has one.
+func (h *PasswordHandler) HandleRequestPasswordReset(res http.ResponseWriter, req *http.Request) {
+	var body requestPasswordResetRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding password reset request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	err = validateEmail(body.Email)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userStore.GetUserByEmail(body.Email)
+	if err != nil {
+		h.logger.Printf("ERROR: GetUserByEmail %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if user != nil {
+		// only the latest reset token works
+		err = h.tokenStore.DeleteAllTokens(user.ID, tokens.ScopePasswordReset)
+		if err != nil {
+			h.logger.Printf("ERROR: DeleteAllTokens %v", err)
+			utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+
+		token, err := h.tokenStore.CreateNewToken(user.ID, passwordResetTTL, tokens.ScopePasswordReset)
+		if err != nil {
+			h.logger.Printf("ERROR: CreateNewToken %v", err)
+			utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		recordEvent(h.auditStore, h.logger, req, user.ID, store.AuditPasswordResetRequested)
+
+		sendEmail(h.mailer, h.logger, user.Email, "password_reset.tmpl", map[string]any{
+			"UserName":  user.UserName,
+			"Token":     token.PlainText,
+			"ExpiresIn": fmt.Sprintf("%d minutes", int(passwordResetTTL.Minutes())),
+		})
+	}
+
+	utils.WriteJSON(res, http.StatusAccepted, utils.Envelope{"message": "if an account with this email exists, a password reset email is on its way"})
+}
+
+// sendEmail renders the template and sends it in the background, so a slow
+// mail server doesn't hold up the response. Failures are only logged.
+func sendEmail(m mailer.Mailer, logger *log.Logger, to, template string, data any) {
+	message, err := mailer.NewMessage(to, template, data)
+	if err != nil {
+		logger.Printf("ERROR: rendering %s: %v", template, err)
+		return
+	}
+
+	go func() {
+		err := m.Send(message)
+		if err != nil {
+			logger.Printf("ERROR: sending %s: %v", template, err)
+		}
+	}()
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// HandleResetPassword sets a new password with a password reset token and
+// logs out every session.
+func (h *PasswordHandler) HandleResetPassword(res http.ResponseWriter, req *http.Request) {
+	var body resetPasswordRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding reset password request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	err = validatePassword(body.Password)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userStore.GetUserToken(tokens.ScopePasswordReset, body.Token)
+	if err != nil {
+		h.logger.Printf("ERROR: GetUserToken %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if user == nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired password reset token"})
+		return
+	}
+
+	if !h.setPassword(res, user, body.Password, 0) {
+		return
+	}
+
+	// a reset is how a compromised account is recovered, so API keys the
+	// attacker may have created go too
+	err = h.tokenStore.DeleteAllTokens(user.ID, tokens.ScopeAPIKey)
+	if err != nil {
+		h.logger.Printf("ERROR: DeleteAllTokens %s: %v", tokens.ScopeAPIKey, err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, user.ID, store.AuditPasswordReset)
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"message": "your password was reset and your API keys were revoked; log in with the new one"})
+}
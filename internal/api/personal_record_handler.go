@@ -0,0 +1,69 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+type PersonalRecordHandler struct {
+	recordStore   store.PersonalRecordStore
+	exerciseStore store.ExerciseStore
+	logger        *log.Logger
+}
+
+func NewPersonalRecordHandler(recordStore store.PersonalRecordStore, exerciseStore store.ExerciseStore, logger *log.Logger) *PersonalRecordHandler {
+	return &PersonalRecordHandler{
+		recordStore,
+		exerciseStore,
+		logger,
+	}
+}
+
+func (h *PersonalRecordHandler) HandleListMyRecords(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	records, err := h.recordStore.ListRecords(currentUser.ID, nil)
+	if err != nil {
+		h.logger.Printf("ERROR: ListRecords: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"records": records})
+}
+
+func (h *PersonalRecordHandler) HandleListExerciseRecords(res http.ResponseWriter, req *http.Request) {
+	exerciseID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	exercise, err := h.exerciseStore.GetExerciseByID(exerciseID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetExerciseByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if exercise == nil {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+
+	records, err := h.recordStore.ListRecords(currentUser.ID, &exercise.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: ListRecords: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"exercise": exercise, "records": records})
+}
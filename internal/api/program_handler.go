@@ -0,0 +1,424 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/progression"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+type ProgramHandler struct {
+	programStore  store.ProgramStore
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	recordStore   store.PersonalRecordStore
+	logger        *log.Logger
+}
+
+func NewProgramHandler(programStore store.ProgramStore, templateStore store.TemplateStore, workoutStore store.WorkoutStore, recordStore store.PersonalRecordStore, logger *log.Logger) *ProgramHandler {
+	return &ProgramHandler{
+		programStore,
+		templateStore,
+		workoutStore,
+		recordStore,
+		logger,
+	}
+}
+
+func (h *ProgramHandler) validateProgram(program *store.Program, userID int) error {
+	program.Name = strings.TrimSpace(program.Name)
+	if program.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if program.Weeks < 1 || program.Weeks > 52 {
+		return errors.New("weeks must be between 1 and 52")
+	}
+
+	if len(program.Slots) == 0 {
+		return errors.New("a program needs at least one slot")
+	}
+
+	seen := map[[2]int]bool{}
+	for _, slot := range program.Slots {
+		if slot.Week < 1 || slot.Week > program.Weeks {
+			return fmt.Errorf("slot week must be between 1 and %d", program.Weeks)
+		}
+		if slot.Day < 1 || slot.Day > 7 {
+			return errors.New("slot day must be between 1 and 7")
+		}
+		if seen[[2]int{slot.Week, slot.Day}] {
+			return fmt.Errorf("week %d day %d is scheduled twice", slot.Week, slot.Day)
+		}
+		seen[[2]int{slot.Week, slot.Day}] = true
+
+		for _, rule := range slot.Progression {
+			if err := progression.Validate(rule); err != nil {
+				return err
+			}
+		}
+
+		template, err := h.templateStore.GetTemplateByID(int64(slot.TemplateID))
+		if err != nil {
+			return err
+		}
+		if template == nil || (template.UserID != userID && !template.IsPublic) {
+			return fmt.Errorf("template %d not found", slot.TemplateID)
+		}
+		// everyone enrolled in a public program is prescribed its
+		// templates, so publishing one can't expose private templates
+		if program.IsPublic && !template.IsPublic {
+			return fmt.Errorf("template %d is private; a public program can only schedule public templates", slot.TemplateID)
+		}
+	}
+
+	return nil
+}
+
+func (h *ProgramHandler) HandleCreateProgram(res http.ResponseWriter, req *http.Request) {
+	var program store.Program
+
+	err := json.NewDecoder(req.Body).Decode(&program)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create program request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	program.UserID = currentUser.ID
+
+	err = h.validateProgram(&program, currentUser.ID)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = h.programStore.CreateProgram(&program)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateProgram: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"program": program})
+}
+
+func (h *ProgramHandler) HandleListPrograms(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+	includePublic := req.URL.Query().Get("public") == "true"
+
+	programs, err := h.programStore.ListPrograms(currentUser.ID, includePublic)
+	if err != nil {
+		h.logger.Printf("ERROR: ListPrograms: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"programs": programs})
+}
+
+// loadVisibleProgram fetches the program named by the id param if the
+// current user owns it or it is public. It writes the error response itself
+// and returns nil.
+func (h *ProgramHandler) loadVisibleProgram(res http.ResponseWriter, req *http.Request) *store.Program {
+	programID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return nil
+	}
+
+	program, err := h.programStore.GetProgramByID(programID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetProgramByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(req)
+	if program == nil || (program.UserID != currentUser.ID && !program.IsPublic) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "program not found"})
+		return nil
+	}
+
+	return program
+}
+
+func (h *ProgramHandler) HandleGetProgramByID(res http.ResponseWriter, req *http.Request) {
+	program := h.loadVisibleProgram(res, req)
+	if program == nil {
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"program": program})
+}
+
+func (h *ProgramHandler) HandleEnroll(res http.ResponseWriter, req *http.Request) {
+	program := h.loadVisibleProgram(res, req)
+	if program == nil {
+		return
+	}
+
+	var body struct {
+		StartDate string `json:"start_date"`
+	}
+
+	err := decodeOptionalBody(req, &body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding enroll request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	enrollment := &store.ProgramEnrollment{
+		UserID:    currentUser.ID,
+		ProgramID: program.ID,
+		StartDate: localToday(currentUser),
+	}
+
+	if body.StartDate != "" {
+		enrollment.StartDate, err = time.Parse(time.DateOnly, body.StartDate)
+		if err != nil {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "start_date must be a date (YYYY-MM-DD)"})
+			return
+		}
+	}
+
+	err = h.programStore.CreateEnrollment(enrollment)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateEnrollment: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"enrollment": enrollment})
+}
+
+func (h *ProgramHandler) HandleListEnrollments(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	enrollments, err := h.programStore.ListEnrollments(currentUser.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: ListEnrollments: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"enrollments": enrollments})
+}
+
+// programDay is what an enrollment prescribes for one calendar date.
+type programDay struct {
+	Date               string         `json:"date"`
+	Week               int            `json:"week"`
+	Day                int            `json:"day"`
+	Status             string         `json:"status"`
+	SlotID             *int           `json:"slot_id,omitempty"`
+	Workout            *store.Workout `json:"workout,omitempty"`
+	CompletedWorkoutID *int           `json:"completed_workout_id,omitempty"`
+}
+
+const (
+	programDayNotStarted = "not_started"
+	programDayFinished   = "finished"
+	programDayRest       = "rest"
+	programDayScheduled  = "scheduled"
+	programDayCompleted  = "completed"
+)
+
+// prescribeDay works out the enrollment's workout for date. It writes the
+// error response itself and returns nil on failure.
+func (h *ProgramHandler) prescribeDay(res http.ResponseWriter, req *http.Request, date time.Time) (*programDay, *store.ProgramEnrollment) {
+	enrollmentID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid enrollment id"})
+		return nil, nil
+	}
+
+	enrollment, err := h.programStore.GetEnrollmentByID(enrollmentID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetEnrollmentByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil
+	}
+
+	currentUser := middleware.GetUser(req)
+	if enrollment == nil || enrollment.UserID != currentUser.ID {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "enrollment not found"})
+		return nil, nil
+	}
+
+	program, err := h.programStore.GetProgramByID(int64(enrollment.ProgramID))
+	if err != nil || program == nil {
+		h.logger.Printf("ERROR: GetProgramByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil
+	}
+
+	start := enrollment.StartDate
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	dayIndex := int(date.Sub(start).Hours() / 24)
+
+	day := &programDay{
+		Date: date.Format(time.DateOnly),
+		Week: dayIndex/7 + 1,
+		Day:  dayIndex%7 + 1,
+	}
+
+	switch {
+	case dayIndex < 0:
+		day.Week, day.Day, day.Status = 0, 0, programDayNotStarted
+		return day, enrollment
+	case day.Week > program.Weeks:
+		day.Status = programDayFinished
+		return day, enrollment
+	}
+
+	var slot *store.ProgramSlot
+	for i := range program.Slots {
+		if program.Slots[i].Week == day.Week && program.Slots[i].Day == day.Day {
+			slot = &program.Slots[i]
+		}
+	}
+
+	if slot == nil {
+		day.Status = programDayRest
+		return day, enrollment
+	}
+	day.SlotID = &slot.ID
+
+	day.CompletedWorkoutID, err = h.programStore.GetSlotCompletion(enrollment.ID, slot.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetSlotCompletion: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil
+	}
+
+	day.Status = programDayScheduled
+	if day.CompletedWorkoutID != nil {
+		day.Status = programDayCompleted
+	}
+
+	template, err := h.templateStore.GetTemplateByID(int64(slot.TemplateID))
+	if err != nil || template == nil {
+		h.logger.Printf("ERROR: GetTemplateByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil
+	}
+	if template.UserID != currentUser.ID && !template.IsPublic {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "this program day's template is not available"})
+		return nil, nil
+	}
+
+	oneRepMaxes, err := h.recordStore.BestEstimatedOneRepMaxes(currentUser.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: BestEstimatedOneRepMaxes: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil
+	}
+
+	day.Workout = workoutFromTemplate(template, currentUser.ID)
+	day.Workout.Title = fmt.Sprintf("%s - week %d day %d", template.Title, day.Week, day.Day)
+	for i, entry := range day.Workout.Entries {
+		day.Workout.Entries[i] = progression.Prescribe(entry, slot.Progression, day.Week-1, oneRepMaxes)
+	}
+
+	return day, enrollment
+}
+
+func (h *ProgramHandler) HandleGetToday(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	day, _ := h.prescribeDay(res, req, localToday(currentUser))
+	if day == nil {
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"today": day})
+}
+
+// HandleLogToday logs today's prescribed workout through the regular
+// workout store. The body may override what was actually performed.
+func (h *ProgramHandler) HandleLogToday(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	var body struct {
+		DurationMinutes *int                 `json:"duration_minutes"`
+		CaloriesBurned  *int                 `json:"calories_burned"`
+		Entries         []store.WorkoutEntry `json:"entries"`
+	}
+
+	err := decodeOptionalBody(req, &body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding log program day request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	day, enrollment := h.prescribeDay(res, req, localToday(currentUser))
+	if day == nil {
+		return
+	}
+
+	switch day.Status {
+	case programDayCompleted:
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": store.ErrSlotAlreadyCompleted.Error()})
+		return
+	case programDayScheduled:
+	default:
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": "nothing is scheduled today", "today": day})
+		return
+	}
+
+	workout := day.Workout
+	if body.DurationMinutes != nil {
+		workout.DurationMinutes = *body.DurationMinutes
+	}
+	if body.CaloriesBurned != nil {
+		workout.CaloriesBurned = *body.CaloriesBurned
+	}
+	if body.Entries != nil {
+		err = validateWorkoutEntries(body.Entries)
+		if err == nil {
+			err = validateEntryGroups(workout.Groups, body.Entries)
+		}
+		if err != nil {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		workout.Entries = body.Entries
+	}
+
+	createdWorkout, err := h.workoutStore.CreateWorkout(workout)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateWorkout: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "Failed to create workout"})
+		return
+	}
+
+	err = h.programStore.CompleteSlot(enrollment.ID, *day.SlotID, createdWorkout.ID)
+	if err != nil {
+		if errors.Is(err, store.ErrSlotAlreadyCompleted) {
+			// a concurrent request logged the day first
+			h.workoutStore.DeleteWorkout(int64(createdWorkout.ID))
+			utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+			return
+		}
+		h.logger.Printf("ERROR: CompleteSlot: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+}
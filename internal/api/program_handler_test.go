@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTemplateStore struct {
+	store.TemplateStore
+	templates map[int64]*store.WorkoutTemplate
+}
+
+func (s *stubTemplateStore) GetTemplateByID(id int64) (*store.WorkoutTemplate, error) {
+	return s.templates[id], nil
+}
+
+type stubProgramStore struct {
+	store.ProgramStore
+	programs    map[int64]*store.Program
+	enrollments map[int64]*store.ProgramEnrollment
+}
+
+func (s *stubProgramStore) CreateProgram(program *store.Program) error {
+	program.ID = len(s.programs) + 1
+	for i := range program.Slots {
+		program.Slots[i].ID = i + 1
+	}
+	s.programs[int64(program.ID)] = program
+	return nil
+}
+
+func (s *stubProgramStore) GetProgramByID(id int64) (*store.Program, error) {
+	return s.programs[id], nil
+}
+
+func (s *stubProgramStore) CreateEnrollment(enrollment *store.ProgramEnrollment) error {
+	enrollment.ID = len(s.enrollments) + 1
+	s.enrollments[int64(enrollment.ID)] = enrollment
+	return nil
+}
+
+func (s *stubProgramStore) GetEnrollmentByID(id int64) (*store.ProgramEnrollment, error) {
+	return s.enrollments[id], nil
+}
+
+func (s *stubProgramStore) GetSlotCompletion(enrollmentID, slotID int) (*int, error) {
+	return nil, nil
+}
+
+type stubRecordStore struct {
+	store.PersonalRecordStore
+}
+
+func (s *stubRecordStore) BestEstimatedOneRepMaxes(userID int) (map[int]float64, error) {
+	return map[int]float64{}, nil
+}
+
+func TestPublicProgramTemplates(t *testing.T) {
+	coach := &store.User{ID: 1, UserName: "coach", Timezone: "UTC"}
+	athlete := &store.User{ID: 2, UserName: "athlete", Timezone: "UTC"}
+
+	entries := []store.WorkoutEntry{{ExerciseName: "Squat", Kind: store.EntryKindStrength, Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1}}
+	programs := &stubProgramStore{programs: map[int64]*store.Program{}, enrollments: map[int64]*store.ProgramEnrollment{}}
+	handler := NewProgramHandler(programs, &stubTemplateStore{templates: map[int64]*store.WorkoutTemplate{
+		1: {ID: 1, UserID: coach.ID, Title: "private squats", Entries: entries},
+		2: {ID: 2, UserID: coach.ID, Title: "public squats", IsPublic: true, Entries: entries},
+	}}, nil, &stubRecordStore{}, log.New(io.Discard, "", 0))
+
+	createProgram := func(templateID int, isPublic bool) *httptest.ResponseRecorder {
+		body, err := json.Marshal(store.Program{
+			Name: "5x5", Weeks: 1, IsPublic: isPublic,
+			Slots: []store.ProgramSlot{{Week: 1, Day: 1, TemplateID: templateID}},
+		})
+		require.NoError(t, err)
+		return serveAs(coach, "/programs", handler.HandleCreateProgram, httptest.NewRequest(http.MethodPost, "/programs", strings.NewReader(string(body))))
+	}
+
+	// a private program may use private templates, a public one may not
+	assert.Equal(t, http.StatusCreated, createProgram(1, false).Code)
+	res := createProgram(1, true)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "template 1 is private")
+
+	res = createProgram(2, true)
+	require.Equal(t, http.StatusCreated, res.Code)
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	res = serveAs(athlete, "/programs/{id}/enroll", handler.HandleEnroll, httptest.NewRequest(http.MethodPost, "/programs/2/enroll", strings.NewReader(`{"start_date": "`+today+`"}`)))
+	require.Equal(t, http.StatusCreated, res.Code)
+
+	res = serveAs(athlete, "/programs/enrollments/{id}/today", handler.HandleGetToday, httptest.NewRequest(http.MethodGet, "/programs/enrollments/1/today", nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "public squats - week 1 day 1")
+
+	// the private program stays out of reach
+	res = serveAs(athlete, "/programs/{id}/enroll", handler.HandleEnroll, httptest.NewRequest(http.MethodPost, "/programs/1/enroll", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	// a program published before templates were checked still doesn't
+	// hand out a private template
+	programs.programs[1].IsPublic = true
+	res = serveAs(athlete, "/programs/{id}/enroll", handler.HandleEnroll, httptest.NewRequest(http.MethodPost, "/programs/1/enroll", strings.NewReader(`{"start_date": "`+today+`"}`)))
+	require.Equal(t, http.StatusCreated, res.Code)
+
+	res = serveAs(athlete, "/programs/enrollments/{id}/today", handler.HandleGetToday, httptest.NewRequest(http.MethodGet, "/programs/enrollments/2/today", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.NotContains(t, res.Body.String(), "private squats")
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruhan/internal/calc"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/utils"
+)
+
+type StatsHandler struct {
+	statsStore store.StatsStore
+	logger     *log.Logger
+	// supportedZones caches TimezoneSupported by zone name; the zones
+	// Postgres knows don't change while the server runs
+	supportedZones sync.Map
+}
+
+func NewStatsHandler(statsStore store.StatsStore, logger *log.Logger) *StatsHandler {
+	return &StatsHandler{
+		statsStore: statsStore,
+		logger:     logger,
+	}
+}
+
+const (
+	defaultStatsRange = 12 * 7 * 24 * time.Hour
+	maxStatsRange     = 5 * 366 * 24 * time.Hour
+	maxDailyRange     = 366 * 24 * time.Hour
+)
+
+// userLocation resolves the user's configured time zone, falling back to
+// UTC for anything the runtime does not know about.
+func userLocation(user *store.User) *time.Location {
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// statsLocation is the user's time zone as userLocation resolves it, or
+// UTC when Postgres doesn't know it either; zones saved before they were
+// checked against Postgres would otherwise fail every stats query. Postgres
+// is asked once per zone. It writes the error response itself and returns
+// nil.
+func (h *StatsHandler) statsLocation(res http.ResponseWriter, user *store.User) *time.Location {
+	location := userLocation(user)
+	if supported, ok := h.supportedZones.Load(location.String()); ok {
+		if !supported.(bool) {
+			return time.UTC
+		}
+		return location
+	}
+
+	supported, err := h.statsStore.TimezoneSupported(location.String())
+	if err != nil {
+		h.logger.Printf("ERROR: TimezoneSupported: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	h.supportedZones.Store(location.String(), supported)
+	if !supported {
+		return time.UTC
+	}
+	return location
+}
+
+// localToday returns the user's current calendar date as midnight UTC.
+func localToday(user *store.User) time.Time {
+	return localDate(time.Now(), userLocation(user))
+}
+
+// localDate returns the calendar date at t in location as midnight UTC.
+func localDate(t time.Time, location *time.Location) time.Time {
+	t = t.In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func parseOptionalDate(raw, key string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	date, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return nil, errors.New(key + " must be a date (YYYY-MM-DD)")
+	}
+	return &date, nil
+}
+
+func (h *StatsHandler) readStatsQuery(req *http.Request, location *time.Location) (store.StatsQuery, error) {
+	params := req.URL.Query()
+
+	query := store.StatsQuery{
+		Bucket:   store.BucketWeek,
+		Timezone: location.String(),
+	}
+
+	switch bucket := params.Get("bucket"); bucket {
+	case "":
+	case store.BucketDay, store.BucketWeek, store.BucketMonth:
+		query.Bucket = bucket
+	default:
+		return query, errors.New("bucket must be one of day, week, month")
+	}
+
+	query.To = localDate(time.Now(), location)
+
+	to, err := parseOptionalDate(params.Get("to"), "to")
+	if err != nil {
+		return query, err
+	}
+	if to != nil {
+		query.To = *to
+	}
+
+	query.From = query.To.Add(-defaultStatsRange).AddDate(0, 0, 1)
+	from, err := parseOptionalDate(params.Get("from"), "from")
+	if err != nil {
+		return query, err
+	}
+	if from != nil {
+		query.From = *from
+	}
+
+	span := query.To.Sub(query.From)
+	switch {
+	case span < 0:
+		return query, errors.New("from must not be after to")
+	case span > maxStatsRange:
+		return query, errors.New("the requested range cannot exceed 5 years")
+	case query.Bucket == store.BucketDay && span > maxDailyRange:
+		return query, errors.New("daily buckets cannot span more than a year")
+	}
+
+	return query, nil
+}
+
+func (h *StatsHandler) HandleGetMyStats(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+	location := h.statsLocation(res, currentUser)
+	if location == nil {
+		return
+	}
+
+	query, err := h.readStatsQuery(req, location)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.statsStore.GetUserStats(currentUser.ID, query)
+	if err != nil {
+		h.logger.Printf("ERROR: GetUserStats: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"stats": stats})
+}
+
+// restWeekdays maps the user's configured rest day names onto weekdays,
+// ignoring anything unrecognised.
+func restWeekdays(user *store.User) []time.Weekday {
+	weekdays := []time.Weekday{}
+	for _, name := range user.RestDays {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(name, day.String()) {
+				weekdays = append(weekdays, day)
+			}
+		}
+	}
+	return weekdays
+}
+
+func (h *StatsHandler) HandleGetMyStreaks(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+	location := h.statsLocation(res, currentUser)
+	if location == nil {
+		return
+	}
+
+	days, err := h.statsStore.GetWorkoutDays(currentUser.ID, location.String())
+	if err != nil {
+		h.logger.Printf("ERROR: GetWorkoutDays: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	streaks := calc.ComputeStreaks(days, localDate(time.Now(), location), restWeekdays(currentUser), currentUser.WeeklyTarget)
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"streaks": streaks, "timezone": location.String()})
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubStatsStore struct {
+	store.StatsStore
+	unsupported map[string]bool
+	checked     map[string]int
+	query       store.StatsQuery
+	timezone    string
+}
+
+func (s *stubStatsStore) TimezoneSupported(name string) (bool, error) {
+	if s.checked == nil {
+		s.checked = map[string]int{}
+	}
+	s.checked[name]++
+	return !s.unsupported[name], nil
+}
+
+func (s *stubStatsStore) GetUserStats(userID int, query store.StatsQuery) (*store.UserStats, error) {
+	s.query = query
+	return &store.UserStats{Timezone: query.Timezone}, nil
+}
+
+func (s *stubStatsStore) GetWorkoutDays(userID int, timezone string) ([]time.Time, error) {
+	s.timezone = timezone
+	return []time.Time{}, nil
+}
+
+func TestHandleGetMyStats(t *testing.T) {
+	tests := []struct {
+		name         string
+		timezone     string
+		target       string
+		wantStatus   int
+		wantTimezone string
+		wantBucket   string
+		wantFrom     string
+		wantTo       string
+	}{
+		{name: "range", timezone: "Europe/Berlin", target: "/users/me/stats?bucket=month&from=2026-01-01&to=2026-03-31", wantStatus: http.StatusOK, wantTimezone: "Europe/Berlin", wantBucket: store.BucketMonth, wantFrom: "2026-01-01", wantTo: "2026-03-31"},
+		{name: "default range", timezone: "UTC", target: "/users/me/stats?to=2026-03-31", wantStatus: http.StatusOK, wantTimezone: "UTC", wantBucket: store.BucketWeek, wantFrom: "2026-01-07", wantTo: "2026-03-31"},
+		// Go knows this zone but the stub's Postgres doesn't
+		{name: "zone only Go knows", timezone: "America/Ciudad_Juarez", target: "/users/me/stats?to=2026-03-31", wantStatus: http.StatusOK, wantTimezone: "UTC", wantBucket: store.BucketWeek, wantFrom: "2026-01-07", wantTo: "2026-03-31"},
+		{name: "unknown bucket", timezone: "UTC", target: "/users/me/stats?bucket=year", wantStatus: http.StatusBadRequest},
+		{name: "from after to", timezone: "UTC", target: "/users/me/stats?from=2026-04-01&to=2026-03-31", wantStatus: http.StatusBadRequest},
+		{name: "daily buckets over a year", timezone: "UTC", target: "/users/me/stats?bucket=day&from=2024-01-01&to=2026-03-31", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statsStore := &stubStatsStore{unsupported: map[string]bool{"America/Ciudad_Juarez": true}}
+			handler := NewStatsHandler(statsStore, log.New(io.Discard, "", 0))
+			user := &store.User{ID: 1, Timezone: tt.timezone}
+
+			res := serveAs(user, "/users/me/stats", handler.HandleGetMyStats, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			require.Equal(t, tt.wantStatus, res.Code, res.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, tt.wantTimezone, statsStore.query.Timezone)
+			assert.Equal(t, tt.wantBucket, statsStore.query.Bucket)
+			assert.Equal(t, tt.wantFrom, statsStore.query.From.Format(time.DateOnly))
+			assert.Equal(t, tt.wantTo, statsStore.query.To.Format(time.DateOnly))
+		})
+	}
+}
+
+func TestHandleGetMyStreaksTimezone(t *testing.T) {
+	statsStore := &stubStatsStore{unsupported: map[string]bool{"America/Ciudad_Juarez": true}}
+	handler := NewStatsHandler(statsStore, log.New(io.Discard, "", 0))
+
+	for timezone, want := range map[string]string{"Asia/Tokyo": "Asia/Tokyo", "America/Ciudad_Juarez": "UTC", "Not/AZone": "UTC"} {
+		user := &store.User{ID: 1, Timezone: timezone}
+		res := serveAs(user, "/users/me/streaks", handler.HandleGetMyStreaks, httptest.NewRequest(http.MethodGet, "/users/me/streaks", nil))
+		require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+		assert.Equal(t, want, statsStore.timezone, timezone)
+		assert.Contains(t, res.Body.String(), `"timezone": "`+want+`"`)
+	}
+}
+
+func TestStatsLocationIsCached(t *testing.T) {
+	statsStore := &stubStatsStore{unsupported: map[string]bool{"America/Ciudad_Juarez": true}}
+	handler := NewStatsHandler(statsStore, log.New(io.Discard, "", 0))
+
+	for range 3 {
+		for _, timezone := range []string{"Asia/Tokyo", "America/Ciudad_Juarez"} {
+			user := &store.User{ID: 1, Timezone: timezone}
+			res := serveAs(user, "/users/me/streaks", handler.HandleGetMyStreaks, httptest.NewRequest(http.MethodGet, "/users/me/streaks", nil))
+			require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+		}
+	}
+	assert.Equal(t, map[string]int{"Asia/Tokyo": 1, "America/Ciudad_Juarez": 1}, statsStore.checked)
+	assert.Equal(t, "UTC", statsStore.timezone)
+}
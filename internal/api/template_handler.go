@@ -0,0 +1,398 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/track"
+	"github.com/ruhan/internal/utils"
+)
+
+type TemplateHandler struct {
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	logger        *log.Logger
+}
+
+func NewTemplateHandler(templateStore store.TemplateStore, workoutStore store.WorkoutStore, logger *log.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateStore,
+		workoutStore,
+		logger,
+	}
+}
+
+// validateWorkoutEntries checks the shape the workout_entries constraints
+// expect for each entry kind, so clients get a 400 instead of a failed
+// insert. Strength entries with a set log are checked set by set, since
+// their aggregates are derived.
+func validateWorkoutEntries(entries []store.WorkoutEntry) error {
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.ExerciseName) == "" {
+			return errors.New("exercise_name is required for every entry")
+		}
+
+		var err error
+		switch entry.Kind {
+		case "", store.EntryKindStrength:
+			err = validateStrengthEntry(entry)
+		case store.EntryKindCardio:
+			err = validateCardioEntry(entry)
+		default:
+			err = errors.New("kind must be strength or cardio")
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, heartRate := range []*int{entry.AvgHeartRate, entry.MaxHeartRate} {
+			if heartRate != nil && !track.ValidHeartRate(*heartRate) {
+				return fmt.Errorf("heart rates must be between %d and %d bpm", track.MinHeartRate, track.MaxHeartRate)
+			}
+		}
+		if entry.AvgHeartRate != nil && entry.MaxHeartRate != nil && *entry.MaxHeartRate < *entry.AvgHeartRate {
+			return errors.New("max_heart_rate cannot be below avg_heart_rate")
+		}
+	}
+	return nil
+}
+
+func validateStrengthEntry(entry store.WorkoutEntry) error {
+	if entry.Distance != nil || entry.DistanceUnit != "" || entry.ElevationGainMeters != nil || entry.AvgCadence != nil {
+		return errors.New("distance, distance_unit, elevation_gain_meters and avg_cadence only apply to cardio entries")
+	}
+
+	if len(entry.SetDetails) > 0 {
+		return validateWorkoutSets(entry.SetDetails)
+	}
+
+	if entry.Sets < 1 {
+		return errors.New("sets must be at least 1")
+	}
+
+	if (entry.Reps == nil) == (entry.DurationSeconds == nil) {
+		return errors.New("each entry needs either reps or duration_seconds, but not both")
+	}
+	return nil
+}
+
+func validateCardioEntry(entry store.WorkoutEntry) error {
+	if entry.Reps != nil || len(entry.SetDetails) > 0 {
+		return errors.New("cardio entries take duration_seconds and distance, not reps or set_details")
+	}
+
+	if entry.DurationSeconds == nil && entry.Distance == nil {
+		return errors.New("cardio entries need duration_seconds or distance")
+	}
+
+	if entry.Distance != nil {
+		if _, ok := store.DistanceUnits[entry.DistanceUnit]; !ok {
+			return errors.New("distance_unit must be one of m, km, mi")
+		}
+	} else if entry.DistanceUnit != "" {
+		return errors.New("distance_unit needs a distance")
+	}
+
+	switch {
+	case entry.Distance != nil && *entry.Distance < 0,
+		entry.DurationSeconds != nil && *entry.DurationSeconds < 0,
+		entry.ElevationGainMeters != nil && *entry.ElevationGainMeters < 0,
+		entry.AvgCadence != nil && *entry.AvgCadence < 0:
+		return errors.New("distance, duration_seconds, elevation_gain_meters and avg_cadence cannot be negative")
+	}
+	return nil
+}
+
+func validateWorkoutSets(sets []store.WorkoutSet) error {
+	timed := sets[0].DurationSeconds != nil
+	for _, set := range sets {
+		if (set.Reps == nil) == (set.DurationSeconds == nil) {
+			return errors.New("each set needs either reps or duration_seconds, but not both")
+		}
+		if (set.DurationSeconds != nil) != timed {
+			return errors.New("the sets of an entry must all use reps or all use duration_seconds")
+		}
+
+		switch set.Type {
+		case "", store.SetTypeWarmup, store.SetTypeWorking, store.SetTypeDrop, store.SetTypeFailure:
+		default:
+			return errors.New("set_type must be one of warmup, working, drop, failure")
+		}
+
+		switch {
+		case set.Reps != nil && *set.Reps < 0,
+			set.Weight != nil && *set.Weight < 0,
+			set.DurationSeconds != nil && *set.DurationSeconds < 0:
+			return errors.New("reps, weight and duration_seconds cannot be negative")
+		case set.RPE != nil && (*set.RPE < 1 || *set.RPE > 10):
+			return errors.New("rpe must be between 1 and 10")
+		case set.RIR != nil && *set.RIR < 0:
+			return errors.New("rir cannot be negative")
+		}
+	}
+	return nil
+}
+
+func (h *TemplateHandler) HandleCreateTemplate(res http.ResponseWriter, req *http.Request) {
+	var template store.WorkoutTemplate
+
+	err := json.NewDecoder(req.Body).Decode(&template)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create template request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	template.Title = strings.TrimSpace(template.Title)
+	if template.Title == "" {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "title is required"})
+		return
+	}
+
+	err = validateWorkoutEntries(template.Entries)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = validateEntryGroups(template.Groups, template.Entries)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	template.UserID = currentUser.ID
+
+	err = h.templateStore.CreateTemplate(&template)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateTemplate: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"template": template})
+}
+
+func (h *TemplateHandler) HandleListTemplates(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+	includePublic := req.URL.Query().Get("public") == "true"
+
+	templates, err := h.templateStore.ListTemplates(currentUser.ID, includePublic)
+	if err != nil {
+		h.logger.Printf("ERROR: ListTemplates: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"templates": templates})
+}
+
+// loadVisibleTemplate fetches the template named by the id param if the
+// current user owns it or it is public. It writes the error response itself
+// and returns nil.
+func (h *TemplateHandler) loadVisibleTemplate(res http.ResponseWriter, req *http.Request) *store.WorkoutTemplate {
+	templateID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return nil
+	}
+
+	template, err := h.templateStore.GetTemplateByID(templateID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetTemplateByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(req)
+	if template == nil || (template.UserID != currentUser.ID && !template.IsPublic) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+		return nil
+	}
+
+	return template
+}
+
+func (h *TemplateHandler) HandleGetTemplateByID(res http.ResponseWriter, req *http.Request) {
+	template := h.loadVisibleTemplate(res, req)
+	if template == nil {
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (h *TemplateHandler) HandleDeleteTemplate(res http.ResponseWriter, req *http.Request) {
+	template := h.loadVisibleTemplate(res, req)
+	if template == nil {
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	if template.UserID != currentUser.ID {
+		utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to delete it"})
+		return
+	}
+
+	err := h.templateStore.DeleteTemplate(int64(template.ID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: DeleteTemplate: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"success": true})
+}
+
+// decodeOptionalBody decodes a JSON body into dst, treating an empty body
+// as "no overrides".
+func decodeOptionalBody(req *http.Request, dst any) error {
+	err := json.NewDecoder(req.Body).Decode(dst)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func (h *TemplateHandler) HandleSaveWorkoutAsTemplate(res http.ResponseWriter, req *http.Request) {
+	workoutID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	var body struct {
+		Title    *string `json:"title"`
+		IsPublic bool    `json:"is_public"`
+	}
+
+	err = decodeOptionalBody(req, &body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding save template request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	workout, err := h.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: GetWorkoutByID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	if workout == nil || workout.UserID != currentUser.ID {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "workout doesn't exists"})
+		return
+	}
+
+	template := &store.WorkoutTemplate{
+		UserID:          currentUser.ID,
+		Title:           workout.Title,
+		Description:     workout.Description,
+		DurationMinutes: workout.DurationMinutes,
+		IsPublic:        body.IsPublic,
+		Groups:          workout.Groups,
+		Entries:         workout.Entries,
+	}
+
+	if body.Title != nil && strings.TrimSpace(*body.Title) != "" {
+		template.Title = strings.TrimSpace(*body.Title)
+	}
+
+	err = h.templateStore.CreateTemplate(template)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateTemplate: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"template": template})
+}
+
+// workoutFromTemplate turns a template into a new, unsaved workout for
+// userID.
+func workoutFromTemplate(template *store.WorkoutTemplate, userID int) *store.Workout {
+	workout := &store.Workout{
+		UserID:          userID,
+		Title:           template.Title,
+		Description:     template.Description,
+		DurationMinutes: template.DurationMinutes,
+		Groups:          make([]store.WorkoutEntryGroup, len(template.Groups)),
+		Entries:         make([]store.WorkoutEntry, len(template.Entries)),
+	}
+
+	for i, group := range template.Groups {
+		group.ID = 0
+		workout.Groups[i] = group
+	}
+
+	for i, entry := range template.Entries {
+		entry.ID = 0
+		entry.PersonalRecords = nil
+		entry.SetDetails = slices.Clone(entry.SetDetails)
+		for j := range entry.SetDetails {
+			entry.SetDetails[j].ID = 0
+		}
+		workout.Entries[i] = entry
+	}
+	return workout
+}
+
+func (h *TemplateHandler) HandleInstantiateTemplate(res http.ResponseWriter, req *http.Request) {
+	template := h.loadVisibleTemplate(res, req)
+	if template == nil {
+		return
+	}
+
+	var body struct {
+		Title           *string `json:"title"`
+		Description     *string `json:"description"`
+		DurationMinutes *int    `json:"duration_minutes"`
+		CaloriesBurned  *int    `json:"calories_burned"`
+	}
+
+	err := decodeOptionalBody(req, &body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding instantiate template request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	workout := workoutFromTemplate(template, currentUser.ID)
+
+	if body.Title != nil {
+		workout.Title = *body.Title
+	}
+	if body.Description != nil {
+		workout.Description = *body.Description
+	}
+	if body.DurationMinutes != nil {
+		workout.DurationMinutes = *body.DurationMinutes
+	}
+	if body.CaloriesBurned != nil {
+		workout.CaloriesBurned = *body.CaloriesBurned
+	}
+
+	createdWorkout, err := h.workoutStore.CreateWorkout(workout)
+	if err != nil {
+		h.logger.Printf("ERROR: CreateWorkout: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "Failed to create workout"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWorkoutEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   store.WorkoutEntry
+		wantErr string
+	}{
+		{name: "strength", entry: store.WorkoutEntry{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5)}},
+		{name: "strength with distance unit", entry: store.WorkoutEntry{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), DistanceUnit: "km"}, wantErr: "only apply to cardio entries"},
+		{name: "strength without reps or duration", entry: store.WorkoutEntry{ExerciseName: "Squat", Sets: 3}, wantErr: "either reps or duration_seconds"},
+		{name: "cardio", entry: store.WorkoutEntry{ExerciseName: "Running", Kind: store.EntryKindCardio, Distance: FloatPtr(5), DistanceUnit: "km"}},
+		{name: "cardio with unknown unit", entry: store.WorkoutEntry{ExerciseName: "Running", Kind: store.EntryKindCardio, Distance: FloatPtr(5), DistanceUnit: "furlong"}, wantErr: "distance_unit must be one of"},
+		{name: "cardio unit without distance", entry: store.WorkoutEntry{ExerciseName: "Running", Kind: store.EntryKindCardio, DurationSeconds: IntPtr(600), DistanceUnit: "km"}, wantErr: "distance_unit needs a distance"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWorkoutEntries([]store.WorkoutEntry{tt.entry})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func (s *stubTemplateStore) CreateTemplate(template *store.WorkoutTemplate) error {
+	template.ID = len(s.templates) + 1
+	s.templates[int64(template.ID)] = template
+	return nil
+}
+
+func (s *stubTemplateStore) DeleteTemplate(id int64) error {
+	delete(s.templates, id)
+	return nil
+}
+
+func (s *stubWorkoutStore) CreateWorkout(workout *store.Workout) (*store.Workout, error) {
+	workout.ID = len(s.workouts) + 1
+	s.workouts[int64(workout.ID)] = workout
+	return workout, nil
+}
+
+func TestTemplateHandler(t *testing.T) {
+	coach := &store.User{ID: 1, UserName: "coach"}
+	athlete := &store.User{ID: 2, UserName: "athlete"}
+
+	templates := &stubTemplateStore{templates: map[int64]*store.WorkoutTemplate{}}
+	workouts := &stubWorkoutStore{workouts: map[int64]*store.Workout{}}
+	handler := NewTemplateHandler(templates, workouts, log.New(io.Discard, "", 0))
+
+	create := func(body string) *httptest.ResponseRecorder {
+		return serveAs(coach, "/templates", handler.HandleCreateTemplate, httptest.NewRequest(http.MethodPost, "/templates", strings.NewReader(body)))
+	}
+
+	res := create(`{"title": "  ", "entries": []}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	res = create(`{"title": "legs", "entries": [{"exercise_name": "Squat", "sets": 3}]}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = create(`{"title": " legs ", "entries": [{"exercise_name": "Squat", "sets": 5, "reps": 5, "weight": 100, "order_index": 1}]}`)
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	require.Contains(t, templates.templates, int64(1))
+	assert.Equal(t, "legs", templates.templates[1].Title)
+	assert.Equal(t, coach.ID, templates.templates[1].UserID)
+
+	res = create(`{"title": "public legs", "is_public": true, "entries": [{"exercise_name": "Squat", "order_index": 1, "set_details": [{"set_type": "warmup", "reps": 10, "weight": 40}, {"reps": 8, "weight": 80}]}]}`)
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+
+	get := func(user *store.User, id string) *httptest.ResponseRecorder {
+		return serveAs(user, "/templates/{id}", handler.HandleGetTemplateByID, httptest.NewRequest(http.MethodGet, "/templates/"+id, nil))
+	}
+	assert.Equal(t, http.StatusOK, get(coach, "1").Code)
+	assert.Equal(t, http.StatusNotFound, get(athlete, "1").Code)
+	assert.Equal(t, http.StatusOK, get(athlete, "2").Code)
+	assert.Equal(t, http.StatusNotFound, get(coach, "9").Code)
+	assert.Equal(t, http.StatusBadRequest, get(coach, "legs").Code)
+
+	// instantiating a public template logs the workout for the caller
+	res = serveAs(athlete, "/templates/{id}/instantiate", handler.HandleInstantiateTemplate, httptest.NewRequest(http.MethodPost, "/templates/2/instantiate", strings.NewReader(`{"title": "monday"}`)))
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	require.Contains(t, workouts.workouts, int64(1))
+	workout := workouts.workouts[1]
+	assert.Equal(t, athlete.ID, workout.UserID)
+	assert.Equal(t, "monday", workout.Title)
+	require.Len(t, workout.Entries, 1)
+	assert.Zero(t, workout.Entries[0].ID)
+	require.Len(t, workout.Entries[0].SetDetails, 2)
+	assert.Equal(t, store.SetTypeWarmup, workout.Entries[0].SetDetails[0].Type)
+	assert.Equal(t, 8, *workout.Entries[0].SetDetails[1].Reps)
+
+	res = serveAs(athlete, "/templates/{id}/instantiate", handler.HandleInstantiateTemplate, httptest.NewRequest(http.MethodPost, "/templates/1/instantiate", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	// saving someone else's workout as a template isn't possible
+	res = serveAs(coach, "/workouts/{id}/template", handler.HandleSaveWorkoutAsTemplate, httptest.NewRequest(http.MethodPost, "/workouts/1/template", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	res = serveAs(athlete, "/workouts/{id}/template", handler.HandleSaveWorkoutAsTemplate, httptest.NewRequest(http.MethodPost, "/workouts/1/template", strings.NewReader(`{"title": "my monday"}`)))
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	assert.Equal(t, "my monday", templates.templates[3].Title)
+	assert.Equal(t, athlete.ID, templates.templates[3].UserID)
+
+	del := func(user *store.User, id string) *httptest.ResponseRecorder {
+		return serveAs(user, "/templates/{id}", handler.HandleDeleteTemplate, httptest.NewRequest(http.MethodDelete, "/templates/"+id, nil))
+	}
+	assert.Equal(t, http.StatusForbidden, del(athlete, "2").Code)
+	assert.Equal(t, http.StatusNotFound, del(athlete, "1").Code)
+	assert.Equal(t, http.StatusOK, del(coach, "1").Code)
+	assert.NotContains(t, templates.templates, int64(1))
+}
@@ -1,19 +1,36 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/ruhan/internal/middleware"
 	"github.com/ruhan/internal/store"
 	"github.com/ruhan/internal/tokens"
 	"github.com/ruhan/internal/utils"
 )
 
+const (
+	// accessTokenTTL is how long an authentication token lasts; clients
+	// get a new one with their refresh token.
+	accessTokenTTL = 15 * time.Minute
+	// refreshTokenTTL is how long a login lasts without being refreshed.
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// maxUserAgentLength is how much of the User-Agent header a session keeps.
+const maxUserAgentLength = 256
+
 type TokenHandler struct {
 	tokenStore store.TokenStore
 	userStore  store.UserStore
+	auditStore store.AuditStore
 	logger     *log.Logger
 }
 
@@ -22,10 +39,11 @@ type createTokenRequest struct {
 	Password string `json:"password"`
 }
 
-func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *TokenHandler {
+func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, auditStore store.AuditStore, logger *log.Logger) *TokenHandler {
 	return &TokenHandler{
 		tokenStore,
 		userStore,
+		auditStore,
 		logger,
 	}
 }
@@ -46,6 +64,10 @@ func (h *TokenHandler) HandleCreateToken(res http.ResponseWriter, req *http.Requ
 		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
+	if user == nil {
+		utils.WriteJSON(res, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		return
+	}
 
 	passwordDoMatch, err := user.PasswordHash.Matches(body.Password)
 	if err != nil {
@@ -59,11 +81,188 @@ func (h *TokenHandler) HandleCreateToken(res http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	token, err := h.tokenStore.CreateNewToken(user.ID, 24*time.Hour, tokens.ScopeAuth)
+	access, refresh, err := createSession(h.tokenStore, req, user.ID, 0)
+	if err != nil {
+		h.logger.Printf("ERROR: createSession %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, user.ID, store.AuditLogin)
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"auth_token": access, "refresh_token": refresh})
+}
+
+// truncateUserAgent makes a User-Agent header safe to store: invalid UTF-8,
+// which postgres rejects, is replaced and the result is cut to at most
+// maxUserAgentLength bytes without splitting a character.
+func truncateUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "\uFFFD")
+	if len(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+
+	cut := maxUserAgentLength
+	for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+		cut--
+	}
+	return userAgent[:cut]
+}
+
+// createSession issues an access and a refresh token for the user,
+// remembering the client that asked for them. familyID continues an existing
+// login; zero starts a new one.
+func createSession(tokenStore store.TokenStore, req *http.Request, userID int, familyID int64) (access, refresh *tokens.Token, err error) {
+	userAgent := truncateUserAgent(req.UserAgent())
+
+	issue := func(ttl time.Duration, scope string) (*tokens.Token, error) {
+		token, err := tokens.GenerateToken(userID, ttl, scope)
+		if err != nil {
+			return nil, err
+		}
+		token.FamilyID = familyID
+		token.UserAgent = userAgent
+		token.IPAddress = clientIP(req)
+
+		err = tokenStore.Insert(token)
+		if err != nil {
+			return nil, err
+		}
+		familyID = token.FamilyID
+		return token, nil
+	}
+
+	access, err = issue(accessTokenTTL, tokens.ScopeAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh, err = issue(refreshTokenTTL, tokens.ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// HandleRefreshToken exchanges a refresh token for a new access and refresh
+// token. Each refresh token works once: presenting one again means it was
+// stolen or replayed, so the whole login is revoked.
+func (h *TokenHandler) HandleRefreshToken(res http.ResponseWriter, req *http.Request) {
+	var body refreshTokenRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding refresh token request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	token, err := h.tokenStore.GetRefreshToken(body.RefreshToken)
+	if err != nil {
+		h.logger.Printf("ERROR: GetRefreshToken %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if token == nil {
+		utils.WriteJSON(res, http.StatusUnauthorized, utils.Envelope{"error": "invalid or expired refresh token"})
+		return
+	}
+
+	// reuse is checked before expiry, so replaying a stolen token still
+	// revokes the login after the token ran out
+	if token.UsedAt != nil {
+		err = store.ErrRefreshTokenReused
+	} else if !token.Expiry.After(time.Now()) {
+		utils.WriteJSON(res, http.StatusUnauthorized, utils.Envelope{"error": "invalid or expired refresh token"})
+		return
+	} else {
+		err = h.tokenStore.UseRefreshToken(token.ID)
+	}
+	if errors.Is(err, store.ErrRefreshTokenReused) {
+		err = h.tokenStore.DeleteSession(token.UserID, token.FamilyID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Printf("ERROR: DeleteSession %v", err)
+			utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		recordEvent(h.auditStore, h.logger, req, token.UserID, store.AuditRefreshTokenReused)
+
+		utils.WriteJSON(res, http.StatusUnauthorized, utils.Envelope{"error": "refresh token was already used; log in again"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: UseRefreshToken %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	access, refresh, err := createSession(h.tokenStore, req, token.UserID, token.FamilyID)
+	if err != nil {
+		h.logger.Printf("ERROR: createSession %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"auth_token": access, "refresh_token": refresh})
+}
+
+// HandleDeleteToken logs out by revoking the session the request was made
+// with, refresh token included.
+func (h *TokenHandler) HandleDeleteToken(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+	session := middleware.GetSession(req)
+
+	err := h.tokenStore.DeleteSession(currentUser.ID, session.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: DeleteSession %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditLogout)
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"success": true})
+}
+
+func (h *TokenHandler) HandleListSessions(res http.ResponseWriter, req *http.Request) {
+	currentUser := middleware.GetUser(req)
+
+	sessions, err := h.tokenStore.ListSessions(currentUser.ID)
 	if err != nil {
-		h.logger.Printf("ERROR: CreateNewToken %v", err)
+		h.logger.Printf("ERROR: ListSessions %v", err)
 		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
-	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"auth_token": token})
+
+	current := middleware.GetSession(req)
+	for _, session := range sessions {
+		session.Current = session.ID == current.ID
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"sessions": sessions})
+}
+
+// HandleDeleteSession revokes one of the user's sessions, such as one on a
+// lost device.
+func (h *TokenHandler) HandleDeleteSession(res http.ResponseWriter, req *http.Request) {
+	id, err := utils.ReadIdParam(req)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid session id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	err = h.tokenStore.DeleteSession(currentUser.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "session not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: DeleteSession %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	recordEvent(h.auditStore, h.logger, req, currentUser.ID, store.AuditSessionRevoked)
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"success": true})
 }
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTokenStore struct {
+	store.TokenStore
+	inserted       []*tokens.Token
+	refreshTokens  map[string]*store.RefreshToken
+	deletedSession int64
+}
+
+func (s *stubTokenStore) Insert(token *tokens.Token) error {
+	if token.FamilyID == 0 {
+		token.FamilyID = 1
+	}
+	s.inserted = append(s.inserted, token)
+	return nil
+}
+
+func (s *stubTokenStore) GetRefreshToken(tokenPlainText string) (*store.RefreshToken, error) {
+	return s.refreshTokens[tokenPlainText], nil
+}
+
+func (s *stubTokenStore) UseRefreshToken(id int64) error {
+	for _, token := range s.refreshTokens {
+		if token.ID == id {
+			now := time.Now()
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *stubTokenStore) DeleteSession(userID int, id int64) error {
+	s.deletedSession = id
+	return nil
+}
+
+type stubAuditStore struct {
+	store.AuditStore
+	events []string
+}
+
+func (s *stubAuditStore) RecordEvent(event *store.AuditEvent) error {
+	s.events = append(s.events, event.Event)
+	return nil
+}
+
+func TestHandleRefreshToken(t *testing.T) {
+	usedAt := time.Now().Add(-2 * time.Hour)
+	tests := []struct {
+		name        string
+		token       *store.RefreshToken
+		wantStatus  int
+		wantRevoked bool
+	}{
+		{name: "fresh", token: &store.RefreshToken{Expiry: time.Now().Add(time.Hour)}, wantStatus: http.StatusCreated},
+		{name: "expired", token: &store.RefreshToken{Expiry: time.Now().Add(-time.Hour)}, wantStatus: http.StatusUnauthorized},
+		{name: "reused", token: &store.RefreshToken{Expiry: time.Now().Add(time.Hour), UsedAt: &usedAt}, wantStatus: http.StatusUnauthorized, wantRevoked: true},
+		// a stolen token replayed after it expired still revokes the login
+		{name: "reused after expiry", token: &store.RefreshToken{Expiry: time.Now().Add(-time.Hour), UsedAt: &usedAt}, wantStatus: http.StatusUnauthorized, wantRevoked: true},
+		{name: "unknown", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenStore := &stubTokenStore{refreshTokens: map[string]*store.RefreshToken{}}
+			if tt.token != nil {
+				tt.token.ID, tt.token.UserID, tt.token.FamilyID = 1, 1, 7
+				tokenStore.refreshTokens["REFRESH"] = tt.token
+			}
+			auditStore := &stubAuditStore{}
+			handler := NewTokenHandler(tokenStore, nil, auditStore, log.New(io.Discard, "", 0))
+
+			res := httptest.NewRecorder()
+			handler.HandleRefreshToken(res, httptest.NewRequest(http.MethodPost, "/tokens/refresh", strings.NewReader(`{"refresh_token": "REFRESH"}`)))
+			assert.Equal(t, tt.wantStatus, res.Code, res.Body.String())
+
+			if tt.wantRevoked {
+				assert.Equal(t, int64(7), tokenStore.deletedSession)
+				assert.Equal(t, []string{store.AuditRefreshTokenReused}, auditStore.events)
+			} else {
+				assert.Zero(t, tokenStore.deletedSession)
+			}
+		})
+	}
+}
+
+func TestCreateSessionUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{name: "short", userAgent: "Mozilla/5.0", want: "Mozilla/5.0"},
+		// 3-byte runes put byte 256 in the middle of the 86th one
+		{name: "long multi-byte", userAgent: strings.Repeat("日", 100), want: strings.Repeat("日", 85)},
+		{name: "invalid utf-8", userAgent: "curl/8.0 \xff\xfe", want: "curl/8.0 �"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenStore := &stubTokenStore{}
+			req := httptest.NewRequest(http.MethodPost, "/tokens/authentication", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+
+			_, _, err := createSession(tokenStore, req, 1, 0)
+			require.NoError(t, err)
+			require.Len(t, tokenStore.inserted, 2)
+
+			for _, token := range tokenStore.inserted {
+				assert.Equal(t, tt.want, token.UserAgent)
+				assert.True(t, utf8.ValidString(token.UserAgent))
+				assert.LessOrEqual(t, len(token.UserAgent), maxUserAgentLength)
+			}
+		})
+	}
+}
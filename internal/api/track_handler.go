@@ -0,0 +1,517 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ruhan/internal/fit"
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/track"
+	"github.com/ruhan/internal/utils"
+)
+
+type TrackHandler struct {
+	trackStore   store.TrackStore
+	workoutStore store.WorkoutStore
+	logger       *log.Logger
+}
+
+func NewTrackHandler(trackStore store.TrackStore, workoutStore store.WorkoutStore, logger *log.Logger) *TrackHandler {
+	return &TrackHandler{
+		trackStore,
+		workoutStore,
+		logger,
+	}
+}
+
+const (
+	maxTrackUploadBytes = 25 << 20
+
+	// points closer than this to the simplified line are dropped
+	trackToleranceMeters = 5.0
+)
+
+// sportExercises names the catalog exercise an imported recording is logged
+// as; unrecognized sports are logged as plain cardio.
+var sportExercises = map[string]string{
+	track.SportRunning: "Running",
+	track.SportCycling: "Cycling",
+	track.SportRowing:  "Rowing",
+	track.SportWalking: "Walking",
+	track.SportHiking:  "Hiking",
+	fit.SportSwimming:  "Swimming",
+}
+
+func (h *TrackHandler) HandleImportGPX(res http.ResponseWriter, req *http.Request) {
+	h.importTrack(res, req, store.TrackFormatGPX, track.ParseGPX)
+}
+
+func (h *TrackHandler) HandleImportTCX(res http.ResponseWriter, req *http.Request) {
+	h.importTrack(res, req, store.TrackFormatTCX, track.ParseTCX)
+}
+
+// importTrack imports a GPX or TCX recording. title and exercise_name can be
+// given as form fields or query parameters.
+func (h *TrackHandler) importTrack(res http.ResponseWriter, req *http.Request, format string, parse func(io.Reader) (*track.Track, error)) {
+	upload, closeUpload := readUpload(res, req, maxTrackUploadBytes, h.logger)
+	if upload == nil {
+		return
+	}
+	defer closeUpload()
+
+	recording, err := parse(upload)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.WriteJSON(res, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "file is too large"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	summary := recording.Summarize()
+	if summary.DurationSeconds <= 0 && summary.DistanceMeters <= 0 {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "track has neither a duration nor a distance"})
+		return
+	}
+
+	exerciseName := strings.TrimSpace(req.FormValue("exercise_name"))
+	if exerciseName == "" {
+		exerciseName = sportExercises[recording.Sport]
+	}
+	if exerciseName == "" {
+		exerciseName = "Cardio"
+	}
+
+	title := strings.TrimSpace(req.FormValue("title"))
+	if title == "" {
+		title = strings.TrimSpace(recording.Name)
+	}
+	if title == "" {
+		title = exerciseName
+	}
+
+	entry := store.WorkoutEntry{
+		ExerciseName: exerciseName,
+		Kind:         store.EntryKindCardio,
+		Sets:         1,
+		OrderIndex:   1,
+		AvgHeartRate: summary.AvgHeartRate,
+		MaxHeartRate: summary.MaxHeartRate,
+		AvgCadence:   summary.AvgCadence,
+	}
+	if summary.DurationSeconds > 0 {
+		entry.DurationSeconds = &summary.DurationSeconds
+	}
+	if summary.DistanceMeters > 0 {
+		km := math.Round(summary.DistanceMeters) / 1000
+		entry.Distance = &km
+		entry.DistanceUnit = "km"
+	}
+	if summary.ElevationGainMeters != nil {
+		gain := math.Round(*summary.ElevationGainMeters*10) / 10
+		entry.ElevationGainMeters = &gain
+	}
+
+	err = validateWorkoutEntries([]store.WorkoutEntry{entry})
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	workout := &store.Workout{
+		UserID:          currentUser.ID,
+		Title:           title,
+		DurationMinutes: int(math.Round(float64(summary.DurationSeconds) / 60)),
+		Entries:         []store.WorkoutEntry{entry},
+	}
+
+	points := recording.Simplified(trackToleranceMeters)
+	workoutTrack := &store.WorkoutTrack{
+		SourceFormat:       format,
+		Polyline:           track.EncodePolyline(points),
+		PointCount:         len(points),
+		OriginalPointCount: recording.PositionCount(),
+	}
+	if !summary.StartedAt.IsZero() {
+		workoutTrack.StartedAt = &summary.StartedAt
+	}
+
+	createdWorkout, err := h.trackStore.ImportWorkout(workout, workoutTrack, workoutTrack.StartedAt)
+	if err != nil {
+		h.logger.Printf("ERROR: ImportWorkout: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workout"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"workout": createdWorkout, "track": workoutTrack})
+}
+
+func (h *TrackHandler) HandleGetWorkoutTrack(res http.ResponseWriter, req *http.Request) {
+	workoutID, err := utils.ReadIdParam(req)
+	if err != nil {
+		h.logger.Printf("ERROR: ReadIdParam: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	owner, err := h.workoutStore.GetWorkoutOwner(workoutID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: GetWorkoutOwner: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if owner != middleware.GetUser(req).ID {
+		utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to view it"})
+		return
+	}
+
+	workoutTrack, err := h.trackStore.GetTrackByWorkoutID(workoutID)
+	if err != nil {
+		h.logger.Printf("ERROR: GetTrackByWorkoutID: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if workoutTrack == nil {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "workout has no track"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"track": workoutTrack})
+}
+
+// fitExercises maps FIT exercise categories onto catalog exercises; other
+// categories are logged under their own name.
+var fitExercises = map[string]string{
+	"bench_press":       "Bench Press",
+	"calf_raise":        "Calf Raise",
+	"curl":              "Bicep Curl",
+	"deadlift":          "Deadlift",
+	"hip_raise":         "Hip Thrust",
+	"hip_swing":         "Kettlebell Swing",
+	"lateral_raise":     "Lateral Raise",
+	"lunge":             "Lunge",
+	"plank":             "Plank",
+	"pull_up":           "Pull Up",
+	"push_up":           "Push Up",
+	"row":               "Barbell Row",
+	"shoulder_press":    "Overhead Press",
+	"squat":             "Squat",
+	"triceps_extension": "Tricep Extension",
+}
+
+func fitExerciseName(category string) string {
+	if category == "" {
+		return "Unknown Exercise"
+	}
+	if name, ok := fitExercises[category]; ok {
+		return name
+	}
+	words := strings.Split(category, "_")
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// HandleImportFIT imports a FIT activity file the same way as GPX and TCX
+// files, with strength-training sets becoming entries with a set log. The
+// response reports which FIT messages were imported, skipped as not
+// relevant, or not recognized.
+func (h *TrackHandler) HandleImportFIT(res http.ResponseWriter, req *http.Request) {
+	upload, closeUpload := readUpload(res, req, maxTrackUploadBytes, h.logger)
+	if upload == nil {
+		return
+	}
+	defer closeUpload()
+
+	activity, err := fit.ParseActivity(upload)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.WriteJSON(res, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "file is too large"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	workout, startedAt := workoutFromFIT(activity)
+	if len(workout.Entries) == 0 {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "activity has no sessions or sets to import", "report": activity.Report})
+		return
+	}
+
+	if title := strings.TrimSpace(req.FormValue("title")); title != "" {
+		workout.Title = title
+	}
+
+	err = validateWorkoutEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error(), "report": activity.Report})
+		return
+	}
+
+	workout.UserID = middleware.GetUser(req).ID
+	workoutTrack := trackFromFIT(activity)
+
+	createdWorkout, err := h.trackStore.ImportWorkout(workout, workoutTrack, startedAt)
+	if err != nil {
+		h.logger.Printf("ERROR: ImportWorkout: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workout"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"workout": createdWorkout, "track": workoutTrack, "report": activity.Report})
+}
+
+// workoutFromFIT builds a workout with one cardio entry per session and, for
+// strength training, one entry per run of consecutive sets of the same
+// exercise. It returns the start of the activity to date the workout.
+func workoutFromFIT(activity *fit.Activity) (*store.Workout, *time.Time) {
+	workout := &store.Workout{}
+	var startedAt *time.Time
+	var elapsed float64
+
+	sessions := activity.Sessions
+	if len(sessions) == 0 {
+		// recordings cut short can lack a session; fall back to the laps
+		sessions = []fit.Session{{Totals: sumLaps(activity.Laps)}}
+	}
+
+	titles := []string{}
+	setsAdded := false
+	for _, session := range sessions {
+		if !session.StartTime.IsZero() && (startedAt == nil || session.StartTime.Before(*startedAt)) {
+			start := session.StartTime
+			startedAt = &start
+		}
+		if session.ElapsedSeconds != nil {
+			elapsed += *session.ElapsedSeconds
+		} else if session.TimerSeconds != nil {
+			elapsed += *session.TimerSeconds
+		}
+		if session.Calories != nil {
+			workout.CaloriesBurned += *session.Calories
+		}
+
+		if session.Strength || (session.Sport == fit.SportTraining && len(activity.Sets) > 0) {
+			if !setsAdded {
+				workout.Entries = append(workout.Entries, entriesFromFITSets(activity.Sets)...)
+				setsAdded = true
+			}
+			titles = append(titles, "Strength Training")
+			continue
+		}
+
+		entry, ok := entryFromFITSession(session, activity)
+		if !ok {
+			continue
+		}
+		workout.Entries = append(workout.Entries, entry)
+		titles = append(titles, entry.ExerciseName)
+	}
+
+	if !setsAdded && len(activity.Sets) > 0 {
+		workout.Entries = append(workout.Entries, entriesFromFITSets(activity.Sets)...)
+		titles = append(titles, "Strength Training")
+	}
+
+	for i := range workout.Entries {
+		workout.Entries[i].OrderIndex = i + 1
+	}
+
+	workout.DurationMinutes = int(math.Round(elapsed / 60))
+	workout.Title = strings.Join(slices.Compact(titles), " + ")
+	return workout, startedAt
+}
+
+func sumLaps(laps []fit.Lap) fit.Totals {
+	var totals fit.Totals
+	add := func(total **float64, value *float64) {
+		if value == nil {
+			return
+		}
+		if *total == nil {
+			*total = new(float64)
+		}
+		**total += *value
+	}
+
+	for _, lap := range laps {
+		if totals.StartTime.IsZero() {
+			totals.StartTime = lap.StartTime
+		}
+		add(&totals.ElapsedSeconds, lap.ElapsedSeconds)
+		add(&totals.TimerSeconds, lap.TimerSeconds)
+		add(&totals.DistanceMeters, lap.DistanceMeters)
+		add(&totals.AscentMeters, lap.AscentMeters)
+		if lap.MaxHeartRate != nil && track.ValidHeartRate(*lap.MaxHeartRate) && (totals.MaxHeartRate == nil || *lap.MaxHeartRate > *totals.MaxHeartRate) {
+			totals.MaxHeartRate = lap.MaxHeartRate
+		}
+	}
+	return totals
+}
+
+func entryFromFITSession(session fit.Session, activity *fit.Activity) (store.WorkoutEntry, bool) {
+	name := sportExercises[session.Sport]
+	if name == "" {
+		name = "Cardio"
+	}
+	entry := store.WorkoutEntry{
+		ExerciseName: name,
+		Kind:         store.EntryKindCardio,
+		Sets:         1,
+		AvgHeartRate: session.AvgHeartRate,
+		MaxHeartRate: session.MaxHeartRate,
+		AvgCadence:   session.AvgCadence,
+	}
+
+	// a glitching strap can leave the device's own figures out of range;
+	// recompute them from the samples that are plausible instead
+	if !plausibleHeartRate(entry.AvgHeartRate) || !plausibleHeartRate(entry.MaxHeartRate) {
+		entry.AvgHeartRate, entry.MaxHeartRate = nil, nil
+		if len(activity.Sessions) <= 1 {
+			entry.AvgHeartRate, entry.MaxHeartRate = recordHeartRates(activity.Records)
+		}
+	}
+
+	// FIT counts running cadence per foot
+	if session.Sport == fit.SportRunning && entry.AvgCadence != nil {
+		cadence := *entry.AvgCadence * 2
+		entry.AvgCadence = &cadence
+	}
+
+	seconds := session.TimerSeconds
+	if seconds == nil {
+		seconds = session.ElapsedSeconds
+	}
+	if seconds != nil && *seconds > 0 {
+		duration := int(math.Round(*seconds))
+		entry.DurationSeconds = &duration
+	}
+
+	meters := session.DistanceMeters
+	if meters == nil && len(activity.Sessions) <= 1 {
+		// without a session total the last record's odometer is the distance
+		for i := len(activity.Records) - 1; i >= 0 && meters == nil; i-- {
+			meters = activity.Records[i].DistanceMeters
+		}
+	}
+	if meters != nil && *meters > 0 {
+		km := math.Round(*meters) / 1000
+		entry.Distance = &km
+		entry.DistanceUnit = "km"
+	}
+
+	if session.AscentMeters != nil {
+		gain := math.Round(*session.AscentMeters*10) / 10
+		entry.ElevationGainMeters = &gain
+	}
+
+	return entry, entry.DurationSeconds != nil || entry.Distance != nil
+}
+
+// entriesFromFITSets groups consecutive sets of the same exercise into
+// entries. Sets with reps log reps and weight; sets without are timed.
+func entriesFromFITSets(sets []fit.Set) []store.WorkoutEntry {
+	entries := []store.WorkoutEntry{}
+	var category string
+	var timed bool
+
+	for _, set := range sets {
+		logged := store.WorkoutSet{Type: store.SetTypeWorking, Reps: set.Reps}
+		if set.Reps == nil {
+			duration := 0
+			if set.DurationSeconds != nil {
+				duration = int(math.Round(*set.DurationSeconds))
+			}
+			logged.DurationSeconds = &duration
+		}
+		if set.WeightKg != nil {
+			weight := math.Round(*set.WeightKg*100) / 100
+			logged.Weight = &weight
+		}
+
+		isTimed := logged.DurationSeconds != nil
+		if len(entries) == 0 || set.Category != category || isTimed != timed {
+			entries = append(entries, store.WorkoutEntry{
+				ExerciseName: fitExerciseName(set.Category),
+				Kind:         store.EntryKindStrength,
+			})
+			category, timed = set.Category, isTimed
+		}
+
+		entry := &entries[len(entries)-1]
+		entry.SetDetails = append(entry.SetDetails, logged)
+	}
+	return entries
+}
+
+// trackFromFIT returns the simplified route of the activity's records, or
+// nil when none has a position.
+func trackFromFIT(activity *fit.Activity) *store.WorkoutTrack {
+	points := []track.Point{}
+	for _, record := range activity.Records {
+		if record.Lat == nil || record.Lon == nil {
+			continue
+		}
+		points = append(points, track.Point{Lat: *record.Lat, Lon: *record.Lon, HasPosition: true, Time: record.Time})
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	simplified := track.Simplify(points, trackToleranceMeters)
+	workoutTrack := &store.WorkoutTrack{
+		SourceFormat:       store.TrackFormatFIT,
+		Polyline:           track.EncodePolyline(simplified),
+		PointCount:         len(simplified),
+		OriginalPointCount: len(points),
+	}
+	if !points[0].Time.IsZero() {
+		workoutTrack.StartedAt = &points[0].Time
+	}
+	return workoutTrack
+}
+
+func plausibleHeartRate(bpm *int) bool {
+	return bpm == nil || track.ValidHeartRate(*bpm)
+}
+
+// recordHeartRates averages the plausible heart-rate samples of the records
+// and finds their maximum. Both are nil when there are none.
+func recordHeartRates(records []fit.Record) (avgRate, maxRate *int) {
+	var sum, count int
+	for _, record := range records {
+		if record.HeartRate == nil || !track.ValidHeartRate(*record.HeartRate) {
+			continue
+		}
+		sum += *record.HeartRate
+		count++
+		if maxRate == nil || *record.HeartRate > *maxRate {
+			heartRate := *record.HeartRate
+			maxRate = &heartRate
+		}
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	average := int(math.Round(float64(sum) / float64(count)))
+	return &average, maxRate
+}
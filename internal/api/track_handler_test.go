@@ -0,0 +1,72 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/fit"
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTrackStore struct {
+	store.TrackStore
+	imported *store.Workout
+}
+
+func (s *stubTrackStore) ImportWorkout(workout *store.Workout, track *store.WorkoutTrack, startedAt *time.Time) (*store.Workout, error) {
+	s.imported = workout
+	return workout, nil
+}
+
+func TestImportTrackDropsImplausibleHeartRates(t *testing.T) {
+	trackStore := &stubTrackStore{}
+	handler := NewTrackHandler(trackStore, nil, log.New(io.Discard, "", 0))
+
+	// the strap glitched to 255 bpm halfway through
+	gpx := `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1"><trk><type>running</type><trkseg>
+		<trkpt lat="52.000" lon="13"><time>2026-03-01T07:00:00Z</time><extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>130</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+		<trkpt lat="52.001" lon="13"><time>2026-03-01T07:00:30Z</time><extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>255</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+		<trkpt lat="52.002" lon="13"><time>2026-03-01T07:01:00Z</time><extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>150</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+	</trkseg></trk></gpx>`
+	user := &store.User{ID: 1}
+	res := serveAs(user, "/workouts/import/gpx", handler.HandleImportGPX, httptest.NewRequest(http.MethodPost, "/workouts/import/gpx", strings.NewReader(gpx)))
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+
+	entry := trackStore.imported.Entries[0]
+	assert.Equal(t, 140, *entry.AvgHeartRate)
+	assert.Equal(t, 150, *entry.MaxHeartRate)
+}
+
+func TestEntryFromFITSessionHeartRates(t *testing.T) {
+	seconds := 60.0
+	records := []fit.Record{{HeartRate: IntPtr(130)}, {HeartRate: IntPtr(255)}, {HeartRate: IntPtr(150)}, {}}
+
+	tests := []struct {
+		name     string
+		avg, max *int
+		wantAvg  *int
+		wantMax  *int
+	}{
+		{"device figures are kept", IntPtr(135), IntPtr(150), IntPtr(135), IntPtr(150)},
+		{"glitched max is recomputed from the samples", IntPtr(138), IntPtr(255), IntPtr(140), IntPtr(150)},
+		{"glitched avg is recomputed from the samples", IntPtr(0), IntPtr(150), IntPtr(140), IntPtr(150)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := fit.Session{Sport: fit.SportRunning, Totals: fit.Totals{TimerSeconds: &seconds, AvgHeartRate: tt.avg, MaxHeartRate: tt.max}}
+			entry, ok := entryFromFITSession(session, &fit.Activity{Sessions: []fit.Session{session}, Records: records})
+			require.True(t, ok)
+			assert.Equal(t, tt.wantAvg, entry.AvgHeartRate)
+			assert.Equal(t, tt.wantMax, entry.MaxHeartRate)
+			assert.NoError(t, validateWorkoutEntries([]store.WorkoutEntry{entry}))
+		})
+	}
+}
@@ -3,22 +3,36 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/ruhan/internal/mailer"
+	"github.com/ruhan/internal/middleware"
 	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/tokens"
 	"github.com/ruhan/internal/utils"
 )
 
+// activationTTL is how long an email verification token can be used.
+const activationTTL = 3 * 24 * time.Hour
+
 type UserHandler struct {
-	usreStore store.UserStore
-	logger    *log.Logger
+	usreStore  store.UserStore
+	tokenStore store.TokenStore
+	mailer     mailer.Mailer
+	logger     *log.Logger
 }
 
-func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
+func NewUserHandler(userStore store.UserStore, tokenStore store.TokenStore, mailer mailer.Mailer, logger *log.Logger) *UserHandler {
 	return &UserHandler{
 		userStore,
+		tokenStore,
+		mailer,
 		logger,
 	}
 }
@@ -28,28 +42,56 @@ type registerUserRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Bio      string `json:"bio"`
+	Timezone string `json:"timezone"`
 }
 
-func (h *UserHandler) validateRegisterRequest(req *registerUserRequest) error {
-	if req.UserName == "" {
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+func validateUserName(userName string) error {
+	if userName == "" {
 		return errors.New("username is required")
 	}
 
-	if len(req.UserName) > 50 {
+	if len(userName) > 50 {
 		return errors.New("username cannot be greater than 50 characters")
 	}
 
-	if req.Email == "" {
+	// /users/me would shadow the profile of a user called me, and
+	// anonymized accounts are renamed deleted-<id>
+	if strings.EqualFold(userName, "me") || strings.HasPrefix(strings.ToLower(userName), "deleted-") {
+		return errors.New("this username is reserved")
+	}
+	return nil
+}
+
+func validateEmail(email string) error {
+	if email == "" {
 		return errors.New("email is required")
 	}
 
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(req.Email) {
+	if !emailRegex.MatchString(email) {
 		return errors.New("invalid email provided")
 	}
+	return nil
+}
 
-	if req.Password == "" {
-		return errors.New("password is required")
+func (h *UserHandler) validateRegisterRequest(req *registerUserRequest) error {
+	if err := validateUserName(req.UserName); err != nil {
+		return err
+	}
+
+	if err := validateEmail(req.Email); err != nil {
+		return err
+	}
+
+	if err := validatePassword(req.Password); err != nil {
+		return err
+	}
+
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return errors.New("invalid timezone provided")
+		}
 	}
 
 	return nil
@@ -70,10 +112,14 @@ func (h *UserHandler) HandleRegisterUser(res http.ResponseWriter, req *http.Requ
 		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
+	if reg.Timezone != "" && !h.checkTimezone(res, reg.Timezone) {
+		return
+	}
 
 	user := &store.User{
 		UserName: reg.UserName,
 		Email:    reg.Email,
+		Timezone: reg.Timezone,
 	}
 
 	if reg.Bio != "" {
@@ -88,11 +134,341 @@ func (h *UserHandler) HandleRegisterUser(res http.ResponseWriter, req *http.Requ
 	}
 
 	err = h.usreStore.CreateUser(user)
+	if errors.Is(err, store.ErrUsernameTaken) || errors.Is(err, store.ErrEmailTaken) {
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.Printf("ERROR: registering user %v", err)
 		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "issue with registering user"})
 		return
 	}
 
+	// the account exists at this point, so a failed activation email is
+	// something the user can recover from by asking for another one
+	err = h.sendActivationEmail(user)
+	if err != nil {
+		h.logger.Printf("ERROR: sendActivationEmail %v", err)
+		utils.WriteJSON(res, http.StatusCreated, utils.Envelope{
+			"user":    user,
+			"message": "we could not send your activation email, request a new one from POST /tokens/activation",
+		})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusCreated, utils.Envelope{"user": user})
+}
+
+// checkTimezone rejects a time zone Go accepts but Postgres doesn't, since
+// the stats and calendar queries convert dates in Postgres. It writes the
+// error response itself and returns false.
+func (h *UserHandler) checkTimezone(res http.ResponseWriter, name string) bool {
+	supported, err := h.usreStore.TimezoneSupported(name)
+	if err != nil {
+		h.logger.Printf("ERROR: TimezoneSupported: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if !supported {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid timezone provided"})
+		return false
+	}
+	return true
+}
+
+type trainingSettingsRequest struct {
+	Timezone     *string  `json:"timezone"`
+	RestDays     []string `json:"rest_days"`
+	WeeklyTarget *int     `json:"weekly_workout_target"`
+}
+
+func (h *UserHandler) HandleUpdateTrainingSettings(res http.ResponseWriter, req *http.Request) {
+	var body trainingSettingsRequest
+
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding training settings request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	user := middleware.GetUser(req)
+
+	if body.Timezone != nil {
+		location, err := time.LoadLocation(*body.Timezone)
+		if err != nil || *body.Timezone == "" {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid timezone provided"})
+			return
+		}
+		if !h.checkTimezone(res, location.String()) {
+			return
+		}
+		user.Timezone = location.String()
+	}
+
+	if body.RestDays != nil {
+		restDays := []string{}
+		for _, name := range body.RestDays {
+			valid := false
+			for day := time.Sunday; day <= time.Saturday; day++ {
+				if strings.EqualFold(name, day.String()) {
+					restDays = append(restDays, strings.ToLower(day.String()))
+					valid = true
+				}
+			}
+			if !valid {
+				utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "rest_days must be weekday names"})
+				return
+			}
+		}
+		if len(restDays) > 6 {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "at least one day must not be a rest day"})
+			return
+		}
+		user.RestDays = restDays
+	}
+
+	if body.WeeklyTarget != nil {
+		if *body.WeeklyTarget < 1 || *body.WeeklyTarget > 7 {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "weekly_workout_target must be between 1 and 7"})
+			return
+		}
+		user.WeeklyTarget = *body.WeeklyTarget
+	}
+
+	err = h.usreStore.UpdateTrainingSettings(user)
+	if err != nil {
+		h.logger.Printf("ERROR: UpdateTrainingSettings %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"user": user})
+}
+
+func (h *UserHandler) HandleGetMe(res http.ResponseWriter, req *http.Request) {
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"user": middleware.GetUser(req)})
+}
+
+type updateProfileRequest struct {
+	UserName          *string `json:"username"`
+	Email             *string `json:"email"`
+	Bio               *string `json:"bio"`
+	ProfileVisibility *string `json:"profile_visibility"`
+}
+
+// HandleUpdateMe updates the fields of the profile present in the body.
+func (h *UserHandler) HandleUpdateMe(res http.ResponseWriter, req *http.Request) {
+	var body updateProfileRequest
+
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update profile request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	user := middleware.GetUser(req)
+	previousEmail := user.Email
+
+	if body.UserName != nil {
+		err = validateUserName(*body.UserName)
+		if err != nil {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		user.UserName = *body.UserName
+	}
+
+	if body.Email != nil {
+		err = validateEmail(*body.Email)
+		if err != nil {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		// the email address is where password resets go, so handing it to
+		// someone else takes over the account; that needs a login, not a key
+		if !strings.EqualFold(*body.Email, previousEmail) && middleware.GetAPIKey(req) != nil {
+			utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "API keys cannot change the email address"})
+			return
+		}
+		user.Email = *body.Email
+	}
+
+	if body.Bio != nil {
+		user.Bio = *body.Bio
+	}
+
+	if body.ProfileVisibility != nil {
+		if *body.ProfileVisibility != store.ProfilePublic && *body.ProfileVisibility != store.ProfilePrivate {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "profile_visibility must be public or private"})
+			return
+		}
+		user.ProfileVisibility = *body.ProfileVisibility
+	}
+
+	// UpdateUser revokes the activation tokens sent to the old address
+	emailChanged := !strings.EqualFold(user.Email, previousEmail)
+	err = h.usreStore.UpdateUser(user)
+	if errors.Is(err, store.ErrUsernameTaken) || errors.Is(err, store.ErrEmailTaken) {
+		utils.WriteJSON(res, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: UpdateUser %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	// a changed address has to be verified again
+	if emailChanged {
+		err = h.sendActivationEmail(user)
+		if err != nil {
+			h.logger.Printf("ERROR: sendActivationEmail %v", err)
+		}
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"user": user})
+}
+
+// publicProfile is what other users see of a profile. Private profiles only
+// show the username.
+type publicProfile struct {
+	UserName          string     `json:"username"`
+	ProfileVisibility string     `json:"profile_visibility"`
+	Bio               string     `json:"bio,omitempty"`
+	MemberSince       *time.Time `json:"member_since,omitempty"`
+}
+
+func (h *UserHandler) HandleGetUserProfile(res http.ResponseWriter, req *http.Request) {
+	user, err := h.usreStore.GetUserByUserName(chi.URLParam(req, "username"))
+	if err != nil {
+		h.logger.Printf("ERROR: GetUserByUserName %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	// accounts waiting to be deleted are already gone as far as others
+	// are concerned
+	if user == nil || user.DeletionScheduledFor != nil {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+		return
+	}
+
+	profile := publicProfile{UserName: user.UserName, ProfileVisibility: user.ProfileVisibility}
+	if user.ProfileVisibility == store.ProfilePublic || user.ID == middleware.GetUser(req).ID {
+		profile.Bio = user.Bio
+		profile.MemberSince = &user.CreatedAt
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"profile": profile})
+}
+
+// sendActivationEmail replaces the user's activation tokens with a new one
+// and emails it to their current address.
+func (h *UserHandler) sendActivationEmail(user *store.User) error {
+	err := h.tokenStore.DeleteAllTokens(user.ID, tokens.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.tokenStore.CreateNewToken(user.ID, activationTTL, tokens.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	sendEmail(h.mailer, h.logger, user.Email, "activation.tmpl", map[string]any{
+		"UserName":  user.UserName,
+		"Token":     token.PlainText,
+		"ExpiresIn": fmt.Sprintf("%d days", int(activationTTL.Hours()/24)),
+	})
+	return nil
+}
+
+type activateUserRequest struct {
+	Token string `json:"token"`
+}
+
+// HandleActivateUser verifies the email address an activation token was
+// sent to.
+func (h *UserHandler) HandleActivateUser(res http.ResponseWriter, req *http.Request) {
+	var body activateUserRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding activate user request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	if body.Token == "" {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "token is required"})
+		return
+	}
+
+	user, err := h.usreStore.GetUserToken(tokens.ScopeActivation, body.Token)
+	if err != nil {
+		h.logger.Printf("ERROR: GetUserToken %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if user == nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired activation token"})
+		return
+	}
+
+	err = h.usreStore.VerifyEmail(user)
+	if err != nil {
+		h.logger.Printf("ERROR: VerifyEmail %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	err = h.tokenStore.DeleteAllTokens(user.ID, tokens.ScopeActivation)
+	if err != nil {
+		h.logger.Printf("ERROR: DeleteAllTokens %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
 	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"user": user})
 }
+
+type requestActivationRequest struct {
+	Email string `json:"email"`
+}
+
+// HandleRequestActivation sends a new activation email, for when the first
+// one expired or got lost. Like password resets, the response doesn't tell
+// whether the account exists.
+func (h *UserHandler) HandleRequestActivation(res http.ResponseWriter, req *http.Request) {
+	var body requestActivationRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding activation request %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "invalid payload"})
+		return
+	}
+
+	err = validateEmail(body.Email)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user, err := h.usreStore.GetUserByEmail(body.Email)
+	if err != nil {
+		h.logger.Printf("ERROR: GetUserByEmail %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if user != nil && !user.IsVerified() {
+		err = h.sendActivationEmail(user)
+		if err != nil {
+			h.logger.Printf("ERROR: sendActivationEmail %v", err)
+			utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
+
+	utils.WriteJSON(res, http.StatusAccepted, utils.Envelope{"message": "if an unverified account with this email exists, an activation email is on its way"})
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ruhan/internal/middleware"
+	"github.com/ruhan/internal/store"
+	"github.com/ruhan/internal/tokens"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleUpdateMeRejectsEmailChangeWithAPIKey(t *testing.T) {
+	user := &store.User{ID: 1, UserName: "alice", Email: "alice@example.com"}
+	handler := NewUserHandler(nil, nil, nil, log.New(io.Discard, "", 0))
+
+	req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"email": "mallory@example.com"}`))
+	req = middleware.SetAPIKey(req, &store.APIKey{ID: 3, Permissions: []string{tokens.PermissionProfileWrite}})
+
+	res := serveAs(user, "/users/me", handler.HandleUpdateMe, req)
+
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	assert.Equal(t, "alice@example.com", user.Email)
+}
+
+type stubUserStore struct {
+	store.UserStore
+	unsupported map[string]bool
+	updated     bool
+}
+
+func (s *stubUserStore) TimezoneSupported(name string) (bool, error) {
+	return !s.unsupported[name], nil
+}
+
+func (s *stubUserStore) UpdateTrainingSettings(user *store.User) error {
+	s.updated = true
+	return nil
+}
+
+func TestHandleUpdateTrainingSettingsTimezone(t *testing.T) {
+	tests := []struct {
+		name         string
+		timezone     string
+		wantStatus   int
+		wantTimezone string
+	}{
+		{name: "known", timezone: "Europe/Berlin", wantStatus: http.StatusOK, wantTimezone: "Europe/Berlin"},
+		{name: "unknown to Go", timezone: "Not/AZone", wantStatus: http.StatusBadRequest, wantTimezone: "UTC"},
+		{name: "unknown to Postgres", timezone: "America/Ciudad_Juarez", wantStatus: http.StatusBadRequest, wantTimezone: "UTC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := &stubUserStore{unsupported: map[string]bool{"America/Ciudad_Juarez": true}}
+			handler := NewUserHandler(userStore, nil, nil, log.New(io.Discard, "", 0))
+			user := &store.User{ID: 1, Timezone: "UTC"}
+
+			req := httptest.NewRequest(http.MethodPut, "/users/me/training-settings", strings.NewReader(`{"timezone": "`+tt.timezone+`"}`))
+			res := serveAs(user, "/users/me/training-settings", handler.HandleUpdateTrainingSettings, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code, res.Body.String())
+			assert.Equal(t, tt.wantTimezone, user.Timezone)
+			assert.Equal(t, tt.wantStatus == http.StatusOK, userStore.updated)
+		})
+	}
+}
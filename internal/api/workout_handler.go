@@ -4,9 +4,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ruhan/internal/calc"
 	"github.com/ruhan/internal/middleware"
 	"github.com/ruhan/internal/store"
 	"github.com/ruhan/internal/utils"
@@ -30,17 +36,263 @@ func (wh *WorkoutHandler) HandleGetWorkoutById(res http.ResponseWriter, req *htt
 	if err != nil {
 		wh.logger.Printf("ERROR: ReadIDParam: %v", err)
 		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "Invlaid workout id"})
+		return
+	}
+
+	formula, err := calc.ParseFormula(req.URL.Query().Get("formula"))
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
 	}
 
 	workout, err := wh.workoutStore.GetWorkoutByID(workoutId)
 
-	if err != nil {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		wh.logger.Printf("ERROR: GetWorkoutByID: %v", err)
 		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
 		return
 	}
 
-	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"workout": workout})
+	// workouts are private to their owner; anyone else gets the same answer
+	// as for a workout that doesn't exist
+	currentUser := middleware.GetUser(req)
+	if workout == nil || workout.UserID != currentUser.ID {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "Workout not found"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"workout": workout, "metrics": calc.Summarize(workout.Entries, formula)})
+}
+
+const (
+	defaultWorkoutPageSize = 20
+	maxWorkoutPageSize     = 100
+)
+
+func parseOptionalInt(query url.Values, key string) (*int, error) {
+	raw := query.Get(key)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", key)
+	}
+	return &value, nil
+}
+
+// parseOptionalTime accepts either a full RFC 3339 timestamp or a plain
+// YYYY-MM-DD date, which is taken as midnight UTC.
+func parseOptionalTime(query url.Values, key string) (*time.Time, error) {
+	raw := query.Get(key)
+	if raw == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		value, err := time.Parse(layout, raw)
+		if err == nil {
+			return &value, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD) or RFC 3339 timestamp", key)
+}
+
+// parseOptionalEndTime is parseOptionalTime for the exclusive end of a
+// range. A plain date includes that whole day, so it is taken as midnight
+// UTC of the day after: to=2026-03-31 lists workouts up to the end of March.
+func parseOptionalEndTime(query url.Values, key string) (*time.Time, error) {
+	value, err := parseOptionalTime(query, key)
+	if err != nil || value == nil {
+		return value, err
+	}
+
+	if _, err := time.Parse(time.DateOnly, query.Get(key)); err == nil {
+		end := value.AddDate(0, 0, 1)
+		return &end, nil
+	}
+	return value, nil
+}
+
+func (wh *WorkoutHandler) readWorkoutFilter(req *http.Request) (store.WorkoutFilter, error) {
+	query := req.URL.Query()
+	filter := store.WorkoutFilter{
+		SortBy:     store.WorkoutSortCreatedAt,
+		Descending: true,
+		Limit:      defaultWorkoutPageSize,
+		Cursor:     query.Get("cursor"),
+	}
+
+	var err error
+	if filter.From, err = parseOptionalTime(query, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseOptionalEndTime(query, "to"); err != nil {
+		return filter, err
+	}
+	if filter.MinDuration, err = parseOptionalInt(query, "min_duration"); err != nil {
+		return filter, err
+	}
+	if filter.MaxDuration, err = parseOptionalInt(query, "max_duration"); err != nil {
+		return filter, err
+	}
+	if filter.MinCalories, err = parseOptionalInt(query, "min_calories"); err != nil {
+		return filter, err
+	}
+	if filter.MaxCalories, err = parseOptionalInt(query, "max_calories"); err != nil {
+		return filter, err
+	}
+
+	limit, err := parseOptionalInt(query, "limit")
+	if err != nil {
+		return filter, err
+	}
+	if limit != nil {
+		if *limit < 1 || *limit > maxWorkoutPageSize {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxWorkoutPageSize)
+		}
+		filter.Limit = *limit
+	}
+
+	switch sort := query.Get("sort"); sort {
+	case "", store.WorkoutSortCreatedAt:
+	case store.WorkoutSortDuration:
+		filter.SortBy = store.WorkoutSortDuration
+	default:
+		return filter, errors.New("sort must be one of created_at, duration")
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Descending = false
+	default:
+		return filter, errors.New("order must be asc or desc")
+	}
+
+	return filter, nil
+}
+
+// HandleListWorkouts pages through the user's workouts. from and to take a
+// date or a timestamp; a date-only to includes that day, while a timestamp
+// is exclusive.
+func (wh *WorkoutHandler) HandleListWorkouts(res http.ResponseWriter, req *http.Request) {
+	filter, err := wh.readWorkoutFilter(req)
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+	filter.UserID = currentUser.ID
+
+	workouts, nextCursor, err := wh.workoutStore.ListWorkouts(filter)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		wh.logger.Printf("ERROR: ListWorkouts: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"workouts": workouts, "next_cursor": nextCursor})
+}
+
+const maxSearchResults = 50
+
+func (wh *WorkoutHandler) HandleSearchWorkouts(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	search := strings.TrimSpace(query.Get("q"))
+	if search == "" {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "q is required"})
+		return
+	}
+
+	limit := defaultWorkoutPageSize
+	requestedLimit, err := parseOptionalInt(query, "limit")
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if requestedLimit != nil {
+		limit = *requestedLimit
+	}
+	if limit < 1 || limit > maxSearchResults {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", maxSearchResults)})
+		return
+	}
+
+	currentUser := middleware.GetUser(req)
+
+	results, err := wh.workoutStore.SearchWorkouts(currentUser.ID, search, limit)
+	if err != nil {
+		wh.logger.Printf("ERROR: SearchWorkouts: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		return
+	}
+
+	utils.WriteJSON(res, http.StatusOK, utils.Envelope{"results": results})
+}
+
+// validateEntryGroups checks that every group carries the settings its type
+// needs and that grouped entries point at a group of the same workout.
+func validateEntryGroups(groups []store.WorkoutEntryGroup, entries []store.WorkoutEntry) error {
+	members := map[int]int{}
+	for _, group := range groups {
+		if _, ok := members[group.GroupIndex]; ok {
+			return fmt.Errorf("group_index %d is used twice", group.GroupIndex)
+		}
+		members[group.GroupIndex] = 0
+
+		switch group.Type {
+		case store.GroupStraight, store.GroupSuperset, store.GroupCircuit:
+		case store.GroupEMOM:
+			if group.IntervalSeconds == nil || group.Rounds == nil {
+				return errors.New("emom groups need interval_seconds and rounds")
+			}
+		case store.GroupAMRAP:
+			if group.TimeCapSeconds == nil {
+				return errors.New("amrap groups need time_cap_seconds")
+			}
+		default:
+			return errors.New("group type must be one of straight, superset, circuit, emom, amrap")
+		}
+
+		switch {
+		case group.Rounds != nil && *group.Rounds < 1:
+			return errors.New("rounds must be at least 1")
+		case group.RestSeconds != nil && *group.RestSeconds < 0,
+			group.RoundRestSeconds != nil && *group.RoundRestSeconds < 0:
+			return errors.New("rest cannot be negative")
+		case group.IntervalSeconds != nil && *group.IntervalSeconds < 1,
+			group.TimeCapSeconds != nil && *group.TimeCapSeconds < 1:
+			return errors.New("interval_seconds and time_cap_seconds must be positive")
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.GroupIndex == nil {
+			continue
+		}
+		if _, ok := members[*entry.GroupIndex]; !ok {
+			return fmt.Errorf("entry %q references unknown group_index %d", entry.ExerciseName, *entry.GroupIndex)
+		}
+		members[*entry.GroupIndex]++
+	}
+
+	for _, group := range groups {
+		switch count := members[group.GroupIndex]; {
+		case count == 0:
+			return fmt.Errorf("group %d has no entries", group.GroupIndex)
+		case group.Type == store.GroupSuperset && count < 2:
+			return errors.New("a superset needs at least two entries")
+		}
+	}
+	return nil
 }
 
 func (wh *WorkoutHandler) HandleCreateOut(res http.ResponseWriter, req *http.Request) {
@@ -62,6 +314,17 @@ func (wh *WorkoutHandler) HandleCreateOut(res http.ResponseWriter, req *http.Req
 	}
 
 	workout.UserID = currentUser.ID
+	// external IDs are only assigned by imports
+	workout.ExternalID = ""
+
+	err = validateWorkoutEntries(workout.Entries)
+	if err == nil {
+		err = validateEntryGroups(workout.Groups, workout.Entries)
+	}
+	if err != nil {
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
 
 	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout)
 
@@ -95,12 +358,38 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(res http.ResponseWriter, req *
 		return
 	}
 
+	currentUser := middleware.GetUser(req)
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		wh.logger.Printf("ERROR: GetUser: %v", err)
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "You must be logged in"})
+		return
+	}
+
+	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(workoutId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			wh.logger.Printf("ERROR: GetWorkoutOwner: %v", err)
+			utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "workout doesn't exists"})
+			return
+		}
+		wh.logger.Printf("ERROR: GetWorkoutOwner Internal Server Error: %v", err)
+		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	// someone else's workout is as good as missing
+	if workoutOwner != currentUser.ID {
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "Workout not found"})
+		return
+	}
+
 	var updateWorkoutReq struct {
-		Title           *string              `json:"title"`
-		Description     *string              `json:"description"`
-		DurationMinutes *int                 `json:"duration_minutes"`
-		CaloriesBurned  *int                 `json:"calories_minutes"`
-		Entries         []store.WorkoutEntry `json:"entries"`
+		Title           *string                   `json:"title"`
+		Description     *string                   `json:"description"`
+		DurationMinutes *int                      `json:"duration_minutes"`
+		CaloriesBurned  *int                      `json:"calories_minutes"`
+		Groups          []store.WorkoutEntryGroup `json:"groups"`
+		Entries         []store.WorkoutEntry      `json:"entries"`
 	}
 
 	err = json.NewDecoder(req.Body).Decode(&updateWorkoutReq)
@@ -123,31 +412,20 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(res http.ResponseWriter, req *
 		existingWorkout.DurationMinutes = *updateWorkoutReq.DurationMinutes
 	}
 
+	if updateWorkoutReq.Groups != nil {
+		existingWorkout.Groups = updateWorkoutReq.Groups
+	}
+
 	if updateWorkoutReq.Entries != nil {
 		existingWorkout.Entries = updateWorkoutReq.Entries
 	}
 
-	currentUser := middleware.GetUser(req)
-	if currentUser == nil || currentUser == store.AnonymousUser {
-		wh.logger.Printf("ERROR: GetUser: %v", err)
-		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": "You must be logged in"})
-		return
+	err = validateWorkoutEntries(existingWorkout.Entries)
+	if err == nil {
+		err = validateEntryGroups(existingWorkout.Groups, existingWorkout.Entries)
 	}
-
-	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(workoutId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			wh.logger.Printf("ERROR: GetWorkoutOwner: %v", err)
-			utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "workout doesn't exists"})
-			return
-		}
-		wh.logger.Printf("ERROR: GetWorkoutOwner Internal Server Error: %v", err)
-		utils.WriteJSON(res, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
-		return
-	}
-
-	if workoutOwner != currentUser.ID {
-		utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to update it"})
+		utils.WriteJSON(res, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
 
@@ -189,8 +467,9 @@ func (wh *WorkoutHandler) HandleDeleteWorkoutById(res http.ResponseWriter, req *
 		return
 	}
 
+	// someone else's workout is as good as missing
 	if workoutOwner != currentUser.ID {
-		utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to delete it"})
+		utils.WriteJSON(res, http.StatusNotFound, utils.Envelope{"error": "workout doesn't exists"})
 		return
 	}
 
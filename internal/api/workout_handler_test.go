@@ -0,0 +1,135 @@
+package api
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubWorkoutStore serves workouts from memory; methods the tests don't
+// need panic through the nil embedded interface.
+type stubWorkoutStore struct {
+	store.WorkoutStore
+	workouts map[int64]*store.Workout
+	filter   store.WorkoutFilter
+}
+
+func (s *stubWorkoutStore) GetWorkoutByID(id int64) (*store.Workout, error) {
+	workout, ok := s.workouts[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return workout, nil
+}
+
+func (s *stubWorkoutStore) ListWorkouts(filter store.WorkoutFilter) ([]*store.Workout, string, error) {
+	s.filter = filter
+	return []*store.Workout{}, "", nil
+}
+
+func (s *stubWorkoutStore) GetWorkoutOwner(id int64) (int, error) {
+	workout, ok := s.workouts[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return workout.UserID, nil
+}
+
+func (s *stubWorkoutStore) UpdateWorkout(workout *store.Workout) error {
+	s.workouts[int64(workout.ID)] = workout
+	return nil
+}
+
+func (s *stubWorkoutStore) DeleteWorkout(id int64) error {
+	delete(s.workouts, id)
+	return nil
+}
+
+func TestHandleGetWorkoutById(t *testing.T) {
+	owner := &store.User{ID: 1, UserName: "owner"}
+	other := &store.User{ID: 2, UserName: "other"}
+
+	handler := NewWorkoutHandler(&stubWorkoutStore{workouts: map[int64]*store.Workout{
+		7: {ID: 7, UserID: owner.ID, Title: "leg day"},
+	}}, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name   string
+		user   *store.User
+		path   string
+		status int
+	}{
+		{name: "owner", user: owner, path: "/workouts/7", status: http.StatusOK},
+		{name: "another user", user: other, path: "/workouts/7", status: http.StatusNotFound},
+		{name: "missing workout", user: owner, path: "/workouts/8", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveAs(tt.user, "/workouts/{id}", handler.HandleGetWorkoutById, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.status, res.Code)
+			if tt.status == http.StatusNotFound {
+				assert.NotContains(t, res.Body.String(), "leg day")
+			}
+		})
+	}
+}
+
+func TestHandleWriteOtherUsersWorkout(t *testing.T) {
+	owner := &store.User{ID: 1, UserName: "owner"}
+	other := &store.User{ID: 2, UserName: "other"}
+
+	workouts := &stubWorkoutStore{workouts: map[int64]*store.Workout{
+		7: {ID: 7, UserID: owner.ID, Title: "leg day"},
+	}}
+	handler := NewWorkoutHandler(workouts, log.New(io.Discard, "", 0))
+
+	// another user's workout looks the same as a missing one, even with a
+	// body that wouldn't validate
+	res := serveAs(other, "/workouts/{id}", handler.HandleUpdateWorkoutById, httptest.NewRequest(http.MethodPut, "/workouts/7", strings.NewReader(`{"title": "mine now", "entries": [{}]}`)))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	res = serveAs(other, "/workouts/{id}", handler.HandleDeleteWorkoutById, httptest.NewRequest(http.MethodDelete, "/workouts/7", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "leg day", workouts.workouts[7].Title)
+
+	res = serveAs(owner, "/workouts/{id}", handler.HandleUpdateWorkoutById, httptest.NewRequest(http.MethodPut, "/workouts/7", strings.NewReader(`{"title": "squat day"}`)))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "squat day", workouts.workouts[7].Title)
+	res = serveAs(owner, "/workouts/{id}", handler.HandleDeleteWorkoutById, httptest.NewRequest(http.MethodDelete, "/workouts/7", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, workouts.workouts, int64(7))
+}
+
+func TestHandleListWorkoutsDateRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantFrom string
+		wantTo   string
+	}{
+		{name: "dates", query: "?from=2026-03-01&to=2026-03-31", wantFrom: "2026-03-01T00:00:00Z", wantTo: "2026-04-01T00:00:00Z"},
+		{name: "timestamps", query: "?from=2026-03-01T06:00:00Z&to=2026-03-31T18:00:00Z", wantFrom: "2026-03-01T06:00:00Z", wantTo: "2026-03-31T18:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts := &stubWorkoutStore{}
+			handler := NewWorkoutHandler(workouts, log.New(io.Discard, "", 0))
+
+			res := serveAs(&store.User{ID: 1}, "/workouts", handler.HandleListWorkouts, httptest.NewRequest(http.MethodGet, "/workouts"+tt.query, nil))
+			require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+			assert.Equal(t, tt.wantFrom, workouts.filter.From.Format(time.RFC3339))
+			assert.Equal(t, tt.wantTo, workouts.filter.To.Format(time.RFC3339))
+		})
+	}
+}
@@ -6,25 +6,60 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/ruhan/internal/api"
+	"github.com/ruhan/internal/mailer"
 	"github.com/ruhan/internal/middleware"
 	"github.com/ruhan/internal/store"
 	"github.com/ruhan/migrations"
 )
 
+// Config holds the settings passed on the command line.
+type Config struct {
+	// Mailer is how emails are delivered: smtp, the default, sends them
+	// through the SMTP server, while log writes them to the log (tokens
+	// redacted) and file to .eml files in MailDir for local development.
+	Mailer  string
+	MailDir string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// MailSender is the From address of every email.
+	MailSender string
+}
+
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	Middleware     middleware.UseMiddleware
-	DB             *sql.DB
+	Logger          *log.Logger
+	WorkoutHandler  *api.WorkoutHandler
+	UserHandler     *api.UserHandler
+	TokenHandler    *api.TokenHandler
+	ExerciseHandler *api.ExerciseHandler
+	RecordHandler   *api.PersonalRecordHandler
+	StatsHandler    *api.StatsHandler
+	TemplateHandler *api.TemplateHandler
+	ProgramHandler  *api.ProgramHandler
+	CalendarHandler *api.CalendarHandler
+	TrackHandler    *api.TrackHandler
+	PasswordHandler *api.PasswordHandler
+	ImportHandler   *api.ImportHandler
+	ExportHandler   *api.ExportHandler
+	AccountHandler  *api.AccountHandler
+	APIKeyHandler   *api.APIKeyHandler
+	Middleware      middleware.UseMiddleware
+	DB              *sql.DB
 }
 
-func NewApplication() (*Application, error) {
+func NewApplication(cfg Config) (*Application, error) {
 	logger := log.New(os.Stdout, "GO: ", log.Ldate|log.Ltime)
 
+	appMailer, err := newMailer(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	// stores
 	pgDb, err := store.Open()
 	if err != nil {
@@ -41,25 +76,83 @@ func NewApplication() (*Application, error) {
 	workoutStore := store.NewPostgresWorkoutStore(pgDb)
 	userStore := store.NewPostgreUserStore(pgDb)
 	tokenStore := store.NewPostgresTokenStore(pgDb)
+	exerciseStore := store.NewPostgresExerciseStore(pgDb)
+	recordStore := store.NewPostgresPersonalRecordStore(pgDb)
+	statsStore := store.NewPostgresStatsStore(pgDb)
+	templateStore := store.NewPostgresTemplateStore(pgDb)
+	programStore := store.NewPostgresProgramStore(pgDb)
+	plannedStore := store.NewPostgresPlannedWorkoutStore(pgDb)
+	trackStore := store.NewPostgresTrackStore(pgDb, workoutStore)
+	auditStore := store.NewPostgresAuditStore(pgDb)
+	archiveStore := store.NewPostgresArchiveStore(pgDb)
 
 	// Handlers
 	workoutHandler := api.NewWorkoutHandler(workoutStore, logger)
-	userHandler := api.NewUserHandler(userStore, logger)
-	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
-	middlewareHandler := middleware.UseMiddleware{UserStore: userStore}
+	userHandler := api.NewUserHandler(userStore, tokenStore, appMailer, logger)
+	tokenHandler := api.NewTokenHandler(tokenStore, userStore, auditStore, logger)
+	exerciseHandler := api.NewExerciseHandler(exerciseStore, logger)
+	recordHandler := api.NewPersonalRecordHandler(recordStore, exerciseStore, logger)
+	statsHandler := api.NewStatsHandler(statsStore, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, workoutStore, logger)
+	programHandler := api.NewProgramHandler(programStore, templateStore, workoutStore, recordStore, logger)
+	calendarHandler := api.NewCalendarHandler(plannedStore, templateStore, workoutStore, tokenStore, userStore, logger)
+	trackHandler := api.NewTrackHandler(trackStore, workoutStore, logger)
+	importHandler := api.NewImportHandler(workoutStore, exerciseStore, logger)
+	exportHandler := api.NewExportHandler(workoutStore, logger)
+	accountHandler := api.NewAccountHandler(userStore, workoutStore, tokenStore, auditStore, archiveStore, logger)
+	apiKeyHandler := api.NewAPIKeyHandler(tokenStore, auditStore, logger)
+	passwordHandler := api.NewPasswordHandler(userStore, tokenStore, auditStore, appMailer, logger)
+	middlewareHandler := middleware.UseMiddleware{UserStore: userStore, TokenStore: tokenStore, Logger: logger}
 
 	app := &Application{
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		UserHandler:    userHandler,
-		TokenHandler:   tokenHandler,
-		Middleware:     middlewareHandler,
-		DB:             pgDb,
+		Logger:          logger,
+		WorkoutHandler:  workoutHandler,
+		UserHandler:     userHandler,
+		TokenHandler:    tokenHandler,
+		ExerciseHandler: exerciseHandler,
+		RecordHandler:   recordHandler,
+		StatsHandler:    statsHandler,
+		TemplateHandler: templateHandler,
+		ProgramHandler:  programHandler,
+		CalendarHandler: calendarHandler,
+		TrackHandler:    trackHandler,
+		PasswordHandler: passwordHandler,
+		ImportHandler:   importHandler,
+		ExportHandler:   exportHandler,
+		AccountHandler:  accountHandler,
+		APIKeyHandler:   apiKeyHandler,
+		Middleware:      middlewareHandler,
+		DB:              pgDb,
 	}
 
 	return app, nil
 }
 
+func newMailer(cfg Config, logger *log.Logger) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "log":
+		return mailer.NewLogMailer(logger), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.MailDir)
+	case "", "smtp":
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailSender), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q; use smtp, log or file", cfg.Mailer)
+	}
+}
+
+// RunMaintenance runs the periodic clean-up jobs every interval, starting
+// right away. It never returns.
+func (app *Application) RunMaintenance(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.AccountHandler.RunMaintenance()
+		<-ticker.C
+	}
+}
+
 func (app *Application) HealthCheck(res http.ResponseWriter, req *http.Request) {
 	fmt.Fprintf(res, "Status is available")
 }
@@ -0,0 +1,187 @@
+package calc
+
+import (
+	"github.com/ruhan/internal/calc/estimate"
+	"github.com/ruhan/internal/store"
+)
+
+// The one-rep max formulas live in package estimate so the store can
+// derive estimated 1RM records without importing calc.
+type Formula = estimate.Formula
+
+const (
+	Epley    = estimate.Epley
+	Brzycki  = estimate.Brzycki
+	Lombardi = estimate.Lombardi
+	Wathan   = estimate.Wathan
+)
+
+var ErrUnknownFormula = estimate.ErrUnknownFormula
+
+func ParseFormula(name string) (Formula, error) {
+	return estimate.ParseFormula(name)
+}
+
+// EstimateOneRepMax returns the estimated one-rep max for lifting weight
+// for reps repetitions; see estimate.OneRepMax.
+func EstimateOneRepMax(formula Formula, weight float64, reps int) float64 {
+	return estimate.OneRepMax(formula, weight, reps)
+}
+
+// countedSets returns the sets of an entry that count towards its metrics.
+// Entries without a set log are treated as identical working sets.
+func countedSets(entry store.WorkoutEntry) []store.WorkoutSet {
+	if len(entry.SetDetails) == 0 {
+		sets := make([]store.WorkoutSet, entry.Sets)
+		for i := range sets {
+			sets[i] = store.WorkoutSet{Reps: entry.Reps, Weight: entry.Weight, DurationSeconds: entry.DurationSeconds}
+		}
+		return sets
+	}
+
+	sets := []store.WorkoutSet{}
+	for _, set := range entry.SetDetails {
+		if set.Counts() {
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
+
+// Volume is the tonnage of an entry: reps x weight summed over its sets,
+// leaving out warm-ups and sets not completed. Timed and bodyweight sets
+// have no volume load.
+func Volume(entry store.WorkoutEntry) float64 {
+	volume := 0.0
+	for _, set := range countedSets(entry) {
+		if set.Reps != nil && set.Weight != nil {
+			volume += float64(*set.Reps) * *set.Weight
+		}
+	}
+	return volume
+}
+
+// bestSet returns the counted set of an entry with the highest estimated
+// one-rep max, or false if no set has both reps and weight.
+func bestSet(entry store.WorkoutEntry, formula Formula) (store.WorkoutSet, float64, bool) {
+	var best store.WorkoutSet
+	bestMax := 0.0
+	for _, set := range countedSets(entry) {
+		if set.Reps == nil || set.Weight == nil {
+			continue
+		}
+		if oneRepMax := EstimateOneRepMax(formula, *set.Weight, *set.Reps); oneRepMax > bestMax {
+			best, bestMax = set, oneRepMax
+		}
+	}
+	return best, bestMax, bestMax > 0
+}
+
+// Pace returns a cardio entry's average pace in seconds per kilometer, or
+// false without both a duration and a distance.
+func Pace(entry store.WorkoutEntry) (float64, bool) {
+	meters := entry.DistanceMeters()
+	if entry.DurationSeconds == nil || *entry.DurationSeconds <= 0 || meters == nil || *meters <= 0 {
+		return 0, false
+	}
+	return float64(*entry.DurationSeconds) / (*meters / 1000), true
+}
+
+// Speed returns a cardio entry's average speed in kilometers per hour, or
+// false without both a duration and a distance.
+func Speed(entry store.WorkoutEntry) (float64, bool) {
+	pace, ok := Pace(entry)
+	if !ok {
+		return 0, false
+	}
+	return 3600 / pace, true
+}
+
+// RelativeIntensity is the weight lifted as a fraction of a one-rep max.
+func RelativeIntensity(weight, oneRepMax float64) float64 {
+	if oneRepMax <= 0 {
+		return 0
+	}
+	return weight / oneRepMax
+}
+
+type EntryMetrics struct {
+	EntryID            int      `json:"entry_id"`
+	Volume             float64  `json:"volume"`
+	EstimatedOneRepMax *float64 `json:"estimated_1rm"`
+	RelativeIntensity  *float64 `json:"relative_intensity"`
+	DistanceMeters     *float64 `json:"distance_meters,omitempty"`
+	PaceSecondsPerKm   *float64 `json:"pace_seconds_per_km,omitempty"`
+	SpeedKmh           *float64 `json:"speed_kmh,omitempty"`
+}
+
+type WorkoutMetrics struct {
+	Formula             Formula        `json:"formula"`
+	TotalVolume         float64        `json:"total_volume"`
+	TotalSets           int            `json:"total_sets"`
+	TotalReps           int            `json:"total_reps"`
+	TotalDistanceMeters float64        `json:"total_distance_meters"`
+	Entries             []EntryMetrics `json:"entries"`
+}
+
+// exerciseKey groups entries of the same exercise, falling back to the
+// free-text name for entries not linked to the catalog.
+func exerciseKey(entry store.WorkoutEntry) any {
+	if entry.ExerciseID != nil {
+		return *entry.ExerciseID
+	}
+	return entry.ExerciseName
+}
+
+// Summarize computes the derived metrics of a workout's entries. An entry's
+// estimated one-rep max comes from its best set, and relative intensity
+// measures that set's weight against the best estimated one-rep max of the
+// same exercise within the workout, i.e. the session's top set.
+func Summarize(entries []store.WorkoutEntry, formula Formula) WorkoutMetrics {
+	metrics := WorkoutMetrics{Formula: formula, Entries: []EntryMetrics{}}
+
+	topSets := map[any]float64{}
+	for _, entry := range entries {
+		_, oneRepMax, ok := bestSet(entry, formula)
+		key := exerciseKey(entry)
+		if ok && oneRepMax > topSets[key] {
+			topSets[key] = oneRepMax
+		}
+	}
+
+	for _, entry := range entries {
+		entryMetrics := EntryMetrics{EntryID: entry.ID, Volume: Volume(entry)}
+
+		if entry.Kind == store.EntryKindCardio {
+			entryMetrics.DistanceMeters = entry.DistanceMeters()
+			if entryMetrics.DistanceMeters != nil {
+				metrics.TotalDistanceMeters += *entryMetrics.DistanceMeters
+			}
+			if pace, ok := Pace(entry); ok {
+				speed, _ := Speed(entry)
+				entryMetrics.PaceSecondsPerKm = &pace
+				entryMetrics.SpeedKmh = &speed
+			}
+			metrics.Entries = append(metrics.Entries, entryMetrics)
+			continue
+		}
+
+		for _, set := range countedSets(entry) {
+			metrics.TotalSets++
+			if set.Reps != nil {
+				metrics.TotalReps += *set.Reps
+			}
+		}
+		metrics.TotalVolume += entryMetrics.Volume
+
+		if set, oneRepMax, ok := bestSet(entry, formula); ok {
+			intensity := RelativeIntensity(*set.Weight, topSets[exerciseKey(entry)])
+			entryMetrics.EstimatedOneRepMax = &oneRepMax
+			entryMetrics.RelativeIntensity = &intensity
+		}
+
+		metrics.Entries = append(metrics.Entries, entryMetrics)
+	}
+
+	return metrics
+}
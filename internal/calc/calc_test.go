@@ -0,0 +1,104 @@
+package calc
+
+import (
+	"testing"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	reps := func(i int) *int { return &i }
+	weight := func(f float64) *float64 { return &f }
+	benchID := 1
+
+	metrics := Summarize([]store.WorkoutEntry{
+		{ID: 1, ExerciseID: &benchID, Sets: 1, Reps: reps(1), Weight: weight(100)},
+		{ID: 2, ExerciseID: &benchID, Sets: 3, Reps: reps(10), Weight: weight(60)},
+		{ID: 3, ExerciseName: "Plank", Sets: 3, DurationSeconds: reps(60)},
+	}, Epley)
+
+	assert.Equal(t, 7, metrics.TotalSets)
+	assert.Equal(t, 31, metrics.TotalReps)
+	assert.InDelta(t, 1900, metrics.TotalVolume, 0.001)
+
+	require.Len(t, metrics.Entries, 3)
+	assert.InDelta(t, 1.0, *metrics.Entries[0].RelativeIntensity, 0.001)
+	assert.InDelta(t, 80, *metrics.Entries[1].EstimatedOneRepMax, 0.001)
+	assert.InDelta(t, 0.6, *metrics.Entries[1].RelativeIntensity, 0.001)
+	assert.Nil(t, metrics.Entries[2].EstimatedOneRepMax)
+	assert.Zero(t, metrics.Entries[2].Volume)
+}
+
+func TestSummarizeSetLog(t *testing.T) {
+	reps := func(i int) *int { return &i }
+	weight := func(f float64) *float64 { return &f }
+	notDone := false
+
+	// pyramid with a warm-up and a missed last set
+	metrics := Summarize([]store.WorkoutEntry{
+		{ID: 1, ExerciseName: "Squat", Sets: 3, Reps: reps(6), Weight: weight(80), SetDetails: []store.WorkoutSet{
+			{Type: store.SetTypeWarmup, Reps: reps(10), Weight: weight(40)},
+			{Type: store.SetTypeWorking, Reps: reps(10), Weight: weight(60)},
+			{Type: store.SetTypeWorking, Reps: reps(8), Weight: weight(70)},
+			{Type: store.SetTypeWorking, Reps: reps(6), Weight: weight(80)},
+			{Type: store.SetTypeFailure, Reps: reps(4), Weight: weight(90), Completed: &notDone},
+		}},
+	}, Epley)
+
+	assert.Equal(t, 3, metrics.TotalSets)
+	assert.Equal(t, 24, metrics.TotalReps)
+	assert.InDelta(t, 600+560+480, metrics.TotalVolume, 0.001)
+
+	require.Len(t, metrics.Entries, 1)
+	assert.InDelta(t, 96, *metrics.Entries[0].EstimatedOneRepMax, 0.001)
+	assert.InDelta(t, 80.0/96, *metrics.Entries[0].RelativeIntensity, 0.001)
+}
+
+func TestPaceAndSpeed(t *testing.T) {
+	seconds := func(i int) *int { return &i }
+	distance := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name      string
+		entry     store.WorkoutEntry
+		wantPace  float64
+		wantSpeed float64
+		wantOK    bool
+	}{
+		{
+			name:     "5k in 25 minutes",
+			entry:    store.WorkoutEntry{Kind: store.EntryKindCardio, DurationSeconds: seconds(1500), Distance: distance(5), DistanceUnit: "km"},
+			wantPace: 300, wantSpeed: 12, wantOK: true,
+		},
+		{
+			name:     "a mile in 8 minutes",
+			entry:    store.WorkoutEntry{Kind: store.EntryKindCardio, DurationSeconds: seconds(480), Distance: distance(1), DistanceUnit: "mi"},
+			wantPace: 298.26, wantSpeed: 12.07, wantOK: true,
+		},
+		{
+			name:  "distance only",
+			entry: store.WorkoutEntry{Kind: store.EntryKindCardio, Distance: distance(400), DistanceUnit: "m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pace, ok := Pace(tt.entry)
+			require.Equal(t, tt.wantOK, ok)
+			speed, _ := Speed(tt.entry)
+			assert.InDelta(t, tt.wantPace, pace, 0.01)
+			assert.InDelta(t, tt.wantSpeed, speed, 0.01)
+		})
+	}
+
+	metrics := Summarize([]store.WorkoutEntry{
+		{ID: 1, Kind: store.EntryKindCardio, Sets: 1, DurationSeconds: seconds(1500), Distance: distance(5), DistanceUnit: "km"},
+		{ID: 2, Kind: store.EntryKindCardio, Sets: 1, Distance: distance(400), DistanceUnit: "m"},
+	}, Epley)
+	assert.InDelta(t, 5400, metrics.TotalDistanceMeters, 0.001)
+	assert.Zero(t, metrics.TotalSets)
+	assert.InDelta(t, 300, *metrics.Entries[0].PaceSecondsPerKm, 0.001)
+	assert.Nil(t, metrics.Entries[1].PaceSecondsPerKm)
+}
@@ -0,0 +1,57 @@
+// Package estimate holds the one-rep max formulas shared by the calc
+// package and the store's estimated 1RM records.
+package estimate
+
+import (
+	"errors"
+	"math"
+)
+
+// Formula selects how a one-rep max is estimated from a submaximal set.
+type Formula string
+
+const (
+	Epley    Formula = "epley"
+	Brzycki  Formula = "brzycki"
+	Lombardi Formula = "lombardi"
+	Wathan   Formula = "wathan"
+)
+
+var ErrUnknownFormula = errors.New("formula must be one of epley, brzycki, lombardi, wathan")
+
+func ParseFormula(name string) (Formula, error) {
+	switch formula := Formula(name); formula {
+	case "":
+		return Epley, nil
+	case Epley, Brzycki, Lombardi, Wathan:
+		return formula, nil
+	}
+	return "", ErrUnknownFormula
+}
+
+// OneRepMax returns the estimated one-rep max for lifting weight
+// for reps repetitions. A single rep is its own max; sets that the formula
+// cannot model (no reps, or 37+ reps for Brzycki) return 0.
+func OneRepMax(formula Formula, weight float64, reps int) float64 {
+	if reps <= 0 || weight <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+
+	r := float64(reps)
+	switch formula {
+	case Brzycki:
+		if reps >= 37 {
+			return 0
+		}
+		return weight * 36 / (37 - r)
+	case Lombardi:
+		return weight * math.Pow(r, 0.10)
+	case Wathan:
+		return 100 * weight / (48.8 + 53.8*math.Exp(-0.075*r))
+	default:
+		return weight * (1 + r/30)
+	}
+}
@@ -0,0 +1,45 @@
+package estimate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneRepMax(t *testing.T) {
+	tests := []struct {
+		name    string
+		formula Formula
+		weight  float64
+		reps    int
+		want    float64
+	}{
+		{name: "epley", formula: Epley, weight: 100, reps: 5, want: 116.67},
+		{name: "brzycki", formula: Brzycki, weight: 100, reps: 5, want: 112.5},
+		{name: "lombardi", formula: Lombardi, weight: 100, reps: 5, want: 117.46},
+		{name: "wathan", formula: Wathan, weight: 100, reps: 5, want: 116.58},
+		{name: "single rep is the max", formula: Wathan, weight: 140, reps: 1, want: 140},
+		{name: "no reps", formula: Epley, weight: 100, reps: 0, want: 0},
+		{name: "brzycki out of range", formula: Brzycki, weight: 20, reps: 40, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, OneRepMax(tt.formula, tt.weight, tt.reps), 0.01)
+		})
+	}
+}
+
+func TestParseFormula(t *testing.T) {
+	formula, err := ParseFormula("")
+	require.NoError(t, err)
+	assert.Equal(t, Epley, formula)
+
+	formula, err = ParseFormula("brzycki")
+	require.NoError(t, err)
+	assert.Equal(t, Brzycki, formula)
+
+	_, err = ParseFormula("mayhew")
+	assert.ErrorIs(t, err, ErrUnknownFormula)
+}
@@ -0,0 +1,99 @@
+package calc
+
+import "time"
+
+type Streaks struct {
+	CurrentStreak       int     `json:"current_streak"`
+	LongestStreak       int     `json:"longest_streak"`
+	LastWorkoutDate     *string `json:"last_workout_date"`
+	WeeklyTarget        int     `json:"weekly_target"`
+	ThisWeekWorkouts    int     `json:"this_week_workouts"`
+	WeeksMeetingTarget  int     `json:"weeks_meeting_target"`
+	CurrentWeeklyStreak int     `json:"current_weekly_streak"`
+	LongestWeeklyStreak int     `json:"longest_weekly_streak"`
+}
+
+// startOfWeek returns the Monday of the week containing day.
+func startOfWeek(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// ComputeStreaks derives streaks from the distinct local dates a user
+// trained on. Days are calendar dates at midnight UTC, today included.
+//
+// A streak counts workout days; a rest day without a workout neither
+// extends nor breaks it. Today only breaks the current streak once it is
+// over, so a user who has not trained yet today keeps yesterday's streak.
+// Weeks start on Monday and meet the target with weeklyTarget workout days.
+func ComputeStreaks(days []time.Time, today time.Time, restDays []time.Weekday, weeklyTarget int) Streaks {
+	streaks := Streaks{WeeklyTarget: weeklyTarget}
+	if len(days) == 0 {
+		return streaks
+	}
+
+	trained := map[time.Time]bool{}
+	first := days[0]
+	last := days[0]
+	for _, day := range days {
+		trained[day] = true
+		if day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+	}
+
+	lastWorkout := last.Format(time.DateOnly)
+	streaks.LastWorkoutDate = &lastWorkout
+
+	resting := map[time.Weekday]bool{}
+	for _, day := range restDays {
+		resting[day] = true
+	}
+
+	run := 0
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		switch {
+		case trained[day]:
+			run++
+		case resting[day.Weekday()], day.Equal(today):
+		default:
+			run = 0
+		}
+		if run > streaks.LongestStreak {
+			streaks.LongestStreak = run
+		}
+	}
+	streaks.CurrentStreak = run
+
+	weekRun := 0
+	currentWeek := startOfWeek(today)
+	for week := startOfWeek(first); !week.After(currentWeek); week = week.AddDate(0, 0, 7) {
+		count := 0
+		for i := 0; i < 7; i++ {
+			if trained[week.AddDate(0, 0, i)] {
+				count++
+			}
+		}
+
+		met := count >= weeklyTarget
+		if met {
+			streaks.WeeksMeetingTarget++
+			weekRun++
+		} else if !week.Equal(currentWeek) {
+			weekRun = 0
+		}
+
+		if weekRun > streaks.LongestWeeklyStreak {
+			streaks.LongestWeeklyStreak = weekRun
+		}
+		if week.Equal(currentWeek) {
+			streaks.ThisWeekWorkouts = count
+		}
+	}
+	streaks.CurrentWeeklyStreak = weekRun
+
+	return streaks
+}
@@ -0,0 +1,86 @@
+package calc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dates(values ...string) []time.Time {
+	days := []time.Time{}
+	for _, value := range values {
+		day, _ := time.Parse(time.DateOnly, value)
+		days = append(days, day)
+	}
+	return days
+}
+
+func TestComputeStreaks(t *testing.T) {
+	// 2026-03-02 is a Monday
+	tests := []struct {
+		name     string
+		days     []time.Time
+		today    string
+		restDays []time.Weekday
+		target   int
+		want     Streaks
+	}{
+		{
+			name:   "no workouts",
+			today:  "2026-03-10",
+			target: 3,
+			want:   Streaks{WeeklyTarget: 3},
+		},
+		{
+			name:   "today not trained yet keeps the streak",
+			days:   dates("2026-03-07", "2026-03-08", "2026-03-09"),
+			today:  "2026-03-10",
+			target: 3,
+			want: Streaks{
+				CurrentStreak: 3, LongestStreak: 3, WeeklyTarget: 3,
+				ThisWeekWorkouts: 1, WeeksMeetingTarget: 0,
+			},
+		},
+		{
+			name:   "a missed day breaks the streak",
+			days:   dates("2026-03-02", "2026-03-03", "2026-03-04", "2026-03-06"),
+			today:  "2026-03-08",
+			target: 4,
+			want: Streaks{
+				CurrentStreak: 0, LongestStreak: 3, WeeklyTarget: 4,
+				ThisWeekWorkouts: 4, WeeksMeetingTarget: 1, CurrentWeeklyStreak: 1, LongestWeeklyStreak: 1,
+			},
+		},
+		{
+			name:     "rest days do not break the streak",
+			days:     dates("2026-03-02", "2026-03-04", "2026-03-06", "2026-03-09"),
+			today:    "2026-03-09",
+			restDays: []time.Weekday{time.Tuesday, time.Thursday, time.Saturday, time.Sunday},
+			target:   3,
+			want: Streaks{
+				CurrentStreak: 4, LongestStreak: 4, WeeklyTarget: 3,
+				ThisWeekWorkouts: 1, WeeksMeetingTarget: 1, CurrentWeeklyStreak: 1, LongestWeeklyStreak: 1,
+			},
+		},
+		{
+			name:   "a missed week resets the weekly streak",
+			days:   dates("2026-02-16", "2026-02-17", "2026-02-23", "2026-02-24", "2026-03-09", "2026-03-10"),
+			today:  "2026-03-10",
+			target: 2,
+			want: Streaks{
+				CurrentStreak: 2, LongestStreak: 2, WeeklyTarget: 2,
+				ThisWeekWorkouts: 2, WeeksMeetingTarget: 3, CurrentWeeklyStreak: 1, LongestWeeklyStreak: 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			today, _ := time.Parse(time.DateOnly, tt.today)
+			got := ComputeStreaks(tt.days, today, tt.restDays, tt.target)
+			got.LastWorkoutDate = nil
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
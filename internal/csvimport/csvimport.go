@@ -0,0 +1,395 @@
+// Package csvimport reads the CSV exports of other workout apps into
+// workouts. Each app's export is a Format; rows are grouped into workouts
+// and consecutive rows of the same exercise into entries with a set log.
+package csvimport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruhan/internal/store"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unrecognized CSV export; supported formats are strong, hevy and fitnotes")
+	ErrEmpty         = errors.New("the CSV file has no rows")
+)
+
+// Row is one set as a Format reads it. Rows with the same WorkoutKey make up
+// one workout.
+type Row struct {
+	WorkoutKey      string
+	WorkoutName     string
+	Start           time.Time
+	DurationMinutes int
+	WorkoutNotes    string
+
+	Exercise     string
+	SetType      string
+	Weight       *float64
+	Reps         *int
+	Seconds      *int
+	Distance     *float64
+	DistanceUnit string
+	RPE          *float64
+	Notes        string
+	SupersetID   string
+	// Cardio marks timed rows the app itself files as cardio; rows with a
+	// distance are cardio either way.
+	Cardio bool
+}
+
+// Options are the settings a file doesn't carry itself.
+type Options struct {
+	// Location is the time zone the export's local times are in.
+	Location *time.Location
+	// DistanceUnit is the unit of distance columns that don't name one.
+	DistanceUnit string
+}
+
+// Format reads one app's export. ParseRow returns ok false for rows that
+// don't hold a set, such as rest timers.
+type Format interface {
+	Name() string
+	Matches(header []string) bool
+	ParseRow(record Record, options Options) (row Row, ok bool, err error)
+}
+
+// Formats are the supported exports, in detection order.
+var Formats = []Format{strongFormat{}, hevyFormat{}, fitNotesFormat{}}
+
+// RowError is a row that couldn't be read; Line counts from 1 and includes
+// the header.
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type Result struct {
+	Format   string
+	Workouts []*store.Workout
+	Errors   []RowError
+}
+
+// Parse reads an export, detecting its format from the header unless
+// format names one. Unreadable rows are reported in Result.Errors and left
+// out.
+func Parse(r io.Reader, format string, options Options) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmpty
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	parser, err := findFormat(format, header)
+	if err != nil {
+		return nil, err
+	}
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	if options.DistanceUnit == "" {
+		options.DistanceUnit = "km"
+	}
+
+	result := &Result{Format: parser.Name()}
+	rows := []Row{}
+	for line := 2; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		row, ok, err := parser.ParseRow(Record{header: header, fields: fields}, options)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if row.Reps == nil && row.Seconds == nil && row.Distance == nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: "row has no reps, duration or distance"})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	result.Workouts = groupWorkouts(parser.Name(), rows)
+	return result, nil
+}
+
+func findFormat(name string, header []string) (Format, error) {
+	for _, format := range Formats {
+		if name != "" && format.Name() == name {
+			return format, nil
+		}
+		if name == "" && format.Matches(header) {
+			return format, nil
+		}
+	}
+	if name != "" {
+		return nil, fmt.Errorf("unknown format %q; supported formats are strong, hevy and fitnotes", name)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// detectDelimiter picks comma or semicolon, whichever the header line uses;
+// Strong writes semicolons in locales with a decimal comma.
+func detectDelimiter(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// groupWorkouts turns rows into workouts in file order. A workout's external
+// ID is derived from the format and its key, so the same export always
+// yields the same IDs.
+func groupWorkouts(format string, rows []Row) []*store.Workout {
+	workouts := []*store.Workout{}
+	byKey := map[string]*store.Workout{}
+	supersets := map[*store.Workout]map[string]int{}
+
+	for _, row := range rows {
+		workout, ok := byKey[row.WorkoutKey]
+		if !ok {
+			workout = &store.Workout{
+				Title:           row.WorkoutName,
+				Description:     row.WorkoutNotes,
+				DurationMinutes: row.DurationMinutes,
+				CreatedAt:       row.Start,
+				ExternalID:      externalID(format, row.WorkoutKey),
+				Groups:          []store.WorkoutEntryGroup{},
+				Entries:         []store.WorkoutEntry{},
+			}
+			byKey[row.WorkoutKey] = workout
+			supersets[workout] = map[string]int{}
+			workouts = append(workouts, workout)
+		}
+
+		addRow(workout, row, supersets[workout])
+	}
+
+	for _, workout := range workouts {
+		finishWorkout(workout)
+	}
+	return workouts
+}
+
+func externalID(format, key string) string {
+	sum := sha256.Sum256([]byte(format + "\x00" + key))
+	return format + ":" + hex.EncodeToString(sum[:12])
+}
+
+// addRow appends the row to the last entry when it continues it, or starts
+// a new entry.
+func addRow(workout *store.Workout, row Row, supersets map[string]int) {
+	cardio := (row.Cardio && (row.Seconds != nil || row.Distance != nil)) || row.Distance != nil
+	timed := !cardio && row.Reps == nil
+
+	var entry *store.WorkoutEntry
+	if n := len(workout.Entries); n > 0 {
+		last := &workout.Entries[n-1]
+		lastTimed := len(last.SetDetails) > 0 && last.SetDetails[0].DurationSeconds != nil
+		if strings.EqualFold(last.ExerciseName, row.Exercise) &&
+			(last.Kind == store.EntryKindCardio) == cardio && lastTimed == timed {
+			entry = last
+		}
+	}
+
+	if entry == nil {
+		kind := store.EntryKindStrength
+		if cardio {
+			kind = store.EntryKindCardio
+		}
+		workout.Entries = append(workout.Entries, store.WorkoutEntry{
+			ExerciseName: row.Exercise,
+			Kind:         kind,
+			Notes:        row.Notes,
+			OrderIndex:   len(workout.Entries) + 1,
+		})
+		entry = &workout.Entries[len(workout.Entries)-1]
+
+		if row.SupersetID != "" {
+			index, ok := supersets[row.SupersetID]
+			if !ok {
+				index = len(supersets) + 1
+				supersets[row.SupersetID] = index
+			}
+			entry.GroupIndex = &index
+		}
+	} else if row.Notes != "" && !strings.Contains(entry.Notes, row.Notes) {
+		entry.Notes = strings.TrimSpace(entry.Notes + "\n" + row.Notes)
+	}
+
+	if cardio {
+		addCardio(entry, row)
+		return
+	}
+
+	set := store.WorkoutSet{Type: row.SetType, Reps: row.Reps, Weight: row.Weight, RPE: row.RPE}
+	if timed {
+		set.DurationSeconds = row.Seconds
+	}
+	entry.SetDetails = append(entry.SetDetails, set)
+}
+
+// addCardio adds a cardio row's duration and distance to the entry, keeping
+// the distance in the unit the entry started with.
+func addCardio(entry *store.WorkoutEntry, row Row) {
+	if row.Seconds != nil {
+		total := *row.Seconds
+		if entry.DurationSeconds != nil {
+			total += *entry.DurationSeconds
+		}
+		entry.DurationSeconds = &total
+	}
+
+	meters := store.WorkoutEntry{Distance: row.Distance, DistanceUnit: row.DistanceUnit}.DistanceMeters()
+	if meters == nil || *meters <= 0 {
+		return
+	}
+	if entry.Distance == nil {
+		entry.DistanceUnit = row.DistanceUnit
+		entry.Distance = new(float64)
+	}
+	*entry.Distance += *meters / store.DistanceUnits[entry.DistanceUnit]
+}
+
+// finishWorkout turns superset IDs shared by at least two entries into
+// groups and drops the rest.
+func finishWorkout(workout *store.Workout) {
+	counts := map[int]int{}
+	for _, entry := range workout.Entries {
+		if entry.GroupIndex != nil {
+			counts[*entry.GroupIndex]++
+		}
+	}
+
+	renumbered := map[int]int{}
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+		if entry.Distance != nil {
+			rounded := math.Round(*entry.Distance*1000) / 1000
+			entry.Distance = &rounded
+		}
+		if entry.GroupIndex == nil {
+			continue
+		}
+		if counts[*entry.GroupIndex] < 2 {
+			entry.GroupIndex = nil
+			continue
+		}
+
+		index, ok := renumbered[*entry.GroupIndex]
+		if !ok {
+			index = len(renumbered) + 1
+			renumbered[*entry.GroupIndex] = index
+			workout.Groups = append(workout.Groups, store.WorkoutEntryGroup{GroupIndex: index, Type: store.GroupSuperset})
+		}
+		entry.GroupIndex = &index
+	}
+}
+
+// Record is a data row with its fields addressed by header name.
+type Record struct {
+	header []string
+	fields []string
+}
+
+// Get returns the trimmed value of the first of the named columns present.
+func (r Record) Get(names ...string) string {
+	for _, name := range names {
+		for i, column := range r.header {
+			if column == name && i < len(r.fields) {
+				return strings.TrimSpace(r.fields[i])
+			}
+		}
+	}
+	return ""
+}
+
+func (r Record) has(name string) bool {
+	for _, column := range r.header {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Float reads a decimal column, accepting a decimal comma. Empty values are
+// nil.
+func (r Record) Float(names ...string) (*float64, error) {
+	raw := strings.Replace(r.Get(names...), ",", ".", 1)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative number", names[0])
+	}
+	return &value, nil
+}
+
+// Int reads a whole-number column; a decimal part of zero is accepted.
+func (r Record) Int(names ...string) (*int, error) {
+	value, err := r.Float(names...)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if *value != float64(int(*value)) {
+		return nil, fmt.Errorf("%s must be a whole number", names[0])
+	}
+	n := int(*value)
+	return &n, nil
+}
+
+// headerHas reports whether every column is in header.
+func headerHas(header []string, columns ...string) bool {
+	record := Record{header: header}
+	for _, column := range columns {
+		if !record.has(column) {
+			return false
+		}
+	}
+	return true
+}
+
+// positive drops zero values, which the exports write for unused columns.
+func positive[T int | float64](value *T) *T {
+	if value == nil || *value <= 0 {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,173 @@
+package csvimport
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleStrong = `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+2026-02-01 18:00:00,Push,1h 5m,Bench Press,W,40,10,0,0,,Felt good,
+2026-02-01 18:00:00,Push,1h 5m,Bench Press,1,80,5,0,0,Paused,Felt good,8
+2026-02-01 18:00:00,Push,1h 5m,Bench Press,2,80,5,0,0,,Felt good,8.5
+2026-02-01 18:00:00,Push,1h 5m,Rest Timer,Rest Timer,0,0,0,90,,Felt good,
+2026-02-01 18:00:00,Push,1h 5m,Plank,1,0,0,0,60,,Felt good,
+2026-02-01 18:00:00,Push,1h 5m,Rowing (Machine),1,0,0,2,600,,Felt good,
+2026-02-03 07:30:00,Legs,45m,Squat,1,100,abc,0,0,,,
+2026-02-03 07:30:00,Legs,45m,Squat,2,100,5,0,0,,,
+`
+
+const sampleHevy = `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_kg","reps","distance_km","duration_seconds","rpe"
+"Upper","1 Feb 2026, 18:00","1 Feb 2026, 19:00","","Bench Press (Barbell)","0","","0","warmup","40","10","","",""
+"Upper","1 Feb 2026, 18:00","1 Feb 2026, 19:00","","Bench Press (Barbell)","0","","1","normal","80","5","","","8"
+"Upper","1 Feb 2026, 18:00","1 Feb 2026, 19:00","","Bent Over Row (Barbell)","0","","0","normal","70","8","","",""
+"Upper","1 Feb 2026, 18:00","1 Feb 2026, 19:00","","Bicep Curl (Dumbbell)","1","","0","dropset","15","12","","",""
+"Upper","1 Feb 2026, 18:00","1 Feb 2026, 19:00","","Running","","","0","normal","","","3.5","1200",""
+`
+
+const sampleFitNotes = `Date;Exercise;Category;Weight (kgs);Reps;Distance;Distance Unit;Time;Comment
+2026-02-05;Deadlift;Back;140,5;3;;;;
+2026-02-05;Deadlift;Back;140,5;3;;;;
+2026-02-05;Cycling;Cardio;;;10;km;0:30:00;
+2026-02-05;Cycling;Cardio;;;5000;m;0:15:00;
+2026-02-06;Plank;Abs;;;;;0:01:00;
+`
+
+func TestParseStrong(t *testing.T) {
+	result, err := Parse(strings.NewReader(sampleStrong), "", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "strong", result.Format)
+	assert.Equal(t, []RowError{{Line: 8, Message: "reps must be a non-negative number"}}, result.Errors)
+	require.Len(t, result.Workouts, 2)
+
+	push := result.Workouts[0]
+	assert.Equal(t, "Push", push.Title)
+	assert.Equal(t, "Felt good", push.Description)
+	assert.Equal(t, 65, push.DurationMinutes)
+	assert.True(t, push.CreatedAt.Equal(time.Date(2026, 2, 1, 18, 0, 0, 0, time.UTC)))
+	require.Len(t, push.Entries, 3)
+
+	bench := push.Entries[0]
+	assert.Equal(t, "Bench Press", bench.ExerciseName)
+	assert.Equal(t, "Paused", bench.Notes)
+	require.Len(t, bench.SetDetails, 3)
+	assert.Equal(t, store.SetTypeWarmup, bench.SetDetails[0].Type)
+	assert.Equal(t, store.SetTypeWorking, bench.SetDetails[1].Type)
+	assert.Equal(t, 8.5, *bench.SetDetails[2].RPE)
+
+	plank := push.Entries[1]
+	require.Len(t, plank.SetDetails, 1)
+	assert.Nil(t, plank.SetDetails[0].Reps)
+	assert.Equal(t, 60, *plank.SetDetails[0].DurationSeconds)
+
+	rowing := push.Entries[2]
+	assert.Equal(t, store.EntryKindCardio, rowing.Kind)
+	assert.Equal(t, 2.0, *rowing.Distance)
+	assert.Equal(t, "km", rowing.DistanceUnit)
+	assert.Equal(t, 600, *rowing.DurationSeconds)
+
+	legs := result.Workouts[1]
+	require.Len(t, legs.Entries, 1)
+	assert.Len(t, legs.Entries[0].SetDetails, 1)
+}
+
+func TestParseHevy(t *testing.T) {
+	result, err := Parse(strings.NewReader(sampleHevy), "", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "hevy", result.Format)
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Workouts, 1)
+
+	workout := result.Workouts[0]
+	assert.Equal(t, 60, workout.DurationMinutes)
+	require.Len(t, workout.Entries, 4)
+
+	// superset 0 has two exercises; superset 1 only one, so it's dropped
+	assert.Equal(t, []store.WorkoutEntryGroup{{GroupIndex: 1, Type: store.GroupSuperset}}, workout.Groups)
+	assert.Equal(t, 1, *workout.Entries[0].GroupIndex)
+	assert.Equal(t, 1, *workout.Entries[1].GroupIndex)
+	assert.Nil(t, workout.Entries[2].GroupIndex)
+	assert.Equal(t, store.SetTypeDrop, workout.Entries[2].SetDetails[0].Type)
+
+	running := workout.Entries[3]
+	assert.Equal(t, store.EntryKindCardio, running.Kind)
+	assert.Equal(t, 3.5, *running.Distance)
+	assert.Equal(t, 1200, *running.DurationSeconds)
+}
+
+func TestParseFitNotes(t *testing.T) {
+	result, err := Parse(strings.NewReader(sampleFitNotes), "", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "fitnotes", result.Format)
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Workouts, 2)
+
+	workout := result.Workouts[0]
+	require.Len(t, workout.Entries, 2)
+	require.Len(t, workout.Entries[0].SetDetails, 2)
+	assert.Equal(t, 140.5, *workout.Entries[0].SetDetails[0].Weight)
+
+	// rows in different units add up in the entry's first unit
+	cycling := workout.Entries[1]
+	assert.Equal(t, store.EntryKindCardio, cycling.Kind)
+	assert.Equal(t, 15.0, *cycling.Distance)
+	assert.Equal(t, "km", cycling.DistanceUnit)
+	assert.Equal(t, 2700, *cycling.DurationSeconds)
+
+	plank := result.Workouts[1].Entries[0]
+	assert.Equal(t, store.EntryKindStrength, plank.Kind)
+	assert.Equal(t, 60, *plank.SetDetails[0].DurationSeconds)
+}
+
+func TestParseExternalIDs(t *testing.T) {
+	first, err := Parse(strings.NewReader(sampleStrong), "", Options{})
+	require.NoError(t, err)
+	second, err := Parse(strings.NewReader(sampleStrong), "", Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, first.Workouts[0].ExternalID, second.Workouts[0].ExternalID)
+	assert.NotEqual(t, first.Workouts[0].ExternalID, first.Workouts[1].ExternalID)
+	assert.True(t, strings.HasPrefix(first.Workouts[0].ExternalID, "strong:"))
+}
+
+func TestParseOptions(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	result, err := Parse(strings.NewReader(sampleStrong), "", Options{Location: berlin, DistanceUnit: "mi"})
+	require.NoError(t, err)
+
+	push := result.Workouts[0]
+	assert.True(t, push.CreatedAt.Equal(time.Date(2026, 2, 1, 17, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "mi", push.Entries[2].DistanceUnit)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		format string
+		err    string
+	}{
+		{name: "empty", input: "", err: ErrEmpty.Error()},
+		{name: "unknown header", input: "a,b,c\n1,2,3\n", err: ErrUnknownFormat.Error()},
+		{name: "unknown format", input: sampleStrong, format: "jefit", err: `unknown format "jefit"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input), tt.format, Options{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	// an explicit format skips detection
+	result, err := Parse(strings.NewReader("Date,Exercise,Reps\n2026-02-05,Squat,5\n"), "fitnotes", Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Workouts, 1)
+}
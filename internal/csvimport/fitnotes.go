@@ -0,0 +1,104 @@
+package csvimport
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ruhan/internal/store"
+)
+
+// fitNotesFormat reads FitNotes' "Export Workouts" CSV:
+//
+//	Date,Exercise,Category,Weight (kgs),Reps,Distance,Distance Unit,Time,Comment
+//
+// FitNotes has no workout names or start times, so each day is one workout.
+type fitNotesFormat struct{}
+
+func (fitNotesFormat) Name() string { return "fitnotes" }
+
+func (fitNotesFormat) Matches(header []string) bool {
+	return headerHas(header, "date", "exercise", "category", "reps")
+}
+
+var fitNotesDistanceUnits = map[string]string{
+	"m":      "m",
+	"km":     "km",
+	"mi":     "mi",
+	"mile":   "mi",
+	"miles":  "mi",
+	"metres": "m",
+	"meters": "m",
+}
+
+func (fitNotesFormat) ParseRow(record Record, options Options) (Row, bool, error) {
+	date := record.Get("date")
+	day, err := time.ParseInLocation(time.DateOnly, date, options.Location)
+	if err != nil {
+		return Row{}, false, fmt.Errorf("date must look like 2006-01-02")
+	}
+
+	row := Row{
+		WorkoutKey:  date,
+		WorkoutName: "Workout",
+		Start:       day,
+		Exercise:    record.Get("exercise"),
+		SetType:     store.SetTypeWorking,
+		Notes:       record.Get("comment"),
+		Cardio:      strings.EqualFold(record.Get("category"), "cardio"),
+	}
+	if row.Exercise == "" {
+		return Row{}, false, fmt.Errorf("exercise is empty")
+	}
+
+	if row.Weight, err = record.Float("weight (kgs)", "weight (lbs)", "weight"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Reps, err = record.Int("reps"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Distance, err = record.Float("distance"); err != nil {
+		return Row{}, false, err
+	}
+
+	row.DistanceUnit = options.DistanceUnit
+	if raw := strings.ToLower(record.Get("distance unit")); raw != "" {
+		unit, ok := fitNotesDistanceUnits[raw]
+		if !ok {
+			return Row{}, false, fmt.Errorf("distance unit %q is not m, km or mi", raw)
+		}
+		row.DistanceUnit = unit
+	}
+
+	if raw := record.Get("time"); raw != "" {
+		seconds, err := parseClock(raw)
+		if err != nil {
+			return Row{}, false, err
+		}
+		row.Seconds = &seconds
+	}
+
+	row.Reps, row.Seconds, row.Distance = positive(row.Reps), positive(row.Seconds), positive(row.Distance)
+	if row.Reps == nil {
+		row.Weight = positive(row.Weight)
+	}
+	return row, true, nil
+}
+
+// parseClock reads h:mm:ss or mm:ss into seconds.
+func parseClock(raw string) (int, error) {
+	seconds := 0
+	parts := strings.Split(raw, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("time %q must look like 0:01:30", raw)
+	}
+	for _, part := range parts {
+		var value int
+		_, err := fmt.Sscanf(part, "%d", &value)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("time %q must look like 0:01:30", raw)
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, nil
+}
@@ -0,0 +1,111 @@
+package csvimport
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ruhan/internal/store"
+)
+
+// hevyFormat reads Hevy's "Export Workouts" CSV:
+//
+//	title,start_time,end_time,description,exercise_title,superset_id,exercise_notes,set_index,set_type,weight_kg,reps,distance_km,duration_seconds,rpe
+//
+// Accounts set to imperial units export weight_lbs and distance_miles
+// instead. Exercises sharing a superset_id become a superset.
+type hevyFormat struct{}
+
+func (hevyFormat) Name() string { return "hevy" }
+
+func (hevyFormat) Matches(header []string) bool {
+	return headerHas(header, "title", "start_time", "exercise_title", "set_index")
+}
+
+var hevySetTypes = map[string]string{
+	"normal":  store.SetTypeWorking,
+	"warmup":  store.SetTypeWarmup,
+	"dropset": store.SetTypeDrop,
+	"failure": store.SetTypeFailure,
+}
+
+// hevyTimeLayouts are the start_time formats Hevy has used.
+var hevyTimeLayouts = []string{"2 Jan 2006, 15:04", "2006-01-02 15:04:05", time.RFC3339}
+
+func parseHevyTime(raw string, location *time.Location) (time.Time, error) {
+	for _, layout := range hevyTimeLayouts {
+		t, err := time.ParseInLocation(layout, raw, location)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("time %q must look like 2 Jan 2006, 15:04", raw)
+}
+
+func (hevyFormat) ParseRow(record Record, options Options) (Row, bool, error) {
+	start, err := parseHevyTime(record.Get("start_time"), options.Location)
+	if err != nil {
+		return Row{}, false, err
+	}
+
+	row := Row{
+		WorkoutKey:   record.Get("start_time") + "\x00" + record.Get("title"),
+		WorkoutName:  record.Get("title"),
+		Start:        start,
+		WorkoutNotes: record.Get("description"),
+		Exercise:     record.Get("exercise_title"),
+		SetType:      store.SetTypeWorking,
+		Notes:        record.Get("exercise_notes"),
+		SupersetID:   record.Get("superset_id"),
+	}
+	if row.Exercise == "" {
+		return Row{}, false, fmt.Errorf("exercise_title is empty")
+	}
+
+	if raw := record.Get("end_time"); raw != "" {
+		end, err := parseHevyTime(raw, options.Location)
+		if err != nil {
+			return Row{}, false, err
+		}
+		if end.After(start) {
+			row.DurationMinutes = int(end.Sub(start).Round(time.Minute).Minutes())
+		}
+	}
+
+	if raw := record.Get("set_type"); raw != "" {
+		setType, ok := hevySetTypes[raw]
+		if !ok {
+			return Row{}, false, fmt.Errorf("set_type %q is not normal, warmup, dropset or failure", raw)
+		}
+		row.SetType = setType
+	}
+
+	if row.Weight, err = record.Float("weight_kg", "weight_lbs", "weight"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Reps, err = record.Int("reps"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Seconds, err = record.Int("duration_seconds"); err != nil {
+		return Row{}, false, err
+	}
+	if row.RPE, err = record.Float("rpe"); err != nil {
+		return Row{}, false, err
+	}
+
+	row.DistanceUnit = "km"
+	if row.Distance, err = record.Float("distance_km"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Distance == nil {
+		row.DistanceUnit = "mi"
+		if row.Distance, err = record.Float("distance_miles"); err != nil {
+			return Row{}, false, err
+		}
+	}
+
+	row.Reps, row.Seconds, row.Distance = positive(row.Reps), positive(row.Seconds), positive(row.Distance)
+	if row.Reps == nil {
+		row.Weight = positive(row.Weight)
+	}
+	return row, true, nil
+}
@@ -0,0 +1,104 @@
+package csvimport
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruhan/internal/store"
+)
+
+// strongFormat reads Strong's "Export Data" CSV:
+//
+//	Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+//
+// Distances are in the unit set in the app, which the file doesn't record.
+type strongFormat struct{}
+
+func (strongFormat) Name() string { return "strong" }
+
+func (strongFormat) Matches(header []string) bool {
+	return headerHas(header, "date", "workout name", "exercise name", "set order")
+}
+
+var strongSetTypes = map[string]string{
+	"w": store.SetTypeWarmup,
+	"d": store.SetTypeDrop,
+	"f": store.SetTypeFailure,
+}
+
+func (strongFormat) ParseRow(record Record, options Options) (Row, bool, error) {
+	setOrder := strings.ToLower(record.Get("set order"))
+	if setOrder == "rest timer" || setOrder == "note" {
+		return Row{}, false, nil
+	}
+
+	start, err := time.ParseInLocation(time.DateTime, record.Get("date"), options.Location)
+	if err != nil {
+		return Row{}, false, fmt.Errorf("date must look like 2006-01-02 15:04:05")
+	}
+
+	row := Row{
+		WorkoutName:     record.Get("workout name"),
+		Start:           start,
+		DurationMinutes: parseStrongDuration(record.Get("duration")),
+		WorkoutNotes:    record.Get("workout notes"),
+		Exercise:        record.Get("exercise name"),
+		SetType:         store.SetTypeWorking,
+		DistanceUnit:    options.DistanceUnit,
+		Notes:           record.Get("notes"),
+	}
+	row.WorkoutKey = record.Get("date") + "\x00" + row.WorkoutName
+	if row.Exercise == "" {
+		return Row{}, false, fmt.Errorf("exercise name is empty")
+	}
+
+	if setType, ok := strongSetTypes[setOrder]; ok {
+		row.SetType = setType
+	} else if _, err := strconv.Atoi(setOrder); err != nil {
+		return Row{}, false, fmt.Errorf("set order %q is not a number, W, D or F", setOrder)
+	}
+
+	if row.Weight, err = record.Float("weight"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Reps, err = record.Int("reps"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Seconds, err = record.Int("seconds"); err != nil {
+		return Row{}, false, err
+	}
+	if row.Distance, err = record.Float("distance"); err != nil {
+		return Row{}, false, err
+	}
+	if row.RPE, err = record.Float("rpe"); err != nil {
+		return Row{}, false, err
+	}
+
+	row.Reps, row.Seconds, row.Distance = positive(row.Reps), positive(row.Seconds), positive(row.Distance)
+	if row.Reps == nil {
+		row.Weight = positive(row.Weight)
+	}
+	return row, true, nil
+}
+
+var strongDurationPart = regexp.MustCompile(`(\d+)\s*([hms])`)
+
+// parseStrongDuration reads durations like "1h 5m" or "45m" into minutes.
+func parseStrongDuration(raw string) int {
+	seconds := 0
+	for _, match := range strongDurationPart.FindAllStringSubmatch(raw, -1) {
+		value, _ := strconv.Atoi(match[1])
+		switch match[2] {
+		case "h":
+			seconds += value * 3600
+		case "m":
+			seconds += value * 60
+		case "s":
+			seconds += value
+		}
+	}
+	return (seconds + 30) / 60
+}
@@ -0,0 +1,95 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+
+	"github.com/ruhan/internal/store"
+)
+
+// Archive is everything stored about a user, as written to a personal data
+// archive.
+type Archive struct {
+	User        *store.User
+	Tokens      []*store.TokenMetadata
+	AuditEvents []*store.AuditEvent
+	// Workouts calls fn with each of the user's workouts, the way
+	// WorkoutStore.ExportWorkouts does. It is called once per file the
+	// workouts are written to.
+	Workouts func(fn func(*store.Workout) error) error
+}
+
+const archiveReadme = `This archive holds the data stored about your account.
+
+profile.json       your profile and settings
+workouts.json      your workouts with their entries and sets; it can be
+                   imported again with POST /workouts/import
+workouts.csv       the same workouts, one row per set
+tokens.json        the scope and expiry of your active tokens; the tokens
+                   themselves are only stored hashed and are not included
+audit_events.json  logins and other security-relevant account events
+`
+
+// WriteArchive writes the archive as a ZIP file.
+func WriteArchive(w io.Writer, meta Meta, archive Archive) error {
+	zw := zip.NewWriter(w)
+
+	err := writeArchiveFile(zw, meta, "README.txt", func(w io.Writer) error {
+		_, err := io.WriteString(w, archiveReadme)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	jsonFiles := []struct {
+		name  string
+		value any
+	}{
+		{"profile.json", archive.User},
+		{"tokens.json", archive.Tokens},
+		{"audit_events.json", archive.AuditEvents},
+	}
+	for _, file := range jsonFiles {
+		err = writeArchiveFile(zw, meta, file.name, func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(file.value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		err = writeArchiveFile(zw, meta, "workouts."+format, func(w io.Writer) error {
+			writer, err := NewWriter(format, w, meta)
+			if err != nil {
+				return err
+			}
+			err = archive.Workouts(writer.WriteWorkout)
+			if err != nil {
+				return err
+			}
+			return writer.Close()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeArchiveFile(zw *zip.Writer, meta Meta, name string, write func(io.Writer) error) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: meta.ExportedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return write(w)
+}
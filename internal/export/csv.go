@@ -0,0 +1,107 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ruhan/internal/store"
+)
+
+// csvHeader lists the columns of a CSV export, which has one row per set.
+// Cardio entries, which have no set log, get a single row.
+var csvHeader = []string{
+	"date", "workout_id", "workout", "workout_notes", "duration_minutes", "calories_burned",
+	"exercise", "kind", "group_index", "group_type", "entry_notes",
+	"set_index", "set_type", "reps", "weight", "duration_seconds", "rpe", "rir", "completed",
+	"distance", "distance_unit", "elevation_gain_meters", "avg_heart_rate", "max_heart_rate", "avg_cadence",
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	meta Meta
+}
+
+func newCSVWriter(w io.Writer, meta Meta) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), meta: meta}
+	err := cw.w.Write(csvHeader)
+	if err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteWorkout(workout *store.Workout) error {
+	groupTypes := map[int]string{}
+	for _, group := range workout.Groups {
+		groupTypes[group.GroupIndex] = group.Type
+	}
+
+	workoutColumns := []string{
+		workout.CreatedAt.In(cw.meta.Location).Format(time.RFC3339),
+		strconv.Itoa(workout.ID),
+		workout.Title,
+		workout.Description,
+		strconv.Itoa(workout.DurationMinutes),
+		strconv.Itoa(workout.CaloriesBurned),
+	}
+
+	for _, entry := range workout.Entries {
+		entryColumns := []string{entry.ExerciseName, entry.Kind, "", "", entry.Notes}
+		if entry.GroupIndex != nil {
+			entryColumns[2] = strconv.Itoa(*entry.GroupIndex)
+			entryColumns[3] = groupTypes[*entry.GroupIndex]
+		}
+		cardioColumns := []string{
+			floatColumn(entry.Distance), entry.DistanceUnit, floatColumn(entry.ElevationGainMeters),
+			intColumn(entry.AvgHeartRate), intColumn(entry.MaxHeartRate), intColumn(entry.AvgCadence),
+		}
+
+		sets := entry.SetDetails
+		if len(sets) == 0 {
+			sets = []store.WorkoutSet{{Reps: entry.Reps, Weight: entry.Weight, DurationSeconds: entry.DurationSeconds}}
+		}
+		for _, set := range sets {
+			setColumns := []string{
+				"", set.Type, intColumn(set.Reps), floatColumn(set.Weight), intColumn(set.DurationSeconds),
+				floatColumn(set.RPE), intColumn(set.RIR), "",
+			}
+			if set.SetIndex > 0 {
+				setColumns[0] = strconv.Itoa(set.SetIndex)
+			}
+			if set.Completed != nil {
+				setColumns[7] = strconv.FormatBool(*set.Completed)
+			}
+
+			record := append(append(append(append([]string{}, workoutColumns...), entryColumns...), setColumns...), cardioColumns...)
+			err := cw.w.Write(record)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// flush per workout so the export streams
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func intColumn(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func floatColumn(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return formatFloat(*value)
+}
@@ -0,0 +1,69 @@
+// Package export writes a user's workouts as CSV, JSON or a Markdown
+// training journal. Writers take one workout at a time, so an export can be
+// streamed while the workouts are still being read. The JSON form keeps
+// everything needed to import the workouts again; see ReadJSON.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ruhan/internal/store"
+)
+
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatMarkdown = "md"
+)
+
+// Meta describes the export as a whole.
+type Meta struct {
+	UserName   string
+	ExportedAt time.Time
+	// Location is the time zone dates are written in.
+	Location *time.Location
+}
+
+// Writer writes workouts one by one. Close finishes the document and must
+// be called even when there were no workouts.
+type Writer interface {
+	WriteWorkout(workout *store.Workout) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format that writes to w.
+func NewWriter(format string, w io.Writer, meta Meta) (Writer, error) {
+	if meta.Location == nil {
+		meta.Location = time.UTC
+	}
+
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, meta)
+	case FormatJSON:
+		return newJSONWriter(w, meta)
+	case FormatMarkdown:
+		return newMarkdownWriter(w, meta)
+	default:
+		return nil, fmt.Errorf("unknown export format %q; supported formats are csv, json and md", format)
+	}
+}
+
+// ContentType returns the MIME type of format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,235 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(i int) *int           { return &i }
+func floatPtr(f float64) *float64 { return &f }
+func boolPtr(b bool) *bool        { return &b }
+
+func sampleWorkouts() []*store.Workout {
+	return []*store.Workout{
+		{
+			ID: 7, UserID: 1, Title: "Push", Description: "Felt good", DurationMinutes: 65, CaloriesBurned: 450,
+			CreatedAt: time.Date(2026, 2, 1, 17, 0, 0, 0, time.UTC),
+			Groups:    []store.WorkoutEntryGroup{{ID: 3, GroupIndex: 1, Type: store.GroupSuperset}},
+			Entries: []store.WorkoutEntry{
+				{
+					ID: 11, ExerciseID: intPtr(2), ExerciseName: "Bench Press", Kind: store.EntryKindStrength,
+					Sets: 1, Reps: intPtr(5), Weight: floatPtr(80), OrderIndex: 1, GroupIndex: intPtr(1), Notes: "Paused",
+					SetDetails: []store.WorkoutSet{
+						{ID: 21, SetIndex: 1, Type: store.SetTypeWarmup, Reps: intPtr(10), Weight: floatPtr(40), Completed: boolPtr(true)},
+						{ID: 22, SetIndex: 2, Type: store.SetTypeWorking, Reps: intPtr(5), Weight: floatPtr(80), RPE: floatPtr(8), Completed: boolPtr(true)},
+					},
+					PersonalRecords: []string{store.RecordMaxWeight},
+				},
+				{
+					ID: 12, ExerciseName: "Plank", Kind: store.EntryKindStrength, Sets: 1, DurationSeconds: intPtr(60),
+					OrderIndex: 2, GroupIndex: intPtr(1),
+					SetDetails: []store.WorkoutSet{
+						{ID: 23, SetIndex: 1, Type: store.SetTypeWorking, DurationSeconds: intPtr(60), Completed: boolPtr(false)},
+					},
+				},
+			},
+		},
+		{
+			ID: 8, UserID: 1, Title: "Easy run", DurationMinutes: 30, ExternalID: "strong:abc",
+			CreatedAt: time.Date(2026, 2, 2, 6, 30, 0, 0, time.UTC),
+			Groups:    []store.WorkoutEntryGroup{},
+			Entries: []store.WorkoutEntry{
+				{
+					ID: 13, ExerciseName: "Running", Kind: store.EntryKindCardio, Sets: 1, OrderIndex: 1,
+					DurationSeconds: intPtr(1800), Distance: floatPtr(6), DistanceUnit: "km", AvgHeartRate: intPtr(142),
+				},
+			},
+		},
+	}
+}
+
+func writeAll(t *testing.T, format string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	writer, err := NewWriter(format, &buf, Meta{
+		UserName:   "alice",
+		ExportedAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Location:   berlin,
+	})
+	require.NoError(t, err)
+	for _, workout := range sampleWorkouts() {
+		require.NoError(t, writer.WriteWorkout(workout))
+	}
+	require.NoError(t, writer.Close())
+	return buf.String()
+}
+
+func TestWriteCSV(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(writeAll(t, FormatCSV))).ReadAll()
+	require.NoError(t, err)
+
+	// header, three sets and one cardio row
+	require.Len(t, records, 5)
+	assert.Equal(t, csvHeader, records[0])
+
+	row := map[string]string{}
+	for i, column := range csvHeader {
+		row[column] = records[2][i]
+	}
+	assert.Equal(t, "2026-02-01T18:00:00+01:00", row["date"])
+	assert.Equal(t, "Bench Press", row["exercise"])
+	assert.Equal(t, "superset", row["group_type"])
+	assert.Equal(t, "working", row["set_type"])
+	assert.Equal(t, "80", row["weight"])
+	assert.Equal(t, "8", row["rpe"])
+
+	cardio := map[string]string{}
+	for i, column := range csvHeader {
+		cardio[column] = records[4][i]
+	}
+	assert.Equal(t, "6", cardio["distance"])
+	assert.Equal(t, "km", cardio["distance_unit"])
+	assert.Equal(t, "1800", cardio["duration_seconds"])
+	assert.Equal(t, "", cardio["set_index"])
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	journal := writeAll(t, FormatMarkdown)
+
+	for _, want := range []string{
+		"# Training journal\n\nalice · exported 18 October 2026\n",
+		"## Sunday, 1 February 2026 · Push\n\n18:00 · 65 min · 450 kcal\n\nFelt good\n",
+		"**Bench Press** (superset 1)\n\n- warm-up: 10 × 40\n- 5 × 80 @ RPE 8\n",
+		"New record: heaviest weight.\n\nPaused\n",
+		"- 1:00 (missed)\n",
+		"- 6 km in 30:00 · 5:00 /km · avg HR 142\n",
+	} {
+		assert.Contains(t, journal, want)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	workouts, err := ReadJSON(strings.NewReader(writeAll(t, FormatJSON)))
+	require.NoError(t, err)
+	require.Len(t, workouts, 2)
+
+	push := workouts[0]
+	assert.Equal(t, 0, push.ID)
+	assert.Equal(t, "Push", push.Title)
+	assert.Equal(t, "Felt good", push.Description)
+	assert.True(t, push.CreatedAt.Equal(time.Date(2026, 2, 1, 17, 0, 0, 0, time.UTC)))
+	// workouts logged in the app are exported under their own ID
+	assert.Equal(t, "workout:7", push.ExternalID)
+	assert.Equal(t, []store.WorkoutEntryGroup{{GroupIndex: 1, Type: store.GroupSuperset}}, push.Groups)
+
+	bench := push.Entries[0]
+	assert.Equal(t, 0, bench.ID)
+	assert.Nil(t, bench.ExerciseID)
+	assert.Nil(t, bench.PersonalRecords)
+	require.Len(t, bench.SetDetails, 2)
+	assert.Equal(t, 0, bench.SetDetails[0].ID)
+	assert.Equal(t, store.SetTypeWarmup, bench.SetDetails[0].Type)
+	assert.Equal(t, 8.0, *bench.SetDetails[1].RPE)
+	assert.False(t, *push.Entries[1].SetDetails[0].Completed)
+
+	// imported workouts keep the ID they were imported under
+	run := workouts[1]
+	assert.Equal(t, "strong:abc", run.ExternalID)
+	assert.Equal(t, 6.0, *run.Entries[0].Distance)
+
+	again, err := ReadJSON(strings.NewReader(writeAll(t, FormatJSON)))
+	require.NoError(t, err)
+	assert.Equal(t, push.ExternalID, again[0].ExternalID)
+
+	// exports written before external IDs were always included still
+	// map back to the original workout
+	older, err := ReadJSON(strings.NewReader(`{"version": 1, "workouts": [
+		{"id": 7, "title": "Push", "created_at": "2026-02-01T17:00:00Z"},
+		{"title": "Hand written", "created_at": "2026-02-02T17:00:00Z"}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "workout:7", older[0].ExternalID)
+	assert.True(t, strings.HasPrefix(older[1].ExternalID, "json:"))
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "not json", input: "Date,Workout Name", err: "invalid JSON export"},
+		{name: "unknown version", input: `{"version": 2, "workouts": []}`, err: "unsupported JSON export version 2"},
+		{name: "no date", input: `{"version": 1, "workouts": [{"title": "Push"}]}`, err: "workout 1 has no created_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadJSON(strings.NewReader(tt.input))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	_, err := NewWriter("xlsx", &bytes.Buffer{}, Meta{})
+	assert.Error(t, err)
+}
+
+func TestWriteArchive(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteArchive(&buf, Meta{UserName: "alice", ExportedAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}, Archive{
+		User:        &store.User{ID: 1, UserName: "alice", Email: "alice@example.com"},
+		Tokens:      []*store.TokenMetadata{{Scope: "authentication", Expiry: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}},
+		AuditEvents: []*store.AuditEvent{{ID: 1, UserID: 1, Event: store.AuditLogin}},
+		Workouts: func(fn func(*store.Workout) error) error {
+			for _, workout := range sampleWorkouts() {
+				if err := fn(workout); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	files := map[string]*zip.File{}
+	names := []string{}
+	for _, file := range zr.File {
+		files[file.Name] = file
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"README.txt", "profile.json", "tokens.json", "audit_events.json", "workouts.json", "workouts.csv"}, names)
+
+	profile, err := files["profile.json"].Open()
+	require.NoError(t, err)
+	defer profile.Close()
+	var user map[string]any
+	require.NoError(t, json.NewDecoder(profile).Decode(&user))
+	assert.Equal(t, "alice@example.com", user["email"])
+	assert.NotContains(t, user, "password_hash")
+
+	workoutsFile, err := files["workouts.json"].Open()
+	require.NoError(t, err)
+	defer workoutsFile.Close()
+	workouts, err := ReadJSON(workoutsFile)
+	require.NoError(t, err)
+	assert.Len(t, workouts, 2)
+}
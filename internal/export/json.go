@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ruhan/internal/store"
+)
+
+// jsonVersion is the version of the JSON export document. Bump it when a
+// change would stop ReadJSON from importing older exports.
+const jsonVersion = 1
+
+// jsonDocument is the JSON export. The writer streams it by hand, so its
+// field order is fixed by jsonWriter, not by this struct.
+type jsonDocument struct {
+	Version    int              `json:"version"`
+	UserName   string           `json:"username"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Workouts   []*store.Workout `json:"workouts"`
+}
+
+type jsonWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newJSONWriter(w io.Writer, meta Meta) (*jsonWriter, error) {
+	userName, err := json.Marshal(meta.UserName)
+	if err != nil {
+		return nil, err
+	}
+	exportedAt, err := json.Marshal(meta.ExportedAt.In(meta.Location))
+	if err != nil {
+		return nil, err
+	}
+
+	jw := &jsonWriter{w: bufio.NewWriter(w)}
+	fmt.Fprintf(jw.w, `{"version":%d,"username":%s,"exported_at":%s,"workouts":[`, jsonVersion, userName, exportedAt)
+	return jw, nil
+}
+
+func (jw *jsonWriter) WriteWorkout(workout *store.Workout) error {
+	// every workout carries an external ID, so an import of the export can
+	// tell which workouts it already has
+	if workout.ExternalID == "" {
+		exported := *workout
+		exported.ExternalID = store.WorkoutExternalID(workout.ID)
+		workout = &exported
+	}
+
+	js, err := json.Marshal(workout)
+	if err != nil {
+		return err
+	}
+
+	if jw.count > 0 {
+		jw.w.WriteByte(',')
+	}
+	jw.w.WriteString("\n")
+	jw.w.Write(js)
+	jw.count++
+	return jw.w.Flush()
+}
+
+func (jw *jsonWriter) Close() error {
+	jw.w.WriteString("\n]}\n")
+	return jw.w.Flush()
+}
+
+// ReadJSON reads a JSON export back into workouts ready to import. IDs are
+// dropped and exercises are matched again by name, since the export may
+// come from another account. Every workout keeps the external ID it was
+// exported under; exports that predate those get one from the workout's ID,
+// which matches the original workout when imported into the same account.
+// Either way importing the same export twice doesn't duplicate it.
+func ReadJSON(r io.Reader) ([]*store.Workout, error) {
+	var document jsonDocument
+	err := json.NewDecoder(r).Decode(&document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON export: %w", err)
+	}
+	if document.Version != jsonVersion {
+		return nil, errors.New("unsupported JSON export version " + strconv.Itoa(document.Version))
+	}
+
+	workouts := []*store.Workout{}
+	for i, workout := range document.Workouts {
+		if workout == nil {
+			continue
+		}
+		if workout.CreatedAt.IsZero() {
+			return nil, fmt.Errorf("workout %d has no created_at", i+1)
+		}
+
+		switch {
+		case workout.ExternalID != "":
+		case workout.ID != 0:
+			workout.ExternalID = store.WorkoutExternalID(workout.ID)
+		default:
+			sum := sha256.Sum256([]byte(workout.CreatedAt.UTC().Format(time.RFC3339Nano)))
+			workout.ExternalID = FormatJSON + ":" + hex.EncodeToString(sum[:12])
+		}
+		workout.ID = 0
+		workout.UserID = 0
+		if workout.Groups == nil {
+			workout.Groups = []store.WorkoutEntryGroup{}
+		}
+		for g := range workout.Groups {
+			workout.Groups[g].ID = 0
+		}
+		for e := range workout.Entries {
+			entry := &workout.Entries[e]
+			entry.ID = 0
+			entry.ExerciseID = nil
+			entry.PersonalRecords = nil
+			for s := range entry.SetDetails {
+				entry.SetDetails[s].ID = 0
+			}
+		}
+		workouts = append(workouts, workout)
+	}
+	return workouts, nil
+}
@@ -0,0 +1,197 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ruhan/internal/store"
+)
+
+// markdownWriter renders a training journal: a section per workout with its
+// exercises, one bullet per set.
+type markdownWriter struct {
+	w    *bufio.Writer
+	meta Meta
+}
+
+func newMarkdownWriter(w io.Writer, meta Meta) (*markdownWriter, error) {
+	mw := &markdownWriter{w: bufio.NewWriter(w), meta: meta}
+	fmt.Fprintf(mw.w, "# Training journal\n\n%s · exported %s\n", mw.meta.UserName, meta.ExportedAt.In(meta.Location).Format("2 January 2006"))
+	return mw, nil
+}
+
+var setTypeLabels = map[string]string{
+	store.SetTypeWarmup:  "warm-up",
+	store.SetTypeDrop:    "drop set",
+	store.SetTypeFailure: "to failure",
+}
+
+var recordLabels = map[string]string{
+	store.RecordMaxWeight:    "heaviest weight",
+	store.RecordMaxReps:      "most reps",
+	store.RecordEstimated1RM: "best estimated 1RM",
+	store.RecordMaxDuration:  "longest duration",
+	store.RecordMaxDistance:  "longest distance",
+}
+
+func (mw *markdownWriter) WriteWorkout(workout *store.Workout) error {
+	createdAt := workout.CreatedAt.In(mw.meta.Location)
+	fmt.Fprintf(mw.w, "\n## %s · %s\n\n", createdAt.Format("Monday, 2 January 2006"), singleLine(workout.Title))
+
+	details := []string{createdAt.Format("15:04")}
+	if workout.DurationMinutes > 0 {
+		details = append(details, fmt.Sprintf("%d min", workout.DurationMinutes))
+	}
+	if workout.CaloriesBurned > 0 {
+		details = append(details, fmt.Sprintf("%d kcal", workout.CaloriesBurned))
+	}
+	fmt.Fprintf(mw.w, "%s\n", strings.Join(details, " · "))
+	if notes := strings.TrimSpace(workout.Description); notes != "" {
+		fmt.Fprintf(mw.w, "\n%s\n", notes)
+	}
+
+	groups := map[int]store.WorkoutEntryGroup{}
+	for _, group := range workout.Groups {
+		groups[group.GroupIndex] = group
+	}
+
+	for _, entry := range workout.Entries {
+		heading := "**" + singleLine(entry.ExerciseName) + "**"
+		if entry.GroupIndex != nil {
+			if group, ok := groups[*entry.GroupIndex]; ok {
+				heading += fmt.Sprintf(" (%s %d)", group.Type, group.GroupIndex)
+			}
+		}
+		fmt.Fprintf(mw.w, "\n%s\n\n", heading)
+
+		for _, line := range entryLines(entry) {
+			fmt.Fprintf(mw.w, "- %s\n", line)
+		}
+
+		if len(entry.PersonalRecords) > 0 {
+			records := []string{}
+			for _, record := range entry.PersonalRecords {
+				label, ok := recordLabels[record]
+				if !ok {
+					label = record
+				}
+				records = append(records, label)
+			}
+			fmt.Fprintf(mw.w, "\nNew record: %s.\n", strings.Join(records, ", "))
+		}
+		if notes := strings.TrimSpace(entry.Notes); notes != "" {
+			fmt.Fprintf(mw.w, "\n%s\n", notes)
+		}
+	}
+
+	return mw.w.Flush()
+}
+
+func (mw *markdownWriter) Close() error {
+	return mw.w.Flush()
+}
+
+// entryLines describes an entry as one line per set, or one line for cardio.
+func entryLines(entry store.WorkoutEntry) []string {
+	if entry.Kind == store.EntryKindCardio {
+		return []string{cardioLine(entry)}
+	}
+
+	if len(entry.SetDetails) == 0 {
+		set := store.WorkoutSet{Reps: entry.Reps, Weight: entry.Weight, DurationSeconds: entry.DurationSeconds}
+		return []string{fmt.Sprintf("%d sets of %s", entry.Sets, setLine(set))}
+	}
+
+	lines := []string{}
+	for _, set := range entry.SetDetails {
+		line := setLine(set)
+		if label, ok := setTypeLabels[set.Type]; ok {
+			line = label + ": " + line
+		}
+		if set.Completed != nil && !*set.Completed {
+			line += " (missed)"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// setLine reads like "5 × 80 @ RPE 8", "12 reps" or "1:00 × 20".
+func setLine(set store.WorkoutSet) string {
+	var line string
+	if set.Reps != nil {
+		line = fmt.Sprintf("%d reps", *set.Reps)
+	} else if set.DurationSeconds != nil {
+		line = formatDuration(*set.DurationSeconds)
+	}
+	if set.Weight != nil && *set.Weight > 0 {
+		if set.Reps != nil {
+			line = fmt.Sprintf("%d", *set.Reps)
+		}
+		line += " × " + formatFloat(*set.Weight)
+	}
+
+	if set.RPE != nil {
+		line += " @ RPE " + formatFloat(*set.RPE)
+	}
+	if set.RIR != nil {
+		line += fmt.Sprintf(", %d in reserve", *set.RIR)
+	}
+	return line
+}
+
+// cardioLine reads like "5.2 km in 30:00 · 5:46 /km · avg HR 150".
+func cardioLine(entry store.WorkoutEntry) string {
+	parts := []string{}
+
+	var main string
+	if entry.Distance != nil {
+		main = formatFloat(*entry.Distance) + " " + entry.DistanceUnit
+	}
+	if entry.DurationSeconds != nil {
+		if main != "" {
+			main += " in "
+		}
+		main += formatDuration(*entry.DurationSeconds)
+	}
+	parts = append(parts, main)
+
+	meters := entry.DistanceMeters()
+	if meters != nil && *meters > 0 && entry.DurationSeconds != nil && *entry.DurationSeconds > 0 {
+		paceUnit := "km"
+		if entry.DistanceUnit == "mi" {
+			paceUnit = "mi"
+		}
+		pace := float64(*entry.DurationSeconds) / (*meters / store.DistanceUnits[paceUnit])
+		parts = append(parts, formatDuration(int(pace+0.5))+" /"+paceUnit)
+	}
+
+	if entry.AvgHeartRate != nil {
+		parts = append(parts, fmt.Sprintf("avg HR %d", *entry.AvgHeartRate))
+	}
+	if entry.MaxHeartRate != nil {
+		parts = append(parts, fmt.Sprintf("max HR %d", *entry.MaxHeartRate))
+	}
+	if entry.AvgCadence != nil {
+		parts = append(parts, fmt.Sprintf("cadence %d", *entry.AvgCadence))
+	}
+	if entry.ElevationGainMeters != nil {
+		parts = append(parts, formatFloat(*entry.ElevationGainMeters)+" m climbed")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatDuration writes seconds as m:ss, or h:mm:ss from an hour on.
+func formatDuration(seconds int) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// singleLine keeps titles and exercise names from breaking headings.
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,327 @@
+package fit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Global message numbers from the FIT profile.
+const (
+	MesgFileID  = 0
+	MesgSession = 18
+	MesgLap     = 19
+	MesgRecord  = 20
+	MesgSet     = 225
+)
+
+// messageNames names the messages an activity file commonly carries; the
+// ones ParseActivity doesn't use are reported as skipped, anything else as
+// unrecognized.
+var messageNames = map[uint16]string{
+	0:   "file_id",
+	2:   "device_settings",
+	3:   "user_profile",
+	7:   "zones_target",
+	12:  "sport",
+	18:  "session",
+	19:  "lap",
+	20:  "record",
+	21:  "event",
+	22:  "source",
+	23:  "device_info",
+	26:  "workout",
+	27:  "workout_step",
+	34:  "activity",
+	49:  "file_creator",
+	72:  "training_file",
+	78:  "hrv",
+	101: "length",
+	206: "field_description",
+	207: "developer_data_id",
+	216: "time_in_zone",
+	225: "set",
+	264: "exercise_title",
+}
+
+const fileTypeActivity = 4
+
+var ErrNotActivity = errors.New("FIT file is not an activity")
+
+const (
+	SportGeneric  = "generic"
+	SportRunning  = "running"
+	SportCycling  = "cycling"
+	SportSwimming = "swimming"
+	SportTraining = "training"
+	SportWalking  = "walking"
+	SportRowing   = "rowing"
+	SportHiking   = "hiking"
+)
+
+var sports = map[float64]string{
+	0:  SportGeneric,
+	1:  SportRunning,
+	2:  SportCycling,
+	5:  SportSwimming,
+	10: SportTraining,
+	11: SportWalking,
+	15: SportRowing,
+	17: SportHiking,
+}
+
+// subSportStrengthTraining marks a training session as strength training.
+const subSportStrengthTraining = 20
+
+// Totals are the summary fields sessions and laps share. Every field is nil
+// when the device didn't record it.
+type Totals struct {
+	StartTime      time.Time
+	ElapsedSeconds *float64
+	TimerSeconds   *float64
+	DistanceMeters *float64
+	Calories       *int
+	AvgHeartRate   *int
+	MaxHeartRate   *int
+	AvgCadence     *int
+	AscentMeters   *float64
+}
+
+// Session is one sport within an activity; most activities have one.
+// Sport is one of the Sport constants or "" for sports not listed there.
+type Session struct {
+	Totals
+	Sport    string
+	Strength bool
+}
+
+type Lap struct {
+	Totals
+}
+
+// Record is one sample of the recording.
+type Record struct {
+	Time           time.Time
+	Lat            *float64
+	Lon            *float64
+	AltitudeMeters *float64
+	HeartRate      *int
+	Cadence        *int
+	DistanceMeters *float64
+}
+
+// Set is one active set of a strength-training session. Category is the
+// FIT exercise category, e.g. "bench_press", or "" when the watch didn't
+// identify the exercise. Weights are in kilograms.
+type Set struct {
+	StartTime       time.Time
+	DurationSeconds *float64
+	Reps            *int
+	WeightKg        *float64
+	Category        string
+}
+
+// Report counts the messages of a file by name: those that made it into
+// the Activity, those that were understood but not used, and those of types
+// this package doesn't know.
+type Report struct {
+	Imported     map[string]int `json:"imported"`
+	Skipped      map[string]int `json:"skipped"`
+	Unrecognized map[string]int `json:"unrecognized"`
+}
+
+type Activity struct {
+	Sessions []Session
+	Laps     []Lap
+	Records  []Record
+	Sets     []Set
+	Report   Report
+}
+
+// ParseActivity decodes a FIT activity file.
+func ParseActivity(r io.Reader) (*Activity, error) {
+	messages, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := &Activity{Report: Report{
+		Imported:     map[string]int{},
+		Skipped:      map[string]int{},
+		Unrecognized: map[string]int{},
+	}}
+	report := &activity.Report
+	isActivity := false
+
+	for i := range messages {
+		message := &messages[i]
+		name, known := messageNames[message.Num]
+		if !known {
+			report.Unrecognized[fmt.Sprintf("message_%d", message.Num)]++
+			continue
+		}
+
+		switch message.Num {
+		case MesgFileID:
+			fileType, _ := message.Value(0)
+			if fileType != fileTypeActivity {
+				return nil, ErrNotActivity
+			}
+			isActivity = true
+			report.Imported[name]++
+		case MesgSession:
+			activity.Sessions = append(activity.Sessions, decodeSession(message))
+			report.Imported[name]++
+		case MesgLap:
+			activity.Laps = append(activity.Laps, Lap{decodeTotals(message, lapFields)})
+			report.Imported[name]++
+		case MesgRecord:
+			activity.Records = append(activity.Records, decodeRecord(message))
+			report.Imported[name]++
+		case MesgSet:
+			set, active := decodeSet(message)
+			if !active {
+				// rest periods between sets
+				report.Skipped[name]++
+				continue
+			}
+			activity.Sets = append(activity.Sets, set)
+			report.Imported[name]++
+		default:
+			report.Skipped[name]++
+		}
+	}
+
+	if !isActivity {
+		return nil, ErrNotActivity
+	}
+	return activity, nil
+}
+
+// totalsFields numbers the Totals fields, which differ between sessions and
+// laps.
+type totalsFields struct {
+	startTime, elapsed, timer, distance, calories, avgHeartRate, maxHeartRate, avgCadence, ascent uint8
+}
+
+var (
+	sessionFields = totalsFields{2, 7, 8, 9, 11, 16, 17, 18, 22}
+	lapFields     = totalsFields{2, 7, 8, 9, 11, 15, 16, 17, 21}
+)
+
+func decodeTotals(message *Message, fields totalsFields) Totals {
+	var totals Totals
+	totals.StartTime, _ = message.Time(fields.startTime)
+	totals.ElapsedSeconds = scaled(message, fields.elapsed, 1000, 0)
+	totals.TimerSeconds = scaled(message, fields.timer, 1000, 0)
+	totals.DistanceMeters = scaled(message, fields.distance, 100, 0)
+	totals.Calories = integer(message, fields.calories)
+	totals.AvgHeartRate = integer(message, fields.avgHeartRate)
+	totals.MaxHeartRate = integer(message, fields.maxHeartRate)
+	totals.AvgCadence = integer(message, fields.avgCadence)
+	totals.AscentMeters = scaled(message, fields.ascent, 1, 0)
+	return totals
+}
+
+func decodeSession(message *Message) Session {
+	session := Session{Totals: decodeTotals(message, sessionFields)}
+
+	sport, _ := message.Value(5)
+	session.Sport = sports[sport]
+	subSport, _ := message.Value(6)
+	session.Strength = session.Sport == SportTraining && subSport == subSportStrengthTraining
+	return session
+}
+
+// semicircles to degrees
+const semicircle = 180 / float64(1<<31)
+
+func decodeRecord(message *Message) Record {
+	var record Record
+	record.Time, _ = message.Time(fieldTimestamp)
+	record.Lat = scaled(message, 0, 1/semicircle, 0)
+	record.Lon = scaled(message, 1, 1/semicircle, 0)
+	record.AltitudeMeters = scaled(message, 78, 5, 500)
+	if record.AltitudeMeters == nil {
+		record.AltitudeMeters = scaled(message, 2, 5, 500)
+	}
+	record.HeartRate = integer(message, 3)
+	record.Cadence = integer(message, 4)
+	record.DistanceMeters = scaled(message, 5, 100, 0)
+	return record
+}
+
+const setTypeActive = 1
+
+// decodeSet reads a set message and reports whether it was an active set.
+func decodeSet(message *Message) (Set, bool) {
+	var set Set
+	set.StartTime, _ = message.Time(6)
+	set.DurationSeconds = scaled(message, 0, 1000, 0)
+	set.Reps = integer(message, 3)
+	set.WeightKg = scaled(message, 4, 16, 0)
+	if category, ok := message.Value(7); ok {
+		set.Category = exerciseCategories[category]
+	}
+
+	setType, _ := message.Value(5)
+	return set, setType == setTypeActive
+}
+
+// scaled applies the FIT profile's scale and offset to a field.
+func scaled(message *Message, num uint8, scale, offset float64) *float64 {
+	value, ok := message.Value(num)
+	if !ok {
+		return nil
+	}
+	value = value/scale - offset
+	return &value
+}
+
+func integer(message *Message, num uint8) *int {
+	value, ok := message.Value(num)
+	if !ok {
+		return nil
+	}
+	n := int(math.Round(value))
+	return &n
+}
+
+// exerciseCategories names the FIT exercise categories.
+var exerciseCategories = map[float64]string{
+	0:  "bench_press",
+	1:  "calf_raise",
+	2:  "cardio",
+	3:  "carry",
+	4:  "chop",
+	5:  "core",
+	6:  "crunch",
+	7:  "curl",
+	8:  "deadlift",
+	9:  "flye",
+	10: "hip_raise",
+	11: "hip_stability",
+	12: "hip_swing",
+	13: "hyperextension",
+	14: "lateral_raise",
+	15: "leg_curl",
+	16: "leg_raise",
+	17: "lunge",
+	18: "olympic_lift",
+	19: "plank",
+	20: "plyo",
+	21: "pull_up",
+	22: "push_up",
+	23: "row",
+	24: "shoulder_press",
+	25: "shoulder_stability",
+	26: "shrug",
+	27: "sit_up",
+	28: "squat",
+	29: "total_body",
+	30: "triceps_extension",
+	31: "warm_up",
+	32: "run",
+}
@@ -0,0 +1,333 @@
+// Package fit decodes Garmin FIT activity files.
+//
+// Decode reads the raw messages of a file; ParseActivity interprets the
+// sessions, laps, records and strength-training sets of an activity.
+package fit
+
+//go:generate go run testdata/generate.go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidHeader = errors.New("not a FIT file")
+	ErrChecksum      = errors.New("FIT file is corrupt: checksum mismatch")
+	ErrTruncated     = errors.New("FIT file is truncated")
+)
+
+// FIT timestamps count seconds from 1989-12-31T00:00:00Z.
+var epoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// fieldTimestamp is the timestamp field number shared by every message.
+const fieldTimestamp = 253
+
+// Message is one decoded data message. Fields hold the raw values of every
+// field that had a valid value; scale and offset are left to the caller.
+// Array fields keep all of their elements.
+type Message struct {
+	Num     uint16
+	Fields  map[uint8][]float64
+	Strings map[uint8]string
+}
+
+// Value returns the first element of a numeric field.
+func (m *Message) Value(num uint8) (float64, bool) {
+	values, ok := m.Fields[num]
+	if !ok || len(values) == 0 {
+		return 0, false
+	}
+	return values[0], true
+}
+
+// Time returns a timestamp field as a time.
+func (m *Message) Time(num uint8) (time.Time, bool) {
+	value, ok := m.Value(num)
+	if !ok {
+		return time.Time{}, false
+	}
+	return epoch.Add(time.Duration(value) * time.Second), true
+}
+
+type fieldDefinition struct {
+	num      uint8
+	size     int
+	baseType byte
+}
+
+type definition struct {
+	num       uint16
+	byteOrder binary.ByteOrder
+	fields    []fieldDefinition
+	// developer fields are skipped over, their total size is enough
+	developerSize int
+}
+
+// Decode reads every data message of a FIT file, including files chained one
+// after another. Header and file checksums are verified.
+func Decode(r io.Reader) ([]Message, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []Message{}
+	for len(data) > 0 {
+		var file []Message
+		file, data, err = decodeFile(data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, file...)
+	}
+	return messages, nil
+}
+
+// decodeFile decodes the first file in data and returns what follows it.
+func decodeFile(data []byte) ([]Message, []byte, error) {
+	if len(data) < 12 {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	headerSize := int(data[0])
+	if (headerSize != 12 && headerSize != 14) || len(data) < headerSize || !bytes.Equal(data[8:12], []byte(".FIT")) {
+		return nil, nil, ErrInvalidHeader
+	}
+	if headerSize == 14 {
+		// a zero header checksum means it wasn't computed
+		headerCRC := binary.LittleEndian.Uint16(data[12:14])
+		if headerCRC != 0 && headerCRC != checksum(data[:12]) {
+			return nil, nil, ErrChecksum
+		}
+	}
+
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := headerSize + dataSize
+	if len(data) < end+2 {
+		return nil, nil, ErrTruncated
+	}
+	if binary.LittleEndian.Uint16(data[end:end+2]) != checksum(data[:end]) {
+		return nil, nil, ErrChecksum
+	}
+
+	messages, err := decodeRecords(data[headerSize:end])
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages, data[end+2:], nil
+}
+
+func decodeRecords(data []byte) ([]Message, error) {
+	definitions := map[byte]*definition{}
+	messages := []Message{}
+	var lastTimestamp uint32
+
+	for pos := 0; pos < len(data); {
+		header := data[pos]
+		pos++
+
+		var local byte
+		compressedOffset := -1
+		switch {
+		case header&0x80 != 0:
+			// compressed timestamp header: a data message whose timestamp
+			// is a 5-bit offset from the last full timestamp
+			local = (header >> 5) & 0x03
+			compressedOffset = int(header & 0x1F)
+		case header&0x40 != 0:
+			def, n, err := decodeDefinition(data[pos:], header&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			definitions[header&0x0F] = def
+			pos += n
+			continue
+		default:
+			local = header & 0x0F
+		}
+
+		def, ok := definitions[local]
+		if !ok {
+			return nil, fmt.Errorf("FIT file is corrupt: data message for undefined local type %d", local)
+		}
+
+		message := Message{Num: def.num, Fields: map[uint8][]float64{}, Strings: map[uint8]string{}}
+		for _, field := range def.fields {
+			if pos+field.size > len(data) {
+				return nil, ErrTruncated
+			}
+			decodeField(&message, field, def.byteOrder, data[pos:pos+field.size])
+			pos += field.size
+		}
+		pos += def.developerSize
+		if pos > len(data) {
+			return nil, ErrTruncated
+		}
+
+		if timestamp, ok := message.Value(fieldTimestamp); ok {
+			lastTimestamp = uint32(timestamp)
+		} else if compressedOffset >= 0 {
+			timestamp := lastTimestamp&^0x1F + uint32(compressedOffset)
+			if uint32(compressedOffset) < lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+			lastTimestamp = timestamp
+			message.Fields[fieldTimestamp] = []float64{float64(timestamp)}
+		}
+
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// decodeDefinition reads a definition message and returns it with its size.
+func decodeDefinition(data []byte, hasDeveloperFields bool) (*definition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, ErrTruncated
+	}
+
+	def := &definition{byteOrder: binary.LittleEndian}
+	if data[1] == 1 {
+		def.byteOrder = binary.BigEndian
+	}
+	def.num = def.byteOrder.Uint16(data[2:4])
+
+	count := int(data[4])
+	pos := 5
+	if len(data) < pos+3*count {
+		return nil, 0, ErrTruncated
+	}
+	for i := 0; i < count; i++ {
+		def.fields = append(def.fields, fieldDefinition{
+			num:      data[pos],
+			size:     int(data[pos+1]),
+			baseType: data[pos+2],
+		})
+		pos += 3
+	}
+
+	if hasDeveloperFields {
+		if len(data) < pos+1 {
+			return nil, 0, ErrTruncated
+		}
+		count = int(data[pos])
+		pos++
+		if len(data) < pos+3*count {
+			return nil, 0, ErrTruncated
+		}
+		for i := 0; i < count; i++ {
+			def.developerSize += int(data[pos+1])
+			pos += 3
+		}
+	}
+	return def, pos, nil
+}
+
+type baseType struct {
+	size    int
+	invalid uint64
+	signed  bool
+	float   bool
+}
+
+// baseTypes is keyed by the base type number, the low five bits of the
+// base type byte.
+var baseTypes = map[byte]baseType{
+	0x00: {1, 0xFF, false, false},               // enum
+	0x01: {1, 0x7F, true, false},                // sint8
+	0x02: {1, 0xFF, false, false},               // uint8
+	0x03: {2, 0x7FFF, true, false},              // sint16
+	0x04: {2, 0xFFFF, false, false},             // uint16
+	0x05: {4, 0x7FFFFFFF, true, false},          // sint32
+	0x06: {4, 0xFFFFFFFF, false, false},         // uint32
+	0x08: {4, 0xFFFFFFFF, false, true},          // float32
+	0x09: {8, 0xFFFFFFFFFFFFFFFF, false, true},  // float64
+	0x0A: {1, 0x00, false, false},               // uint8z
+	0x0B: {2, 0x0000, false, false},             // uint16z
+	0x0C: {4, 0x00000000, false, false},         // uint32z
+	0x0D: {1, 0xFF, false, false},               // byte
+	0x0E: {8, 0x7FFFFFFFFFFFFFFF, true, false},  // sint64
+	0x0F: {8, 0xFFFFFFFFFFFFFFFF, false, false}, // uint64
+	0x10: {8, 0x0000000000000000, false, false}, // uint64z
+}
+
+const baseTypeString = 0x07
+
+func decodeField(message *Message, field fieldDefinition, order binary.ByteOrder, raw []byte) {
+	number := field.baseType & 0x1F
+	if number == baseTypeString {
+		if end := bytes.IndexByte(raw, 0); end >= 0 {
+			raw = raw[:end]
+		}
+		if len(raw) > 0 {
+			message.Strings[field.num] = string(raw)
+		}
+		return
+	}
+
+	bt, ok := baseTypes[number]
+	if !ok || field.size < bt.size {
+		return
+	}
+
+	values := []float64{}
+	for i := 0; i+bt.size <= field.size; i += bt.size {
+		var bits uint64
+		switch bt.size {
+		case 1:
+			bits = uint64(raw[i])
+		case 2:
+			bits = uint64(order.Uint16(raw[i:]))
+		case 4:
+			bits = uint64(order.Uint32(raw[i:]))
+		case 8:
+			bits = order.Uint64(raw[i:])
+		}
+		if bits == bt.invalid {
+			continue
+		}
+
+		switch {
+		case bt.float && bt.size == 4:
+			values = append(values, float64(math.Float32frombits(uint32(bits))))
+		case bt.float:
+			values = append(values, math.Float64frombits(bits))
+		case bt.signed:
+			// sign-extend from the field's width
+			shift := 64 - 8*bt.size
+			values = append(values, float64(int64(bits<<shift)>>shift))
+		default:
+			values = append(values, float64(bits))
+		}
+	}
+
+	if len(values) > 0 {
+		message.Fields[field.num] = values
+	}
+}
+
+var crcTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// checksum is the CRC-16 used for FIT headers and files.
+func checksum(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[b&0xF]
+
+		tmp = crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[(b>>4)&0xF]
+	}
+	return crc
+}
@@ -0,0 +1,132 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readSample(t *testing.T, name string) []byte {
+	data, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseActivityRun(t *testing.T) {
+	activity, err := ParseActivity(bytes.NewReader(readSample(t, "run.fit")))
+	require.NoError(t, err)
+
+	require.Len(t, activity.Sessions, 1)
+	session := activity.Sessions[0]
+	assert.Equal(t, SportRunning, session.Sport)
+	assert.False(t, session.Strength)
+	assert.Equal(t, time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC), session.StartTime)
+	assert.InDelta(t, 590, *session.TimerSeconds, 0.001)
+	assert.InDelta(t, 240, *session.DistanceMeters, 0.001)
+	assert.Equal(t, 95, *session.Calories)
+	assert.Equal(t, 145, *session.AvgHeartRate)
+	assert.Equal(t, 160, *session.MaxHeartRate)
+	assert.InDelta(t, 30, *session.AscentMeters, 0.001)
+
+	// laps are big-endian in the sample
+	require.Len(t, activity.Laps, 2)
+	assert.InDelta(t, 290, *activity.Laps[1].TimerSeconds, 0.001)
+	assert.Equal(t, 152, *activity.Laps[1].AvgHeartRate)
+
+	require.Len(t, activity.Records, 61)
+	for i, record := range activity.Records {
+		assert.Equal(t, session.StartTime.Add(time.Duration(i)*10*time.Second), record.Time, "record %d", i)
+	}
+	last := activity.Records[60]
+	assert.InDelta(t, 52.00216, *last.Lat, 0.00001)
+	assert.InDelta(t, 13, *last.Lon, 0.00001)
+	assert.InDelta(t, 130, *last.AltitudeMeters, 0.001)
+	assert.Equal(t, 160, *last.HeartRate)
+	assert.InDelta(t, 240, *last.DistanceMeters, 0.001)
+
+	assert.Equal(t, map[string]int{"file_id": 1, "record": 61, "lap": 2, "session": 1}, activity.Report.Imported)
+	assert.Equal(t, map[string]int{"device_info": 1, "activity": 1}, activity.Report.Skipped)
+	assert.Equal(t, map[string]int{"message_65296": 1}, activity.Report.Unrecognized)
+}
+
+func TestParseActivityStrength(t *testing.T) {
+	activity, err := ParseActivity(bytes.NewReader(readSample(t, "strength.fit")))
+	require.NoError(t, err)
+
+	require.Len(t, activity.Sessions, 1)
+	assert.True(t, activity.Sessions[0].Strength)
+	assert.Equal(t, 210, *activity.Sessions[0].Calories)
+
+	require.Len(t, activity.Sets, 6)
+	bench := activity.Sets[0]
+	assert.Equal(t, "bench_press", bench.Category)
+	assert.Equal(t, 8, *bench.Reps)
+	assert.InDelta(t, 60, *bench.WeightKg, 0.001)
+	assert.InDelta(t, 40, *bench.DurationSeconds, 0.001)
+	assert.Equal(t, time.Date(2026, 3, 3, 18, 0, 0, 0, time.UTC), bench.StartTime)
+
+	assert.Equal(t, "squat", activity.Sets[4].Category)
+	assert.InDelta(t, 62.5, *activity.Sets[4].WeightKg, 0.001)
+
+	plank := activity.Sets[5]
+	assert.Equal(t, "plank", plank.Category)
+	assert.Nil(t, plank.Reps)
+	assert.Nil(t, plank.WeightKg)
+	assert.InDelta(t, 60, *plank.DurationSeconds, 0.001)
+
+	assert.Equal(t, 6, activity.Report.Imported["set"])
+	assert.Equal(t, 5, activity.Report.Skipped["set"])
+}
+
+// reseal recomputes the file checksum after a test edits the data.
+func reseal(data []byte) []byte {
+	end := len(data) - 2
+	binary.LittleEndian.PutUint16(data[end:], checksum(data[:end]))
+	return data
+}
+
+func TestParseActivityErrors(t *testing.T) {
+	sample := readSample(t, "run.fit")
+	edit := func(change func([]byte) []byte) []byte {
+		return change(bytes.Clone(sample))
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"empty", nil, ErrNotActivity},
+		{"not FIT", []byte("<gpx></gpx> and some padding"), ErrInvalidHeader},
+		{"truncated", sample[:len(sample)-40], ErrTruncated},
+		{"corrupt byte", edit(func(d []byte) []byte { d[100] ^= 0xFF; return d }), ErrChecksum},
+		{"corrupt header", edit(func(d []byte) []byte { d[2] ^= 0xFF; return d }), ErrChecksum},
+		// the file_id type of the first data message, a course instead of
+		// an activity
+		{"not an activity", edit(func(d []byte) []byte { d[33] = 6; return reseal(d) }), ErrNotActivity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseActivity(bytes.NewReader(tt.data))
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDecodeChainedFiles(t *testing.T) {
+	run := readSample(t, "run.fit")
+	strength := readSample(t, "strength.fit")
+
+	messages, err := Decode(bytes.NewReader(append(bytes.Clone(run), strength...)))
+	require.NoError(t, err)
+
+	single, err := Decode(bytes.NewReader(run))
+	require.NoError(t, err)
+	assert.Greater(t, len(messages), len(single))
+}
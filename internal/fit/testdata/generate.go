@@ -0,0 +1,263 @@
+//go:build ignore
+
+// generate writes the sample FIT files the fit package is tested against: a
+// short GPS run and a strength-training session, laid out the way Garmin
+// watches write them. Run it with go generate from the package directory.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+var epoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+func timestamp(t time.Time) uint32 {
+	return uint32(t.Sub(epoch) / time.Second)
+}
+
+// base types
+const (
+	tEnum   = 0x00
+	tUint8  = 0x02
+	tUint16 = 0x84
+	tSint32 = 0x85
+	tUint32 = 0x86
+	tString = 0x07
+)
+
+type field struct {
+	num      byte
+	size     byte
+	baseType byte
+}
+
+type encoder struct {
+	buf    bytes.Buffer
+	orders map[byte]binary.ByteOrder
+	fields map[byte][]field
+	dev    map[byte]int
+}
+
+func newEncoder() *encoder {
+	return &encoder{orders: map[byte]binary.ByteOrder{}, fields: map[byte][]field{}, dev: map[byte]int{}}
+}
+
+// define writes a definition message. devSizes adds developer fields of the
+// given sizes, which readers skip.
+func (e *encoder) define(local byte, global uint16, bigEndian bool, fields []field, devSizes ...byte) {
+	header := 0x40 | local
+	if len(devSizes) > 0 {
+		header |= 0x20
+	}
+	e.buf.WriteByte(header)
+	e.buf.WriteByte(0)
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+	binary.Write(&e.buf, order, global)
+
+	e.buf.WriteByte(byte(len(fields)))
+	for _, f := range fields {
+		e.buf.Write([]byte{f.num, f.size, f.baseType})
+	}
+
+	devSize := 0
+	if len(devSizes) > 0 {
+		e.buf.WriteByte(byte(len(devSizes)))
+		for i, size := range devSizes {
+			e.buf.Write([]byte{byte(i), size, 0})
+			devSize += int(size)
+		}
+	}
+
+	e.orders[local] = order
+	e.fields[local] = fields
+	e.dev[local] = devSize
+}
+
+// data writes a data message; values are given in field order, strings for
+// string fields and integers otherwise. compressed, when not negative, uses
+// a compressed timestamp header with that offset.
+func (e *encoder) data(local byte, compressed int, values ...any) {
+	if compressed >= 0 {
+		e.buf.WriteByte(0x80 | local<<5 | byte(compressed))
+	} else {
+		e.buf.WriteByte(local)
+	}
+
+	order := e.orders[local]
+	for i, f := range e.fields[local] {
+		raw := make([]byte, f.size)
+		switch v := values[i].(type) {
+		case string:
+			copy(raw, v)
+		case int:
+			switch f.size {
+			case 1:
+				raw[0] = byte(v)
+			case 2:
+				order.PutUint16(raw, uint16(v))
+			case 4:
+				order.PutUint32(raw, uint32(int32(v)))
+			}
+		case uint32:
+			order.PutUint32(raw, v)
+		}
+		e.buf.Write(raw)
+	}
+	e.buf.Write(make([]byte, e.dev[local]))
+}
+
+func (e *encoder) file() []byte {
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = 0x20
+	binary.LittleEndian.PutUint16(header[2:], 2132)
+	binary.LittleEndian.PutUint32(header[4:], uint32(e.buf.Len()))
+	copy(header[8:], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:], checksum(header[:12]))
+
+	out := append(header, e.buf.Bytes()...)
+	return binary.LittleEndian.AppendUint16(out, checksum(out))
+}
+
+var crcTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+func checksum(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[b&0xF]
+		tmp = crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+func semicircles(degrees float64) int {
+	return int(math.Round(degrees * float64(1<<31) / 180))
+}
+
+func fileID(e *encoder, created time.Time) {
+	e.define(0, 0, false, []field{{0, 1, tEnum}, {1, 2, tUint16}, {2, 2, tUint16}, {4, 4, tUint32}})
+	e.data(0, -1, 4, 1, 3121, timestamp(created))
+}
+
+// run writes a 10 minute run north in two laps: 61 records 10 s apart, every
+// other one with a compressed timestamp, 4 m per record, climbing 0.5 m per
+// record.
+func run() []byte {
+	start := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	e := newEncoder()
+	fileID(e, start)
+
+	e.define(1, 23, false, []field{{253, 4, tUint32}, {2, 2, tUint16}, {27, 20, tString}})
+	e.data(1, -1, timestamp(start), 3121, "Forerunner")
+
+	full := []field{{253, 4, tUint32}, {0, 4, tSint32}, {1, 4, tSint32}, {78, 4, tUint32}, {3, 1, tUint8}, {4, 1, tUint8}, {5, 4, tUint32}}
+	compact := full[1:]
+	e.define(2, 20, false, full)
+	e.define(3, 20, false, compact)
+
+	for i := 0; i <= 60; i++ {
+		at := start.Add(time.Duration(i) * 10 * time.Second)
+		lat := semicircles(52 + float64(i)*4/111195)
+		lon := semicircles(13)
+		altitude := int((100 + float64(i)*0.5 + 500) * 5)
+		heartRate := 130 + i/2
+		distance := i * 400
+		if i%2 == 1 {
+			e.data(3, int(timestamp(at)&0x1F), lat, lon, altitude, heartRate, 85, distance)
+		} else {
+			e.data(2, -1, timestamp(at), lat, lon, altitude, heartRate, 85, distance)
+		}
+	}
+
+	// laps are written big-endian, as some devices do
+	lap := []field{{253, 4, tUint32}, {2, 4, tUint32}, {7, 4, tUint32}, {8, 4, tUint32}, {9, 4, tUint32}, {15, 1, tUint8}, {16, 1, tUint8}}
+	e.define(4, 19, true, lap)
+	e.data(4, -1, timestamp(start.Add(5*time.Minute)), timestamp(start), 300000, 300000, 12000, 137, 145)
+	e.data(4, -1, timestamp(start.Add(10*time.Minute)), timestamp(start.Add(5*time.Minute)), 300000, 290000, 12000, 152, 160)
+
+	session := []field{{253, 4, tUint32}, {2, 4, tUint32}, {5, 1, tEnum}, {6, 1, tEnum}, {7, 4, tUint32}, {8, 4, tUint32}, {9, 4, tUint32}, {11, 2, tUint16}, {16, 1, tUint8}, {17, 1, tUint8}, {18, 1, tUint8}, {22, 2, tUint16}}
+	e.define(5, 18, false, session)
+	e.data(5, -1, timestamp(start.Add(10*time.Minute)), timestamp(start), 1, 0, 600000, 590000, 24000, 95, 145, 160, 85, 30)
+
+	e.define(6, 34, false, []field{{253, 4, tUint32}, {1, 2, tUint16}})
+	e.data(6, -1, timestamp(start.Add(10*time.Minute)), 1)
+
+	// a manufacturer-specific message no reader knows
+	e.define(7, 0xFF10, false, []field{{0, 4, tUint32}})
+	e.data(7, -1, uint32(7))
+
+	return e.file()
+}
+
+// strength writes a strength session: three sets of bench press, two of
+// squats and a timed plank, with rest sets in between.
+func strength() []byte {
+	start := time.Date(2026, 3, 3, 18, 0, 0, 0, time.UTC)
+	e := newEncoder()
+	fileID(e, start)
+
+	set := []field{{254, 4, tUint32}, {0, 4, tUint32}, {3, 2, tUint16}, {4, 2, tUint16}, {5, 1, tUint8}, {6, 4, tUint32}, {7, 4, tUint16}}
+	// the set definition carries a developer field, as Connect IQ apps add
+	e.define(1, 225, false, set, 2)
+
+	at := start
+	add := func(active bool, seconds int, reps, weight, category int) {
+		setType := 0
+		if active {
+			setType = 1
+		}
+		end := at.Add(time.Duration(seconds) * time.Second)
+		// the category field is an array of two; the second element is
+		// left invalid
+		e.data(1, -1, timestamp(end), seconds*1000, reps, weight, setType, timestamp(at), uint32(category)|0xFFFF0000)
+		at = end
+	}
+
+	const invalid16 = 0xFFFF
+	for i := 0; i < 3; i++ {
+		add(true, 40, 8, 60*16, 0)
+		add(false, 90, invalid16, invalid16, invalid16)
+	}
+	add(true, 50, 5, 100*16, 28)
+	add(false, 120, invalid16, invalid16, invalid16)
+	add(true, 45, 6, 1000, 28)
+	add(false, 60, invalid16, invalid16, invalid16)
+	add(true, 60, invalid16, invalid16, 19)
+
+	session := []field{{253, 4, tUint32}, {2, 4, tUint32}, {5, 1, tEnum}, {6, 1, tEnum}, {7, 4, tUint32}, {8, 4, tUint32}, {11, 2, tUint16}, {16, 1, tUint8}, {17, 1, tUint8}}
+	e.define(2, 18, false, session)
+	e.data(2, -1, timestamp(at), timestamp(start), 10, 20, 1015000, 1015000, 210, 112, 151)
+
+	return e.file()
+}
+
+func main() {
+	files := map[string][]byte{
+		"testdata/run.fit":      run(),
+		"testdata/strength.fit": strength(),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
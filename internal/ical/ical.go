@@ -0,0 +1,99 @@
+// Package ical writes RFC 5545 iCalendar feeds.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeFormat = "20060102T150405Z"
+	dateFormat     = "20060102"
+
+	// content lines longer than this many octets are folded
+	maxLineOctets = 75
+)
+
+// Event is one VEVENT. All-day events only use the date of Start and End,
+// and End is exclusive as RFC 5545 requires.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+}
+
+// Write renders a VCALENDAR named name holding events. stamp is used as the
+// DTSTAMP of every event.
+func Write(w io.Writer, name string, stamp time.Time, events []Event) error {
+	bw := bufio.NewWriter(w)
+	line := func(content string) {
+		writeFolded(bw, content)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//ruhan//workouts//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeText(name))
+
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + event.UID)
+		line("DTSTAMP:" + stamp.UTC().Format(dateTimeFormat))
+		if event.AllDay {
+			line("DTSTART;VALUE=DATE:" + event.Start.Format(dateFormat))
+			line("DTEND;VALUE=DATE:" + event.End.Format(dateFormat))
+		} else {
+			line("DTSTART:" + event.Start.UTC().Format(dateTimeFormat))
+			line("DTEND:" + event.End.UTC().Format(dateTimeFormat))
+		}
+		line("SUMMARY:" + escapeText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:" + escapeText(event.Description))
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+func escapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// writeFolded writes a content line terminated by CRLF, folding it onto
+// continuation lines that start with a space. Folds never split a UTF-8
+// sequence.
+func writeFolded(w *bufio.Writer, content string) {
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		w.WriteString(content[:cut])
+		w.WriteString("\r\n ")
+		content = content[cut:]
+		// the leading space counts against the continuation line
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(content)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,68 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	stamp := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	start := time.Date(2026, 3, 2, 18, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	events := []Event{
+		{
+			UID:         "workout-1@example",
+			Summary:     "Push day, heavy; bench",
+			Description: "line one\nline two",
+			Start:       start,
+			End:         start.Add(45 * time.Minute),
+		},
+		{
+			UID:     "planned-2-20260304@example",
+			Summary: "Legs",
+			Start:   time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+			End:     time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC),
+			AllDay:  true,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "Training", stamp, events))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	assert.Contains(t, out, "DTSTAMP:20260301T080000Z\r\n")
+	assert.Contains(t, out, "DTSTART:20260302T173000Z\r\nDTEND:20260302T181500Z\r\n")
+	assert.Contains(t, out, `SUMMARY:Push day\, heavy\; bench`+"\r\n")
+	assert.Contains(t, out, `DESCRIPTION:line one\nline two`+"\r\n")
+	assert.Contains(t, out, "DTSTART;VALUE=DATE:20260304\r\nDTEND;VALUE=DATE:20260305\r\n")
+	assert.Equal(t, 2, strings.Count(out, "BEGIN:VEVENT"))
+}
+
+func TestWriteFoldsLongLines(t *testing.T) {
+	summary := strings.Repeat("Kniebeuge über Kopf ", 10)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "Training", time.Now(), []Event{{UID: "x", Summary: summary}}))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	unfolded := ""
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), maxLineOctets)
+		assert.True(t, utf8.ValidString(line))
+
+		if strings.HasPrefix(line, " ") {
+			unfolded += line[1:]
+		} else {
+			unfolded += "\n" + line
+		}
+	}
+	assert.Contains(t, unfolded, "\nSUMMARY:"+summary+"\n")
+}
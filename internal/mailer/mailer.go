@@ -0,0 +1,79 @@
+// Package mailer delivers the emails the app sends to users. Messages are
+// rendered from the templates in templates/ and Mailer is the extension
+// point: SMTPMailer talks to a mail server, while LogMailer and FileMailer
+// stand in for one during local development.
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Message is an email to one recipient with a plain-text body and, if HTML
+// isn't empty, an HTML alternative.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(message Message) error
+}
+
+// LogMailer writes emails to a logger instead of sending them. Tokens in
+// the body are redacted, since logs are read by more people than the
+// recipient.
+type LogMailer struct {
+	logger *log.Logger
+}
+
+func NewLogMailer(logger *log.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// tokenPattern matches the unpadded base32 tokens that internal/tokens
+// generates.
+var tokenPattern = regexp.MustCompile(`\b[A-Z2-7]{26,}\b`)
+
+func (m *LogMailer) Send(message Message) error {
+	m.logger.Printf("MAIL to %s: %s\n%s", message.To, message.Subject, tokenPattern.ReplaceAllString(message.Text, "[REDACTED]"))
+	return nil
+}
+
+// FileMailer writes each email to its own .eml file in a directory, which
+// mail clients can open.
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]+`)
+
+func (m *FileMailer) Send(message Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(message.To, "_"))
+
+	file, err := os.OpenFile(filepath.Join(m.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = writeMessage(file, "", message, now)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
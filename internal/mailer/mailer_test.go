@@ -0,0 +1,163 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ruhan/internal/mailer/smtptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := NewFileMailer(dir)
+	require.NoError(t, err)
+
+	message := Message{To: "alice@example.com", Subject: "Reset your password", Text: "Hi alice,\n\nYour token is ABC."}
+	require.NoError(t, mailer.Send(message))
+	require.NoError(t, mailer.Send(message))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Contains(t, files[0].Name(), "alice@example.com")
+
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: alice@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: Reset your password\r\n")
+	assert.Contains(t, string(content), "Content-Type: text/plain; charset=utf-8\r\n")
+	assert.Contains(t, string(content), "\r\n\r\nHi alice,\r\n\r\nYour token is ABC.")
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewLogMailer(log.New(&buf, "", 0))
+
+	require.NoError(t, mailer.Send(Message{To: "bob@example.com", Subject: "Hello", Text: "Body", HTML: "<p>Body</p>"}))
+	assert.Equal(t, "MAIL to bob@example.com: Hello\nBody\n", buf.String())
+}
+
+func TestLogMailerRedactsTokens(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewLogMailer(log.New(&buf, "", 0))
+
+	token := "QKZ3V7JX2M4N6P5RSTUWYABCDEFGHIJKLMNOQ2345673ABCDEFGH"
+	require.NoError(t, mailer.Send(Message{To: "bob@example.com", Subject: "Reset your password", Text: "Your token:\n\n" + token + "\n\nIt expires in 30 minutes."}))
+	assert.NotContains(t, buf.String(), token)
+	assert.Contains(t, buf.String(), "Your token:\n\n[REDACTED]\n\nIt expires in 30 minutes.")
+}
+
+func TestNewMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		subject  string
+	}{
+		{name: "activation", template: "activation.tmpl", subject: "Confirm your email address"},
+		{name: "password reset", template: "password_reset.tmpl", subject: "Reset your password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := NewMessage("alice@example.com", tt.template, map[string]any{
+				"UserName":  "<alice>",
+				"Token":     "TOKEN123",
+				"ExpiresIn": "3 days",
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, "alice@example.com", message.To)
+			assert.Equal(t, tt.subject, message.Subject)
+			assert.True(t, strings.HasPrefix(message.Text, "Hi <alice>,"))
+			assert.Contains(t, message.Text, "TOKEN123")
+			assert.Contains(t, message.Text, "3 days")
+			assert.Contains(t, message.HTML, "Hi &lt;alice&gt;,")
+			assert.Contains(t, message.HTML, "TOKEN123")
+		})
+	}
+
+	_, err := NewMessage("alice@example.com", "missing.tmpl", nil)
+	assert.Error(t, err)
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server, err := smtptest.NewServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	host, rawPort, err := net.SplitHostPort(server.Addr)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(rawPort)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		username string
+		html     string
+	}{
+		{name: "plain text", username: ""},
+		{name: "with HTML and authentication", username: "mailer", html: "<p>Hi alice, your token is ABC.</p>"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := NewSMTPMailer(host, port, tt.username, "secret", "Workouts <no-reply@example.com>")
+			err := mailer.Send(Message{To: "alice@example.com", Subject: "Grüße", Text: "Hi alice,\nyour token is ABC.\n", HTML: tt.html})
+			require.NoError(t, err)
+
+			messages := server.Messages()
+			require.Len(t, messages, i+1)
+			received := messages[i]
+			assert.Equal(t, "no-reply@example.com", received.From)
+			assert.Equal(t, []string{"alice@example.com"}, received.To)
+			assert.Equal(t, tt.username, received.Username)
+
+			parsed, err := mail.ReadMessage(bytes.NewReader(received.Data))
+			require.NoError(t, err)
+			assert.Equal(t, "Workouts <no-reply@example.com>", parsed.Header.Get("From"))
+			subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+			require.NoError(t, err)
+			assert.Equal(t, "Grüße", subject)
+
+			mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			if tt.html == "" {
+				assert.Equal(t, "text/plain", mediaType)
+				return
+			}
+
+			assert.Equal(t, "multipart/alternative", mediaType)
+			parts := multipart.NewReader(parsed.Body, params["boundary"])
+			bodies := map[string]string{}
+			for {
+				part, err := parts.NextPart()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				body, err := io.ReadAll(part)
+				require.NoError(t, err)
+				bodies[strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0]] = string(body)
+			}
+			assert.Equal(t, "Hi alice,\nyour token is ABC.\n", bodies["text/plain"])
+			assert.Equal(t, tt.html, bodies["text/html"])
+		})
+	}
+}
+
+func TestEnvelopeAddress(t *testing.T) {
+	assert.Equal(t, "no-reply@example.com", envelopeAddress("Workouts <no-reply@example.com>"))
+	assert.Equal(t, "no-reply@example.com", envelopeAddress("no-reply@example.com"))
+}
@@ -0,0 +1,120 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// NewMessage renders the named template for the recipient. A template
+// defines "subject", "text" and optionally "html"; the HTML is rendered with
+// html/template so data is escaped.
+func NewMessage(to, name string, data any) (Message, error) {
+	path := "templates/" + name
+	message := Message{To: to}
+
+	textTemplate, err := template.New("").ParseFS(templateFS, path)
+	if err != nil {
+		return Message{}, err
+	}
+
+	subject := new(strings.Builder)
+	err = textTemplate.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return Message{}, err
+	}
+	message.Subject = strings.TrimSpace(subject.String())
+
+	text := new(strings.Builder)
+	err = textTemplate.ExecuteTemplate(text, "text", data)
+	if err != nil {
+		return Message{}, err
+	}
+	message.Text = strings.TrimSpace(text.String()) + "\n"
+
+	htmlTemplate, err := htmltemplate.New("").ParseFS(templateFS, path)
+	if err != nil {
+		return Message{}, err
+	}
+	if htmlTemplate.Lookup("html") != nil {
+		html := new(strings.Builder)
+		err = htmlTemplate.ExecuteTemplate(html, "html", data)
+		if err != nil {
+			return Message{}, err
+		}
+		message.HTML = html.String()
+	}
+
+	return message, nil
+}
+
+// writeMessage writes the message in RFC 5322 format, as multipart/
+// alternative when it has an HTML body. from is left out when empty.
+func writeMessage(w io.Writer, from string, message Message, date time.Time) error {
+	var buf bytes.Buffer
+
+	if from != "" {
+		buf.WriteString("From: " + from + "\r\n")
+	}
+	buf.WriteString("To: " + message.To + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Subject) + "\r\n")
+	buf.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		err := writeQuotedPrintable(&buf, message.Text)
+		if err != nil {
+			return err
+		}
+	} else {
+		parts := multipart.NewWriter(&buf)
+		buf.WriteString("Content-Type: multipart/alternative; boundary=" + parts.Boundary() + "\r\n\r\n")
+
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", message.Text},
+			{"text/html; charset=utf-8", message.HTML},
+		} {
+			pw, err := parts.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return err
+			}
+			err = writeQuotedPrintable(pw, part.body)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := parts.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	_, err := io.WriteString(qp, body)
+	if err != nil {
+		return err
+	}
+	return qp.Close()
+}
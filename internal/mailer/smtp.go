@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends emails through an SMTP server, authenticating with PLAIN
+// when a username is set. The server must offer TLS for that unless it runs
+// on localhost.
+type SMTPMailer struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+}
+
+func NewSMTPMailer(host string, port int, username, password, sender string) *SMTPMailer {
+	m := &SMTPMailer{addr: fmt.Sprintf("%s:%d", host, port), sender: sender}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	var buf bytes.Buffer
+	err := writeMessage(&buf, m.sender, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, envelopeAddress(m.sender), []string{message.To}, buf.Bytes())
+}
+
+// envelopeAddress returns the bare address of a sender like
+// "Workouts <no-reply@example.com>".
+func envelopeAddress(sender string) string {
+	start, end := strings.IndexByte(sender, '<'), strings.LastIndexByte(sender, '>')
+	if start >= 0 && end > start {
+		return sender[start+1 : end]
+	}
+	return sender
+}
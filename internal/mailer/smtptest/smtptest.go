@@ -0,0 +1,155 @@
+// Package smtptest runs a minimal SMTP server in-process that keeps the
+// messages it receives, so SMTPMailer can be tested without a mail server.
+// It speaks just enough SMTP for net/smtp: no TLS, and AUTH PLAIN accepts
+// any credentials.
+package smtptest
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is one mail transaction: the envelope and the raw message.
+type Message struct {
+	From string
+	To   []string
+	// Username is who authenticated, if anyone did.
+	Username string
+	Data     []byte
+}
+
+type Server struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewServer starts a server on a free port on the loopback interface.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Addr: listener.Addr().String(), listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Messages returns the messages received so far, oldest first.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops accepting connections and waits for open ones to finish.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(textproto.NewConn(conn))
+		}()
+	}
+}
+
+func (s *Server) handle(conn *textproto.Conn) {
+	var message Message
+	conn.PrintfLine("220 smtptest ESMTP")
+
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			conn.PrintfLine("250 smtptest")
+		case "EHLO":
+			conn.PrintfLine("250-smtptest")
+			conn.PrintfLine("250-8BITMIME")
+			conn.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			credentials, err := base64.StdEncoding.DecodeString(initial)
+			if !strings.EqualFold(mechanism, "PLAIN") || err != nil {
+				conn.PrintfLine("504 unsupported authentication")
+				continue
+			}
+			// authzid NUL authcid NUL password
+			parts := strings.Split(string(credentials), "\x00")
+			if len(parts) != 3 {
+				conn.PrintfLine("501 malformed credentials")
+				continue
+			}
+			message.Username = parts[1]
+			conn.PrintfLine("235 authenticated")
+		case "MAIL":
+			message.From = address(arg)
+			message.To = nil
+			conn.PrintfLine("250 OK")
+		case "RCPT":
+			message.To = append(message.To, address(arg))
+			conn.PrintfLine("250 OK")
+		case "DATA":
+			if message.From == "" || len(message.To) == 0 {
+				conn.PrintfLine("503 need MAIL and RCPT first")
+				continue
+			}
+			conn.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.Data = data
+
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+
+			message = Message{Username: message.Username}
+			conn.PrintfLine("250 OK")
+		case "RSET":
+			message = Message{Username: message.Username}
+			conn.PrintfLine("250 OK")
+		case "NOOP":
+			conn.PrintfLine("250 OK")
+		case "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+// address returns the address in an argument like "FROM:<a@example.com>".
+func address(arg string) string {
+	_, value, _ := strings.Cut(arg, ":")
+	value, _, _ = strings.Cut(strings.TrimSpace(value), " ")
+	return strings.Trim(value, "<>")
+}
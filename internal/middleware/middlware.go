@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ruhan/internal/store"
 	"github.com/ruhan/internal/tokens"
@@ -11,12 +13,21 @@ import (
 )
 
 type UseMiddleware struct {
-	UserStore store.UserStore
+	UserStore  store.UserStore
+	TokenStore store.TokenStore
+	Logger     *log.Logger
 }
 
 type contextKey string
 
 const USER_CONTEXT_KEY = contextKey("user")
+const SESSION_CONTEXT_KEY = contextKey("session")
+const API_KEY_CONTEXT_KEY = contextKey("api_key")
+const SCOPE_CHECKED_CONTEXT_KEY = contextKey("scope_checked")
+
+// tokenTouchInterval is how stale a token's last-used time can get before a
+// request updates it, to save a write on every request.
+const tokenTouchInterval = time.Minute
 
 func SetUser(req *http.Request, user *store.User) *http.Request {
 	ctx := context.WithValue(req.Context(), USER_CONTEXT_KEY, user)
@@ -31,6 +42,30 @@ func GetUser(req *http.Request) *store.User {
 	return user
 }
 
+func SetSession(req *http.Request, session *store.Session) *http.Request {
+	ctx := context.WithValue(req.Context(), SESSION_CONTEXT_KEY, session)
+	return req.WithContext(ctx)
+}
+
+// GetSession returns the session the request was authenticated with, or nil
+// for anonymous requests.
+func GetSession(req *http.Request) *store.Session {
+	session, _ := req.Context().Value(SESSION_CONTEXT_KEY).(*store.Session)
+	return session
+}
+
+func SetAPIKey(req *http.Request, key *store.APIKey) *http.Request {
+	ctx := context.WithValue(req.Context(), API_KEY_CONTEXT_KEY, key)
+	return req.WithContext(ctx)
+}
+
+// GetAPIKey returns the API key the request was authenticated with, or nil
+// if it wasn't made with one.
+func GetAPIKey(req *http.Request) *store.APIKey {
+	key, _ := req.Context().Value(API_KEY_CONTEXT_KEY).(*store.APIKey)
+	return key
+}
+
 func (u *UseMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// within this anonymouse function
@@ -52,7 +87,27 @@ func (u *UseMiddleware) Authenticate(next http.Handler) http.Handler {
 		}
 
 		token := headerParts[1]
-		user, err := u.UserStore.GetUserToken(tokens.ScopeAuth, token)
+		if strings.HasPrefix(token, tokens.APIKeyPrefix) {
+			user, key, err := u.UserStore.GetUserAPIKey(token)
+			if err != nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
+				return
+			}
+
+			if user == nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "token expired or invalid"})
+				return
+			}
+
+			u.touchToken(key.ID, key.LastUsedAt)
+
+			r = SetUser(r, user)
+			r = SetAPIKey(r, key)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, session, err := u.UserStore.GetUserSession(token)
 		if err != nil {
 			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
 			return
@@ -63,11 +118,30 @@ func (u *UseMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		u.touchToken(session.TokenID, session.LastUsedAt)
+
 		r = SetUser(r, user)
+		r = SetSession(r, session)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// touchToken updates a token's last-used time in the background so the
+// request doesn't wait on the write.
+func (u *UseMiddleware) touchToken(tokenID int64, lastUsedAt *time.Time) {
+	now := time.Now()
+	if lastUsedAt != nil && now.Sub(*lastUsedAt) < tokenTouchInterval {
+		return
+	}
+
+	go func() {
+		err := u.TokenStore.TouchToken(tokenID, now)
+		if err != nil {
+			u.Logger.Printf("ERROR: TouchToken %v", err)
+		}
+	}()
+}
+
 func (u *UseMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		user := GetUser(req)
@@ -77,6 +151,47 @@ func (u *UseMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// API keys only reach routes that declare a scope with RequireScope
+		if GetAPIKey(req) != nil && req.Context().Value(SCOPE_CHECKED_CONTEXT_KEY) == nil {
+			utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "API keys cannot access this route"})
+			return
+		}
+
+		next.ServeHTTP(res, req)
+	})
+}
+
+// RequireScope lets requests made with an API key through only if the key
+// has the permission. Session tokens carry every permission. It goes in
+// front of RequireUser, which turns API keys away from routes without a
+// scope.
+func (u *UseMiddleware) RequireScope(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		key := GetAPIKey(req)
+
+		if key != nil {
+			if !key.HasPermission(permission) {
+				utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "this API key lacks the " + permission + " permission"})
+				return
+			}
+			req = req.WithContext(context.WithValue(req.Context(), SCOPE_CHECKED_CONTEXT_KEY, true))
+		}
+
+		next.ServeHTTP(res, req)
+	})
+}
+
+// RequireVerifiedUser is RequireUser for routes that change data, which
+// also need a verified email address.
+func (u *UseMiddleware) RequireVerifiedUser(next http.HandlerFunc) http.HandlerFunc {
+	return u.RequireUser(func(res http.ResponseWriter, req *http.Request) {
+		user := GetUser(req)
+
+		if !user.IsVerified() {
+			utils.WriteJSON(res, http.StatusForbidden, utils.Envelope{"error": "you must verify your email address to access this route"})
+			return
+		}
+
 		next.ServeHTTP(res, req)
 	})
 }
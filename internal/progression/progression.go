@@ -0,0 +1,115 @@
+package progression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ruhan/internal/store"
+)
+
+// Validate reports whether a rule carries the parameters its type needs.
+func Validate(rule store.ProgressionRule) error {
+	switch rule.Type {
+	case store.ProgressionLinearLoad:
+		if rule.Increment <= 0 {
+			return errors.New("linear_load needs a positive increment")
+		}
+	case store.ProgressionDoubleProgression:
+		if rule.MinReps < 1 || rule.MaxReps <= rule.MinReps {
+			return errors.New("double_progression needs 1 <= min_reps < max_reps")
+		}
+		if rule.Increment <= 0 {
+			return errors.New("double_progression needs a positive increment")
+		}
+	case store.ProgressionPercentOneRepMax:
+		if len(rule.Percentages) == 0 {
+			return errors.New("percent_1rm needs at least one percentage")
+		}
+		for _, percentage := range rule.Percentages {
+			if percentage <= 0 || percentage > 120 {
+				return errors.New("percent_1rm percentages must be between 0 and 120")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown progression type %q", rule.Type)
+	}
+
+	if rule.RoundTo < 0 {
+		return errors.New("round_to cannot be negative")
+	}
+	return nil
+}
+
+func roundTo(value, step float64) float64 {
+	if step <= 0 {
+		return math.Round(value*100) / 100
+	}
+	return math.Round(value/step) * step
+}
+
+func applies(rule store.ProgressionRule, entry store.WorkoutEntry) bool {
+	return rule.ExerciseID == nil || (entry.ExerciseID != nil && *entry.ExerciseID == *rule.ExerciseID)
+}
+
+// Prescribe returns the entry as it should be performed weekOffset weeks
+// into a program (0 for the first week). oneRepMaxes holds the user's best
+// estimated one-rep max per exercise for percent_1rm rules; an exercise
+// without one keeps the template weight and says so in the notes.
+func Prescribe(entry store.WorkoutEntry, rules []store.ProgressionRule, weekOffset int, oneRepMaxes map[int]float64) store.WorkoutEntry {
+	for _, rule := range rules {
+		if !applies(rule, entry) {
+			continue
+		}
+
+		switch rule.Type {
+		case store.ProgressionLinearLoad:
+			if entry.Weight != nil {
+				weight := roundTo(*entry.Weight+rule.Increment*float64(weekOffset), rule.RoundTo)
+				entry.Weight = &weight
+			}
+
+		case store.ProgressionDoubleProgression:
+			steps := rule.MaxReps - rule.MinReps + 1
+			reps := rule.MinReps + weekOffset%steps
+			entry.Reps = &reps
+			entry.DurationSeconds = nil
+
+			if entry.Weight != nil {
+				weight := roundTo(*entry.Weight+rule.Increment*float64(weekOffset/steps), rule.RoundTo)
+				entry.Weight = &weight
+			}
+
+		case store.ProgressionPercentOneRepMax:
+			percentage := rule.Percentages[min(weekOffset, len(rule.Percentages)-1)]
+			if rule.Reps > 0 {
+				reps := rule.Reps
+				entry.Reps = &reps
+				entry.DurationSeconds = nil
+			}
+
+			oneRepMax := 0.0
+			if entry.ExerciseID != nil {
+				oneRepMax = oneRepMaxes[*entry.ExerciseID]
+			}
+
+			note := fmt.Sprintf("%g%% of 1RM", percentage)
+			if oneRepMax > 0 {
+				step := rule.RoundTo
+				if step == 0 {
+					step = 2.5
+				}
+				weight := roundTo(oneRepMax*percentage/100, step)
+				entry.Weight = &weight
+			} else {
+				note += " (no 1RM on record, using template weight)"
+			}
+
+			if entry.Notes != "" {
+				note = entry.Notes + " - " + note
+			}
+			entry.Notes = note
+		}
+	}
+	return entry
+}
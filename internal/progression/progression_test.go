@@ -0,0 +1,96 @@
+package progression
+
+import (
+	"testing"
+
+	"github.com/ruhan/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(i int) *int { return &i }
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestPrescribe(t *testing.T) {
+	squatID := 7
+	squat := store.WorkoutEntry{ExerciseID: &squatID, ExerciseName: "Squat", Sets: 3, Reps: intPtr(5), Weight: floatPtr(100)}
+
+	tests := []struct {
+		name       string
+		rule       store.ProgressionRule
+		weekOffset int
+		maxes      map[int]float64
+		wantReps   int
+		wantWeight float64
+	}{
+		{
+			name:       "linear load first week is the template",
+			rule:       store.ProgressionRule{Type: store.ProgressionLinearLoad, Increment: 2.5},
+			weekOffset: 0,
+			wantReps:   5, wantWeight: 100,
+		},
+		{
+			name:       "linear load adds the increment every week",
+			rule:       store.ProgressionRule{Type: store.ProgressionLinearLoad, Increment: 2.5},
+			weekOffset: 3,
+			wantReps:   5, wantWeight: 107.5,
+		},
+		{
+			name:       "double progression adds reps first",
+			rule:       store.ProgressionRule{Type: store.ProgressionDoubleProgression, MinReps: 8, MaxReps: 12, Increment: 5},
+			weekOffset: 4,
+			wantReps:   12, wantWeight: 100,
+		},
+		{
+			name:       "double progression then raises the weight",
+			rule:       store.ProgressionRule{Type: store.ProgressionDoubleProgression, MinReps: 8, MaxReps: 12, Increment: 5},
+			weekOffset: 5,
+			wantReps:   8, wantWeight: 105,
+		},
+		{
+			name:       "percent of 1rm rounds to the plate step",
+			rule:       store.ProgressionRule{Type: store.ProgressionPercentOneRepMax, Percentages: []float64{70, 75, 80}, Reps: 3},
+			weekOffset: 1,
+			maxes:      map[int]float64{squatID: 143},
+			wantReps:   3, wantWeight: 107.5,
+		},
+		{
+			name:       "percent of 1rm holds the last percentage",
+			rule:       store.ProgressionRule{Type: store.ProgressionPercentOneRepMax, Percentages: []float64{70, 75, 80}, RoundTo: 1},
+			weekOffset: 6,
+			maxes:      map[int]float64{squatID: 150},
+			wantReps:   5, wantWeight: 120,
+		},
+		{
+			name:       "percent of 1rm without a max keeps the template weight",
+			rule:       store.ProgressionRule{Type: store.ProgressionPercentOneRepMax, Percentages: []float64{70}},
+			weekOffset: 0,
+			wantReps:   5, wantWeight: 100,
+		},
+		{
+			name:       "rules for another exercise are ignored",
+			rule:       store.ProgressionRule{Type: store.ProgressionLinearLoad, ExerciseID: intPtr(99), Increment: 10},
+			weekOffset: 2,
+			wantReps:   5, wantWeight: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, Validate(tt.rule))
+
+			got := Prescribe(squat, []store.ProgressionRule{tt.rule}, tt.weekOffset, tt.maxes)
+			assert.Equal(t, tt.wantReps, *got.Reps)
+			assert.InDelta(t, tt.wantWeight, *got.Weight, 0.001)
+			assert.InDelta(t, 100, *squat.Weight, 0.001, "template entry must not be modified")
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.Error(t, Validate(store.ProgressionRule{Type: "magic"}))
+	assert.Error(t, Validate(store.ProgressionRule{Type: store.ProgressionLinearLoad}))
+	assert.Error(t, Validate(store.ProgressionRule{Type: store.ProgressionDoubleProgression, MinReps: 10, MaxReps: 8, Increment: 2.5}))
+	assert.Error(t, Validate(store.ProgressionRule{Type: store.ProgressionPercentOneRepMax}))
+}
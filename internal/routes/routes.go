@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/ruhan/internal/app"
+	"github.com/ruhan/internal/tokens"
 )
 
 func SetupRoutes(app *app.Application) *chi.Mux {
@@ -11,15 +12,79 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 	r.Group(func(r chi.Router) {
 		r.Use(app.Middleware.Authenticate)
 
-		r.Get("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleGetWorkoutById))
-		r.Post("/workouts", app.Middleware.RequireUser(app.WorkoutHandler.HandleCreateOut))
-		r.Put("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleUpdateWorkoutById))
-		r.Delete("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleDeleteWorkoutById))
+		r.Get("/workouts", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.WorkoutHandler.HandleListWorkouts)))
+		r.Get("/workouts/search", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.WorkoutHandler.HandleSearchWorkouts)))
+		r.Post("/workouts/import", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.ImportHandler.HandleImportWorkouts)))
+		r.Post("/workouts/import/gpx", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.TrackHandler.HandleImportGPX)))
+		r.Post("/workouts/import/tcx", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.TrackHandler.HandleImportTCX)))
+		r.Post("/workouts/import/fit", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.TrackHandler.HandleImportFIT)))
+		r.Get("/workouts/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.WorkoutHandler.HandleGetWorkoutById)))
+		r.Post("/workouts", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.WorkoutHandler.HandleCreateOut)))
+		r.Put("/workouts/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.WorkoutHandler.HandleUpdateWorkoutById)))
+		r.Delete("/workouts/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.WorkoutHandler.HandleDeleteWorkoutById)))
+		r.Get("/workouts/{id}/track", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.TrackHandler.HandleGetWorkoutTrack)))
+		r.Post("/workouts/{id}/template", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.TemplateHandler.HandleSaveWorkoutAsTemplate)))
+
+		r.Get("/templates", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.TemplateHandler.HandleListTemplates)))
+		r.Post("/templates", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.TemplateHandler.HandleCreateTemplate)))
+		r.Get("/templates/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.TemplateHandler.HandleGetTemplateByID)))
+		r.Delete("/templates/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.TemplateHandler.HandleDeleteTemplate)))
+		r.Post("/templates/{id}/instantiate", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.TemplateHandler.HandleInstantiateTemplate)))
+
+		r.Get("/programs", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.ProgramHandler.HandleListPrograms)))
+		r.Post("/programs", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.ProgramHandler.HandleCreateProgram)))
+		r.Get("/programs/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.ProgramHandler.HandleGetProgramByID)))
+		r.Post("/programs/{id}/enroll", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.ProgramHandler.HandleEnroll)))
+		r.Get("/programs/enrollments", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.ProgramHandler.HandleListEnrollments)))
+		r.Get("/programs/enrollments/{id}/today", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.ProgramHandler.HandleGetToday)))
+		r.Post("/programs/enrollments/{id}/today", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.ProgramHandler.HandleLogToday)))
+
+		r.Get("/calendar", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.CalendarHandler.HandleGetCalendar)))
+		r.Post("/planned-workouts", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.CalendarHandler.HandleCreatePlannedWorkout)))
+		r.Get("/planned-workouts/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.CalendarHandler.HandleGetPlannedWorkoutByID)))
+		r.Delete("/planned-workouts/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.CalendarHandler.HandleDeletePlannedWorkout)))
+		r.Post("/planned-workouts/{id}/complete", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.CalendarHandler.HandleCompletePlannedWorkout)))
+
+		r.Get("/exercises", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.ExerciseHandler.HandleListExercises)))
+		r.Get("/exercises/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.ExerciseHandler.HandleGetExerciseByID)))
+		r.Post("/exercises", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.ExerciseHandler.HandleCreateExercise)))
+		r.Put("/exercises/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.ExerciseHandler.HandleUpdateExercise)))
+		r.Delete("/exercises/{id}", app.Middleware.RequireScope(tokens.PermissionWorkoutsWrite, app.Middleware.RequireVerifiedUser(app.ExerciseHandler.HandleDeleteExercise)))
+		r.Get("/exercises/{id}/records", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.RecordHandler.HandleListExerciseRecords)))
+
+		r.Get("/users/me", app.Middleware.RequireScope(tokens.PermissionProfileRead, app.Middleware.RequireUser(app.UserHandler.HandleGetMe)))
+		r.Patch("/users/me", app.Middleware.RequireScope(tokens.PermissionProfileWrite, app.Middleware.RequireUser(app.UserHandler.HandleUpdateMe)))
+		r.Get("/users/{username}", app.UserHandler.HandleGetUserProfile)
+		r.Get("/users/me/records", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.RecordHandler.HandleListMyRecords)))
+		r.Get("/users/me/stats", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.StatsHandler.HandleGetMyStats)))
+		r.Get("/users/me/streaks", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.StatsHandler.HandleGetMyStreaks)))
+		r.Get("/users/me/export", app.Middleware.RequireScope(tokens.PermissionWorkoutsRead, app.Middleware.RequireUser(app.ExportHandler.HandleExportMyData)))
+		r.Post("/users/me/export-archive", app.Middleware.RequireUser(app.AccountHandler.HandleRequestExportArchive))
+		r.Get("/users/me/export-archive/{id}", app.Middleware.RequireUser(app.AccountHandler.HandleGetExportArchive))
+		r.Get("/users/me/export-archive/{id}/download", app.Middleware.RequireUser(app.AccountHandler.HandleDownloadExportArchive))
+		r.Delete("/users/me", app.Middleware.RequireUser(app.AccountHandler.HandleDeleteAccount))
+		r.Post("/users/me/deletion/cancel", app.Middleware.RequireUser(app.AccountHandler.HandleCancelDeletion))
+		r.Put("/users/me/password", app.Middleware.RequireUser(app.PasswordHandler.HandleChangePassword))
+		r.Get("/users/me/sessions", app.Middleware.RequireUser(app.TokenHandler.HandleListSessions))
+		r.Delete("/users/me/sessions/{id}", app.Middleware.RequireUser(app.TokenHandler.HandleDeleteSession))
+		r.Delete("/tokens/authentication", app.Middleware.RequireUser(app.TokenHandler.HandleDeleteToken))
+		r.Get("/users/me/api-keys", app.Middleware.RequireUser(app.APIKeyHandler.HandleListAPIKeys))
+		r.Post("/users/me/api-keys", app.Middleware.RequireVerifiedUser(app.APIKeyHandler.HandleCreateAPIKey))
+		r.Delete("/users/me/api-keys/{id}", app.Middleware.RequireUser(app.APIKeyHandler.HandleDeleteAPIKey))
+		r.Put("/users/me/settings", app.Middleware.RequireScope(tokens.PermissionProfileWrite, app.Middleware.RequireVerifiedUser(app.UserHandler.HandleUpdateTrainingSettings)))
+		r.Post("/users/me/calendar-feed", app.Middleware.RequireVerifiedUser(app.CalendarHandler.HandleCreateCalendarFeed))
+		r.Delete("/users/me/calendar-feed", app.Middleware.RequireVerifiedUser(app.CalendarHandler.HandleRevokeCalendarFeed))
 	})
 
 	r.Get("/health", app.HealthCheck)
 	r.Post("/users", app.UserHandler.HandleRegisterUser)
 	r.Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
+	r.Post("/tokens/refresh", app.TokenHandler.HandleRefreshToken)
+	r.Post("/tokens/password-reset", app.PasswordHandler.HandleRequestPasswordReset)
+	r.Put("/users/password", app.PasswordHandler.HandleResetPassword)
+	r.Post("/tokens/activation", app.UserHandler.HandleRequestActivation)
+	r.Put("/users/activate", app.UserHandler.HandleActivateUser)
+	r.Get("/calendar/{feedToken}.ics", app.CalendarHandler.HandleGetCalendarFeed)
 
 	return r
 }
@@ -0,0 +1,290 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used for
+// planned workouts: DAILY, WEEKLY and MONTHLY frequencies with INTERVAL,
+// BYDAY, BYMONTHDAY, COUNT and UNTIL. Rules work on calendar dates, which
+// are represented as midnight UTC.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+type Rule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO,WE,FR". An optional
+// "RRULE:" prefix is accepted.
+func Parse(raw string) (*Rule, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(strings.ToUpper(raw), "RRULE:")
+	if raw == "" {
+		return nil, errors.New("rrule is empty")
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(raw, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("rrule part %q is not KEY=VALUE", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("rrule repeats %s", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case Daily, Weekly, Monthly:
+				rule.Freq = value
+			default:
+				return nil, fmt.Errorf("rrule FREQ must be DAILY, WEEKLY or MONTHLY, got %s", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, errors.New("rrule INTERVAL must be a positive integer")
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, errors.New("rrule COUNT must be a positive integer")
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					return nil, fmt.Errorf("rrule BYDAY %q is not a weekday code (MO..SU)", code)
+				}
+				// repeated days would repeat occurrences
+				if !containsWeekday(rule.ByDay, day) {
+					rule.ByDay = append(rule.ByDay, day)
+				}
+			}
+		case "BYMONTHDAY":
+			for _, raw := range strings.Split(value, ",") {
+				day, err := strconv.Atoi(raw)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return nil, fmt.Errorf("rrule BYMONTHDAY %q must be between 1 and 31 or -31 and -1", raw)
+				}
+				if !slices.Contains(rule.ByMonthDay, day) {
+					rule.ByMonthDay = append(rule.ByMonthDay, day)
+				}
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, errors.New("rrule only supports WKST=MO")
+			}
+		default:
+			return nil, fmt.Errorf("rrule %s is not supported", key)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return nil, errors.New("rrule FREQ is required")
+	case rule.Count > 0 && rule.Until != nil:
+		return nil, errors.New("rrule cannot have both COUNT and UNTIL")
+	case len(rule.ByMonthDay) > 0 && rule.Freq != Monthly:
+		return nil, errors.New("rrule BYMONTHDAY is only supported with FREQ=MONTHLY")
+	case len(rule.ByDay) > 0 && rule.Freq == Monthly:
+		return nil, errors.New("rrule BYDAY is not supported with FREQ=MONTHLY")
+	}
+
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if len(value) >= 8 {
+		date, err := time.Parse("20060102", value[:8])
+		if err == nil && (len(value) == 8 || value[8] == 'T') {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.New("rrule UNTIL must be a date (YYYYMMDD)")
+}
+
+// String renders the rule in canonical form, leaving out defaults.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			for code, weekday := range weekdayCodes {
+				if weekday == day {
+					codes[i] = code
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the occurrences of a series starting on start that fall
+// within from and to (inclusive), oldest first. Only dates matching the
+// rule count as occurrences, so a start date off the rule is skipped.
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	start, from, to = dateOf(start), dateOf(from), dateOf(to)
+	if r.Until != nil && r.Until.Before(to) {
+		to = *r.Until
+	}
+
+	occurrences := []time.Time{}
+	emitted := 0
+
+	for period := 0; ; period++ {
+		periodStart, candidates := r.period(start, period)
+		if periodStart.After(to) {
+			return occurrences
+		}
+
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				continue
+			}
+			if candidate.After(to) {
+				return occurrences
+			}
+
+			emitted++
+			if !candidate.Before(from) {
+				occurrences = append(occurrences, candidate)
+			}
+			if r.Count > 0 && emitted == r.Count {
+				return occurrences
+			}
+		}
+	}
+}
+
+// Occurs reports whether date is an occurrence of the series starting on
+// start.
+func (r *Rule) Occurs(start, date time.Time) bool {
+	return len(r.Between(start, date, date)) == 1
+}
+
+// period returns the first day of the n-th period of the series and the
+// candidate dates within it, in order.
+func (r *Rule) period(start time.Time, n int) (time.Time, []time.Time) {
+	switch r.Freq {
+	case Weekly:
+		monday := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+		week := monday.AddDate(0, 0, 7*r.Interval*n)
+
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+
+		candidates := make([]time.Time, 0, len(days))
+		for _, day := range days {
+			candidates = append(candidates, week.AddDate(0, 0, (int(day)+6)%7))
+		}
+		sortDates(candidates)
+		candidates = slices.CompactFunc(candidates, time.Time.Equal)
+		return week, candidates
+
+	case Monthly:
+		month := time.Date(start.Year(), start.Month()+time.Month(r.Interval*n), 1, 0, 0, 0, 0, time.UTC)
+		daysInMonth := month.AddDate(0, 1, -1).Day()
+
+		days := r.ByMonthDay
+		if len(days) == 0 {
+			days = []int{start.Day()}
+		}
+
+		candidates := make([]time.Time, 0, len(days))
+		for _, day := range days {
+			if day < 0 {
+				day = daysInMonth + day + 1
+			}
+			// months without that day are skipped, as RFC 5545 does
+			if day < 1 || day > daysInMonth {
+				continue
+			}
+			candidates = append(candidates, month.AddDate(0, 0, day-1))
+		}
+		sortDates(candidates)
+		// 31 and -1 can name the same day
+		candidates = slices.CompactFunc(candidates, time.Time.Equal)
+		return month, candidates
+
+	default:
+		day := start.AddDate(0, 0, r.Interval*n)
+		if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, day.Weekday()) {
+			return day, nil
+		}
+		return day, []time.Time{day}
+	}
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func sortDates(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func formatDates(dates []time.Time) []string {
+	out := []string{}
+	for _, d := range dates {
+		out = append(out, d.Format(time.DateOnly))
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "weekly by day", raw: "FREQ=WEEKLY;BYDAY=MO,WE,FR", want: "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{name: "prefix and lower case", raw: "rrule:freq=daily;interval=2", want: "FREQ=DAILY;INTERVAL=2"},
+		{name: "default interval is dropped", raw: "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=-1", want: "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{name: "until with time", raw: "FREQ=DAILY;UNTIL=20260131T235959Z", want: "FREQ=DAILY;UNTIL=20260131"},
+		{name: "repeated weekdays", raw: "FREQ=WEEKLY;BYDAY=MO,MO,WE", want: "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{name: "repeated month days", raw: "FREQ=MONTHLY;BYMONTHDAY=1,15,1", want: "FREQ=MONTHLY;BYMONTHDAY=1,15"},
+		{name: "freq is required", raw: "BYDAY=MO", wantErr: true},
+		{name: "unsupported freq", raw: "FREQ=YEARLY", wantErr: true},
+		{name: "unsupported part", raw: "FREQ=WEEKLY;BYSETPOS=1", wantErr: true},
+		{name: "bad weekday", raw: "FREQ=WEEKLY;BYDAY=1MO", wantErr: true},
+		{name: "count and until", raw: "FREQ=DAILY;COUNT=3;UNTIL=20260101", wantErr: true},
+		{name: "bymonthday needs monthly", raw: "FREQ=WEEKLY;BYMONTHDAY=1", wantErr: true},
+		{name: "zero interval", raw: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rule.String())
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		start string
+		from  string
+		to    string
+		want  []string
+	}{
+		{
+			name:  "mon wed fri",
+			raw:   "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			start: "2026-03-04", from: "2026-03-01", to: "2026-03-15",
+			want: []string{"2026-03-04", "2026-03-06", "2026-03-09", "2026-03-11", "2026-03-13"},
+		},
+		{
+			name:  "every other week on the start weekday",
+			raw:   "FREQ=WEEKLY;INTERVAL=2",
+			start: "2026-03-03", from: "2026-03-01", to: "2026-03-31",
+			want: []string{"2026-03-03", "2026-03-17", "2026-03-31"},
+		},
+		{
+			name:  "count is counted from the start, not the window",
+			raw:   "FREQ=DAILY;COUNT=5",
+			start: "2026-03-01", from: "2026-03-04", to: "2026-03-31",
+			want: []string{"2026-03-04", "2026-03-05"},
+		},
+		{
+			name:  "until is inclusive",
+			raw:   "FREQ=DAILY;INTERVAL=3;UNTIL=20260307",
+			start: "2026-03-01", from: "2026-03-01", to: "2026-03-31",
+			want: []string{"2026-03-01", "2026-03-04", "2026-03-07"},
+		},
+		{
+			name:  "daily filtered by weekday",
+			raw:   "FREQ=DAILY;BYDAY=SA,SU",
+			start: "2026-03-01", from: "2026-03-01", to: "2026-03-10",
+			want: []string{"2026-03-01", "2026-03-07", "2026-03-08"},
+		},
+		{
+			name:  "monthly skips months without the day",
+			raw:   "FREQ=MONTHLY",
+			start: "2026-01-31", from: "2026-01-01", to: "2026-05-31",
+			want: []string{"2026-01-31", "2026-03-31", "2026-05-31"},
+		},
+		{
+			name:  "last day of the month",
+			raw:   "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: "2026-01-15", from: "2026-01-01", to: "2026-03-31",
+			want: []string{"2026-01-31", "2026-02-28", "2026-03-31"},
+		},
+		{
+			name:  "repeated weekdays occur once",
+			raw:   "FREQ=WEEKLY;BYDAY=MO,MO;COUNT=3",
+			start: "2026-03-02", from: "2026-03-01", to: "2026-03-31",
+			want: []string{"2026-03-02", "2026-03-09", "2026-03-16"},
+		},
+		{
+			name:  "month days naming the same day occur once",
+			raw:   "FREQ=MONTHLY;BYMONTHDAY=31,-1",
+			start: "2026-01-01", from: "2026-01-01", to: "2026-02-28",
+			want: []string{"2026-01-31", "2026-02-28"},
+		},
+		{
+			name:  "window before the start",
+			raw:   "FREQ=DAILY",
+			start: "2026-03-01", from: "2026-02-01", to: "2026-02-28",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.raw)
+			require.NoError(t, err)
+
+			got := rule.Between(date(tt.start), date(tt.from), date(tt.to))
+			assert.Equal(t, tt.want, formatDates(got))
+		})
+	}
+}
+
+func TestOccurs(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=MO,WE,FR")
+	require.NoError(t, err)
+
+	assert.True(t, rule.Occurs(date("2026-03-02"), date("2026-03-13")))
+	assert.False(t, rule.Occurs(date("2026-03-02"), date("2026-03-14")))
+	assert.False(t, rule.Occurs(date("2026-03-02"), date("2026-02-27")))
+}
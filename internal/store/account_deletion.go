@@ -0,0 +1,171 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+var ErrDeletionScheduled = errors.New("account deletion is already scheduled")
+
+// ScheduleDeletion marks the account for deletion at the given time, or
+// returns ErrDeletionScheduled when it already is.
+func (s *PostgresUserStore) ScheduleDeletion(user *User, at time.Time) error {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_for = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deletion_scheduled_for IS NULL
+		RETURNING deletion_scheduled_for, updated_at
+	`
+	err := s.db.QueryRow(query, user.ID, at).Scan(&user.DeletionScheduledFor, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrDeletionScheduled
+	}
+	return err
+}
+
+// CancelDeletion keeps the account, returning sql.ErrNoRows when no deletion
+// was scheduled.
+func (s *PostgresUserStore) CancelDeletion(userID int) error {
+	result, err := s.db.Exec(`
+		UPDATE users
+		SET deletion_scheduled_for = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deletion_scheduled_for IS NOT NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListDueDeletions returns the accounts whose grace period is over.
+func (s *PostgresUserStore) ListDueDeletions() ([]int, error) {
+	rows, err := s.db.Query(`
+		SELECT id
+		FROM users
+		WHERE deletion_scheduled_for <= CURRENT_TIMESTAMP
+		ORDER BY deletion_scheduled_for
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeUser deletes an account whose grace period is over, returning
+// sql.ErrNoRows when the deletion was cancelled in the meantime.
+//
+// Deleting a user cascades to everything they own, including their public
+// programs, which would take other users' enrollments with them. An account
+// with programs others are enrolled in is anonymized instead: the shared
+// programs and their templates stay, everything else is deleted and the
+// profile is scrubbed. Either way only an anonymous audit event remains.
+func (s *PostgresUserStore) PurgeUser(userID int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var shared bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM program_enrollments e
+			INNER JOIN programs p ON p.id = e.program_id
+			WHERE p.user_id = u.id AND e.user_id <> u.id
+		)
+		FROM users u
+		WHERE u.id = $1 AND u.deletion_scheduled_for <= CURRENT_TIMESTAMP
+		FOR UPDATE OF u
+	`, userID).Scan(&shared)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM audit_events WHERE user_id = $1`, userID)
+	if err != nil {
+		return false, err
+	}
+
+	event := AuditAccountDeleted
+	if shared {
+		event = AuditAccountAnonymized
+		err = anonymizeUser(tx, userID)
+	} else {
+		_, err = tx.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO audit_events (event) VALUES ($1)`, event)
+	if err != nil {
+		return false, err
+	}
+
+	return shared, tx.Commit()
+}
+
+func anonymizeUser(tx *sql.Tx, userID int) error {
+	// nobody can log in as the account again: the password is random and
+	// immediately forgotten
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	var scrambled password
+	err = scrambled.Set(base64.RawURLEncoding.EncodeToString(secret))
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		`DELETE FROM workouts WHERE user_id = $1`,
+		`DELETE FROM planned_workouts WHERE user_id = $1`,
+		`DELETE FROM program_enrollments WHERE user_id = $1`,
+		`DELETE FROM programs p WHERE p.user_id = $1
+			AND NOT EXISTS (SELECT 1 FROM program_enrollments e WHERE e.program_id = p.id)`,
+		`DELETE FROM workout_templates t WHERE t.user_id = $1
+			AND NOT EXISTS (SELECT 1 FROM program_slots s WHERE s.template_id = t.id)`,
+		`DELETE FROM tokens WHERE user_id = $1`,
+		`DELETE FROM export_archives WHERE user_id = $1`,
+	}
+	for _, statement := range statements {
+		_, err = tx.Exec(statement, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET username = 'deleted-' || id, email = 'deleted-' || id || '@deleted.invalid', password_hash = $2,
+			bio = NULL, timezone = 'UTC', rest_days = '{}', profile_visibility = 'private',
+			deletion_scheduled_for = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, userID, scrambled.hash)
+	return err
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountDeletion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+	auditStore := NewPostgresAuditStore(db)
+	user := createTestUser(t, db, "deleted_user")
+
+	_, err := workoutStore.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "Legs", DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Kind: EntryKindStrength, OrderIndex: 1, Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100)},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, auditStore.RecordEvent(&AuditEvent{UserID: user.ID, Event: AuditLogin, IPAddress: "192.0.2.1"}))
+
+	// within the grace period the deletion can be cancelled
+	require.NoError(t, userStore.ScheduleDeletion(user, time.Now().Add(time.Hour)))
+	require.NotNil(t, user.DeletionScheduledFor)
+	assert.ErrorIs(t, userStore.ScheduleDeletion(user, time.Now()), ErrDeletionScheduled)
+
+	due, err := userStore.ListDueDeletions()
+	require.NoError(t, err)
+	assert.NotContains(t, due, user.ID)
+
+	require.NoError(t, userStore.CancelDeletion(user.ID))
+	assert.ErrorIs(t, userStore.CancelDeletion(user.ID), sql.ErrNoRows)
+
+	_, err = userStore.PurgeUser(user.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// once it is over the account and everything it owns is gone
+	user.DeletionScheduledFor = nil
+	require.NoError(t, userStore.ScheduleDeletion(user, time.Now().Add(-time.Minute)))
+	due, err = userStore.ListDueDeletions()
+	require.NoError(t, err)
+	assert.Contains(t, due, user.ID)
+
+	anonymized, err := userStore.PurgeUser(user.ID)
+	require.NoError(t, err)
+	assert.False(t, anonymized)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE user_id = $1`, user.ID).Scan(&count))
+	assert.Equal(t, 0, count)
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM users WHERE id = $1`, user.ID).Scan(&count))
+	assert.Equal(t, 0, count)
+
+	events, err := auditStore.ListEvents(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/ruhan/internal/tokens"
+)
+
+// APIKey is a token a user creates for scripts and integrations. Unlike a
+// session it can only do what its permissions allow.
+type APIKey struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	Expiry      time.Time  `json:"expiry"`
+}
+
+func (k *APIKey) HasPermission(permission string) bool {
+	return slices.Contains(k.Permissions, permission)
+}
+
+// CreateAPIKey stores a token made by tokens.GenerateAPIKey under the key's
+// name and permissions, filling in the key's ID and timestamps.
+func (t *PostgersTokenStore) CreateAPIKey(key *APIKey, token *tokens.Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope, name, permissions)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := t.db.QueryRow(query, token.Hash, token.UserID, token.Expiry, tokens.ScopeAPIKey, key.Name, nonNilStrings(key.Permissions)).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return err
+	}
+	token.ID = key.ID
+	key.Expiry = token.Expiry
+	return nil
+}
+
+// ListAPIKeys returns the user's unexpired API keys, newest first.
+func (t *PostgersTokenStore) ListAPIKeys(userID int) ([]*APIKey, error) {
+	rows, err := t.db.Query(`
+		SELECT `+apiKeyColumns+`
+		FROM tokens t
+		WHERE t.user_id = $1 AND t.scope = $2 AND t.expiry > $3
+		ORDER BY t.created_at DESC, t.id DESC
+	`, userID, tokens.ScopeAPIKey, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey revokes one of the user's API keys. It returns sql.ErrNoRows
+// if the user has no such key.
+func (t *PostgersTokenStore) DeleteAPIKey(userID int, id int64) error {
+	result, err := t.db.Exec(`
+		DELETE FROM tokens
+		WHERE id = $1 AND user_id = $2 AND scope = $3
+	`, id, userID, tokens.ScopeAPIKey)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// apiKeyColumns is the column list read by scanAPIKey, for a tokens table
+// aliased as t.
+const apiKeyColumns = `t.id, t.name, t.permissions, t.created_at, t.last_used_at, t.expiry`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	key := &APIKey{}
+	var permissions pgtype.TextArray
+
+	err := row.Scan(&key.ID, &key.Name, &permissions, &key.CreatedAt, &key.LastUsedAt, &key.Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Permissions, err = textArrayToStrings(permissions)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetUserAPIKey returns the owner of an unexpired API key along with the
+// key. Both are nil if there is no such key.
+func (s *PostgresUserStore) GetUserAPIKey(tokenPlainText string) (*User, *APIKey, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	query := `
+		SELECT ` + userColumns + `, ` + apiKeyColumns + `
+		FROM users u
+		INNER JOIN tokens t ON t.user_id = u.id
+		WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
+	`
+
+	key := &APIKey{}
+	var permissions pgtype.TextArray
+	row := withColumns{
+		s.db.QueryRow(query, tokenHash[:], tokens.ScopeAPIKey, time.Now()),
+		[]any{&key.ID, &key.Name, &permissions, &key.CreatedAt, &key.LastUsedAt, &key.Expiry},
+	}
+
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key.Permissions, err = textArrayToStrings(permissions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, key, nil
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "api_keys_user")
+	other := createTestUser(t, db, "api_keys_other")
+
+	token, err := tokens.GenerateAPIKey(user.ID, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token.PlainText, tokens.APIKeyPrefix))
+
+	key := &APIKey{Name: "sync script", Permissions: []string{tokens.PermissionWorkoutsRead}}
+	require.NoError(t, tokenStore.CreateAPIKey(key, token))
+	assert.NotZero(t, key.ID)
+	assert.Equal(t, token.ID, key.ID)
+
+	got, gotKey, err := userStore.GetUserAPIKey(token.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, "sync script", gotKey.Name)
+	assert.True(t, gotKey.HasPermission(tokens.PermissionWorkoutsRead))
+	assert.False(t, gotKey.HasPermission(tokens.PermissionWorkoutsWrite))
+
+	// an API key is not a session token, nor the other way round
+	got, _, err = userStore.GetUserSession(token.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	session, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	got, _, err = userStore.GetUserAPIKey(session.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	keys, err := tokenStore.ListAPIKeys(user.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, []string{tokens.PermissionWorkoutsRead}, keys[0].Permissions)
+
+	assert.ErrorIs(t, tokenStore.DeleteAPIKey(other.ID, key.ID), sql.ErrNoRows)
+	assert.ErrorIs(t, tokenStore.DeleteAPIKey(user.ID, session.ID), sql.ErrNoRows)
+	require.NoError(t, tokenStore.DeleteAPIKey(user.ID, key.ID))
+
+	got, _, err = userStore.GetUserAPIKey(token.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
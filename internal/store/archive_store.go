@@ -0,0 +1,129 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ArchiveStatusPending = "pending"
+	ArchiveStatusReady   = "ready"
+	ArchiveStatusFailed  = "failed"
+)
+
+var ErrArchivePending = errors.New("an export archive is already being built")
+
+// ExportArchive is a ZIP of everything stored about a user, built in the
+// background. The archive itself is only read when it is downloaded.
+type ExportArchive struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Status      string     `json:"status"`
+	SizeBytes   *int64     `json:"size_bytes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+type ArchiveStore interface {
+	CreateArchive(archive *ExportArchive) error
+	CompleteArchive(id int, data []byte) error
+	FailArchive(id int) error
+	GetArchive(id int64) (*ExportArchive, error)
+	GetArchiveData(id int64) ([]byte, error)
+	CleanUpArchives(staleAfter time.Duration) (int64, error)
+}
+
+type PostgresArchiveStore struct {
+	db *sql.DB
+}
+
+func NewPostgresArchiveStore(db *sql.DB) *PostgresArchiveStore {
+	return &PostgresArchiveStore{db: db}
+}
+
+// CreateArchive records a pending archive, or returns ErrArchivePending when
+// the user already has one being built.
+func (pg *PostgresArchiveStore) CreateArchive(archive *ExportArchive) error {
+	query := `
+		INSERT INTO export_archives (user_id, expires_at)
+		VALUES ($1, $2)
+		RETURNING id, status, created_at
+	`
+	err := pg.db.QueryRow(query, archive.UserID, archive.ExpiresAt).Scan(&archive.ID, &archive.Status, &archive.CreatedAt)
+	if _, ok := isUniqueViolation(err); ok {
+		return ErrArchivePending
+	}
+	return err
+}
+
+func (pg *PostgresArchiveStore) CompleteArchive(id int, data []byte) error {
+	_, err := pg.db.Exec(`
+		UPDATE export_archives
+		SET status = 'ready', data = $2, size_bytes = $3, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id, data, len(data))
+	return err
+}
+
+func (pg *PostgresArchiveStore) FailArchive(id int) error {
+	_, err := pg.db.Exec(`
+		UPDATE export_archives
+		SET status = 'failed', completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func (pg *PostgresArchiveStore) GetArchive(id int64) (*ExportArchive, error) {
+	archive := &ExportArchive{}
+	err := pg.db.QueryRow(`
+		SELECT id, user_id, status, size_bytes, created_at, completed_at, expires_at
+		FROM export_archives
+		WHERE id = $1
+	`, id).Scan(
+		&archive.ID, &archive.UserID, &archive.Status, &archive.SizeBytes,
+		&archive.CreatedAt, &archive.CompletedAt, &archive.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (pg *PostgresArchiveStore) GetArchiveData(id int64) ([]byte, error) {
+	var data []byte
+	err := pg.db.QueryRow(`
+		SELECT data
+		FROM export_archives
+		WHERE id = $1 AND status = 'ready'
+	`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+// CleanUpArchives deletes expired archives and fails archives that have been
+// pending for longer than staleAfter, which a restart interrupted. It
+// returns the number of archives deleted.
+func (pg *PostgresArchiveStore) CleanUpArchives(staleAfter time.Duration) (int64, error) {
+	_, err := pg.db.Exec(`
+		UPDATE export_archives
+		SET status = 'failed', completed_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND created_at < $1
+	`, time.Now().Add(-staleAfter))
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := pg.db.Exec(`DELETE FROM export_archives WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportArchives(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	archiveStore := NewPostgresArchiveStore(db)
+	user := createTestUser(t, db, "archive_user")
+
+	archive := &ExportArchive{UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, archiveStore.CreateArchive(archive))
+	assert.Equal(t, ArchiveStatusPending, archive.Status)
+
+	// one archive at a time
+	assert.ErrorIs(t, archiveStore.CreateArchive(&ExportArchive{UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}), ErrArchivePending)
+
+	data, err := archiveStore.GetArchiveData(int64(archive.ID))
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	require.NoError(t, archiveStore.CompleteArchive(archive.ID, []byte("PK")))
+	got, err := archiveStore.GetArchive(int64(archive.ID))
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveStatusReady, got.Status)
+	assert.Equal(t, int64(2), *got.SizeBytes)
+
+	data, err = archiveStore.GetArchiveData(int64(archive.ID))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("PK"), data)
+
+	expired := &ExportArchive{UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, archiveStore.CreateArchive(expired))
+	deleted, err := archiveStore.CleanUpArchives(time.Hour)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+
+	got, err = archiveStore.GetArchive(int64(expired.ID))
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	AuditLogin                  = "login"
+	AuditLogout                 = "logout"
+	AuditSessionRevoked         = "session_revoked"
+	AuditRefreshTokenReused     = "refresh_token_reused"
+	AuditAPIKeyCreated          = "api_key_created"
+	AuditAPIKeyRevoked          = "api_key_revoked"
+	AuditPasswordChanged        = "password_changed"
+	AuditPasswordResetRequested = "password_reset_requested"
+	AuditPasswordReset          = "password_reset"
+	AuditExportArchiveRequested = "export_archive_requested"
+	AuditDeletionRequested      = "account_deletion_requested"
+	AuditDeletionCancelled      = "account_deletion_cancelled"
+	AuditAccountDeleted         = "account_deleted"
+	AuditAccountAnonymized      = "account_anonymized"
+)
+
+// AuditEvent records a security-relevant action on an account.
+type AuditEvent struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Event     string    `json:"event"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditStore interface {
+	RecordEvent(event *AuditEvent) error
+	ListEvents(userID int) ([]*AuditEvent, error)
+}
+
+type PostgresAuditStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditStore(db *sql.DB) *PostgresAuditStore {
+	return &PostgresAuditStore{db: db}
+}
+
+func (pg *PostgresAuditStore) RecordEvent(event *AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (user_id, event, ip_address)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	return pg.db.QueryRow(query, event.UserID, event.Event, nullString(event.IPAddress)).Scan(&event.ID, &event.CreatedAt)
+}
+
+// ListEvents returns the user's events, oldest first.
+func (pg *PostgresAuditStore) ListEvents(userID int) ([]*AuditEvent, error) {
+	rows, err := pg.db.Query(`
+		SELECT id, user_id, event, COALESCE(ip_address, ''), created_at
+		FROM audit_events
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		event := &AuditEvent{}
+		err = rows.Scan(&event.ID, &event.UserID, &event.Event, &event.IPAddress, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,37 @@
+package store
+
+import "math"
+
+const (
+	EntryKindStrength = "strength"
+	EntryKindCardio   = "cardio"
+)
+
+// DistanceUnits maps the accepted distance units to meters.
+var DistanceUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"mi": 1609.344,
+}
+
+// DistanceMeters converts the entry's distance to meters, or nil if it has
+// none.
+func (e WorkoutEntry) DistanceMeters() *float64 {
+	factor, ok := DistanceUnits[e.DistanceUnit]
+	if e.Distance == nil || !ok {
+		return nil
+	}
+	meters := *e.Distance * factor
+	return &meters
+}
+
+// distanceInUnit turns a stored distance back into the unit it was logged
+// in, dropping the noise the meter conversion adds.
+func distanceInUnit(meters *float64, unit string) *float64 {
+	factor, ok := DistanceUnits[unit]
+	if meters == nil || !ok {
+		return nil
+	}
+	distance := math.Round(*meters/factor*1000) / 1000
+	return &distance
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardioEntries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "cardio_user")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "long run", DurationMinutes: 95,
+		Entries: []WorkoutEntry{
+			{
+				ExerciseName: "running", Kind: EntryKindCardio, OrderIndex: 1,
+				DurationSeconds: IntPtr(5400), Distance: FloatPtr(13.1), DistanceUnit: "mi",
+				ElevationGainMeters: FloatPtr(120), AvgHeartRate: IntPtr(148), MaxHeartRate: IntPtr(171), AvgCadence: IntPtr(172),
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, workout.Entries[0].Sets)
+	assert.Empty(t, workout.Entries[0].SetDetails)
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+
+	run := retrieved.Entries[0]
+	assert.Equal(t, EntryKindCardio, run.Kind)
+	assert.Equal(t, "mi", run.DistanceUnit)
+	assert.InDelta(t, 13.1, *run.Distance, 0.001)
+	assert.Equal(t, 148, *run.AvgHeartRate)
+	assert.Contains(t, run.PersonalRecords, RecordMaxDistance)
+}
@@ -0,0 +1,101 @@
+package store
+
+import "database/sql"
+
+const (
+	GroupStraight = "straight"
+	GroupSuperset = "superset"
+	GroupCircuit  = "circuit"
+	GroupEMOM     = "emom"
+	GroupAMRAP    = "amrap"
+)
+
+// WorkoutEntryGroup bundles the entries that share its GroupIndex into a
+// superset, circuit, EMOM or AMRAP block. Rounds repeats the whole block,
+// RestSeconds is taken between exercises and RoundRestSeconds between
+// rounds. EMOMs start a round every IntervalSeconds and AMRAPs run until
+// TimeCapSeconds.
+type WorkoutEntryGroup struct {
+	ID               int    `json:"id"`
+	GroupIndex       int    `json:"group_index"`
+	Type             string `json:"type"`
+	Rounds           *int   `json:"rounds"`
+	RestSeconds      *int   `json:"rest_seconds"`
+	RoundRestSeconds *int   `json:"round_rest_seconds"`
+	IntervalSeconds  *int   `json:"interval_seconds"`
+	TimeCapSeconds   *int   `json:"time_cap_seconds"`
+	Notes            string `json:"notes"`
+}
+
+// Groups of workouts and of templates live in twin tables keyed by the
+// owning row.
+const (
+	workoutGroupTable  = "workout_entry_groups"
+	workoutGroupOwner  = "workout_id"
+	templateGroupTable = "workout_template_entry_groups"
+	templateGroupOwner = "template_id"
+)
+
+// insertEntryGroups writes groups for the owner in place, filling in their
+// IDs. It has to run before the entries that reference them.
+func insertEntryGroups(tx *sql.Tx, table, ownerColumn string, ownerID int, groups []WorkoutEntryGroup) error {
+	query := `
+		INSERT INTO ` + table + ` (` + ownerColumn + `, group_index, group_type, rounds, rest_seconds, round_rest_seconds, interval_seconds, time_cap_seconds, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+
+	for i := range groups {
+		group := &groups[i]
+
+		err := tx.QueryRow(
+			query,
+			ownerID, group.GroupIndex, group.Type, group.Rounds, group.RestSeconds,
+			group.RoundRestSeconds, group.IntervalSeconds, group.TimeCapSeconds, nullString(group.Notes),
+		).Scan(&group.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadEntryGroups reads the groups of several owners in one query, keyed by
+// owner id.
+func loadEntryGroups(db *sql.DB, table, ownerColumn string, ids []int) (map[int][]WorkoutEntryGroup, error) {
+	query := `
+		SELECT ` + ownerColumn + `, id, group_index, group_type, rounds, rest_seconds,
+			round_rest_seconds, interval_seconds, time_cap_seconds, COALESCE(notes, '')
+		FROM ` + table + `
+		WHERE ` + ownerColumn + ` = ANY($1)
+		ORDER BY ` + ownerColumn + `, group_index
+	`
+
+	rows, err := db.Query(query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := map[int][]WorkoutEntryGroup{}
+	for rows.Next() {
+		var ownerID int
+		var group WorkoutEntryGroup
+		err = rows.Scan(
+			&ownerID, &group.ID, &group.GroupIndex, &group.Type, &group.Rounds, &group.RestSeconds,
+			&group.RoundRestSeconds, &group.IntervalSeconds, &group.TimeCapSeconds, &group.Notes,
+		)
+		if err != nil {
+			return nil, err
+		}
+		groups[ownerID] = append(groups[ownerID], group)
+	}
+	return groups, rows.Err()
+}
+
+func nonNilGroups(groups []WorkoutEntryGroup) []WorkoutEntryGroup {
+	if groups == nil {
+		return []WorkoutEntryGroup{}
+	}
+	return groups
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutEntryGroups(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "entry_groups_user")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "push superset", DurationMinutes: 45,
+		Groups: []WorkoutEntryGroup{
+			{GroupIndex: 0, Type: GroupSuperset, Rounds: IntPtr(3), RoundRestSeconds: IntPtr(90)},
+		},
+		Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", Sets: 1, Reps: IntPtr(10), Weight: FloatPtr(60), OrderIndex: 1, GroupIndex: IntPtr(0)},
+			{ExerciseName: "pull up", Sets: 1, Reps: IntPtr(8), OrderIndex: 2, GroupIndex: IntPtr(0)},
+			{ExerciseName: "plank", Sets: 2, DurationSeconds: IntPtr(60), OrderIndex: 3},
+		},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Groups, 1)
+	assert.Equal(t, GroupSuperset, retrieved.Groups[0].Type)
+	assert.Equal(t, 3, *retrieved.Groups[0].Rounds)
+	assert.Equal(t, 0, *retrieved.Entries[0].GroupIndex)
+	assert.Equal(t, 0, *retrieved.Entries[1].GroupIndex)
+	assert.Nil(t, retrieved.Entries[2].GroupIndex)
+
+	retrieved.Groups = []WorkoutEntryGroup{}
+	retrieved.Entries[0].GroupIndex = nil
+	retrieved.Entries[1].GroupIndex = nil
+	require.NoError(t, store.UpdateWorkout(retrieved))
+
+	updated, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	assert.Empty(t, updated.Groups)
+}
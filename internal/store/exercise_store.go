@@ -0,0 +1,294 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+)
+
+var ErrExerciseExists = errors.New("an exercise with this name or alias already exists")
+
+type Exercise struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	Aliases          []string  `json:"aliases"`
+	PrimaryMuscles   []string  `json:"primary_muscles"`
+	SecondaryMuscles []string  `json:"secondary_muscles"`
+	Equipment        string    `json:"equipment"`
+	MovementType     string    `json:"movement_type"`
+	CreatedBy        *int      `json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ExerciseFilter narrows ListExercises to the seeded catalog plus UserID's
+// own exercises.
+type ExerciseFilter struct {
+	UserID      int
+	Search      string
+	MuscleGroup string
+}
+
+type ExerciseStore interface {
+	CreateExercise(*Exercise) error
+	GetExerciseByID(id int64) (*Exercise, error)
+	ListExercises(filter ExerciseFilter) ([]*Exercise, error)
+	UpdateExercise(*Exercise) error
+	DeleteExercise(id int64) error
+	ResolveExerciseNames(userID int, names []string) (map[string]int, error)
+}
+
+type PostgresExerciseStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExerciseStore(db *sql.DB) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: db}
+}
+
+// isUniqueViolation reports whether err is a postgres unique_violation.
+func isUniqueViolation(err error) (*pgconn.PgError, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return pgErr, true
+	}
+	return nil, false
+}
+
+func (pg *PostgresExerciseStore) CreateExercise(exercise *Exercise) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO exercises (name, primary_muscles, secondary_muscles, equipment, movement_type, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = tx.QueryRow(
+		query,
+		exercise.Name, nonNilStrings(exercise.PrimaryMuscles), nonNilStrings(exercise.SecondaryMuscles),
+		nullString(exercise.Equipment), nullString(exercise.MovementType), exercise.CreatedBy,
+	).Scan(&exercise.ID, &exercise.CreatedAt, &exercise.UpdatedAt)
+	if err != nil {
+		if _, ok := isUniqueViolation(err); ok {
+			return ErrExerciseExists
+		}
+		return err
+	}
+
+	err = insertExerciseAliases(tx, exercise)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertExerciseAliases(tx *sql.Tx, exercise *Exercise) error {
+	for _, alias := range exercise.Aliases {
+		_, err := tx.Exec(
+			`INSERT INTO exercise_aliases (exercise_id, alias, created_by) VALUES ($1, $2, $3)`,
+			exercise.ID, alias, exercise.CreatedBy,
+		)
+		if err != nil {
+			if _, ok := isUniqueViolation(err); ok {
+				return ErrExerciseExists
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+const exerciseColumns = `
+	x.id, x.name,
+	ARRAY(SELECT a.alias FROM exercise_aliases a WHERE a.exercise_id = x.id ORDER BY a.alias),
+	x.primary_muscles, x.secondary_muscles,
+	COALESCE(x.equipment, ''), COALESCE(x.movement_type, ''),
+	x.created_by, x.created_at, x.updated_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExercise(row rowScanner) (*Exercise, error) {
+	exercise := &Exercise{}
+	var aliases, primary, secondary pgtype.TextArray
+	var createdBy sql.NullInt64
+
+	err := row.Scan(
+		&exercise.ID, &exercise.Name,
+		&aliases, &primary, &secondary,
+		&exercise.Equipment, &exercise.MovementType,
+		&createdBy, &exercise.CreatedAt, &exercise.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if exercise.Aliases, err = textArrayToStrings(aliases); err != nil {
+		return nil, err
+	}
+	if exercise.PrimaryMuscles, err = textArrayToStrings(primary); err != nil {
+		return nil, err
+	}
+	if exercise.SecondaryMuscles, err = textArrayToStrings(secondary); err != nil {
+		return nil, err
+	}
+
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		exercise.CreatedBy = &id
+	}
+	return exercise, nil
+}
+
+func (pg *PostgresExerciseStore) GetExerciseByID(id int64) (*Exercise, error) {
+	query := `SELECT ` + exerciseColumns + ` FROM exercises x WHERE x.id = $1`
+
+	exercise, err := scanExercise(pg.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exercise, nil
+}
+
+// ResolveExerciseNames matches free-text names against the catalog the way
+// userID's logged entries are matched. Names without a match are left out.
+func (pg *PostgresExerciseStore) ResolveExerciseNames(userID int, names []string) (map[string]int, error) {
+	rows, err := pg.db.Query(`
+		SELECT name, resolve_exercise_id(name, $2)
+		FROM unnest($1::TEXT[]) AS name
+		WHERE resolve_exercise_id(name, $2) IS NOT NULL
+	`, names, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resolved := map[string]int{}
+	for rows.Next() {
+		var name string
+		var id int
+		err = rows.Scan(&name, &id)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = id
+	}
+	return resolved, rows.Err()
+}
+
+func (pg *PostgresExerciseStore) ListExercises(filter ExerciseFilter) ([]*Exercise, error) {
+	query := `
+		SELECT ` + exerciseColumns + `
+		FROM exercises x
+		WHERE (x.created_by IS NULL OR x.created_by = $3)
+			AND ($1 = '' OR x.name ILIKE '%' || $1 || '%' OR similarity(x.name, $1) > 0.3
+			OR EXISTS (SELECT 1 FROM exercise_aliases a WHERE a.exercise_id = x.id AND a.alias ILIKE '%' || $1 || '%'))
+			AND ($2 = '' OR $2 = ANY(x.primary_muscles) OR $2 = ANY(x.secondary_muscles))
+		ORDER BY CASE WHEN $1 = '' THEN 0 ELSE similarity(x.name, $1) END DESC, x.name
+	`
+
+	rows, err := pg.db.Query(query, filter.Search, filter.MuscleGroup, filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []*Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+	return exercises, rows.Err()
+}
+
+func (pg *PostgresExerciseStore) UpdateExercise(exercise *Exercise) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE exercises
+		SET name = $1, primary_muscles = $2, secondary_muscles = $3, equipment = $4, movement_type = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+		RETURNING updated_at
+	`
+
+	err = tx.QueryRow(
+		query,
+		exercise.Name, nonNilStrings(exercise.PrimaryMuscles), nonNilStrings(exercise.SecondaryMuscles),
+		nullString(exercise.Equipment), nullString(exercise.MovementType), exercise.ID,
+	).Scan(&exercise.UpdatedAt)
+	if err != nil {
+		if _, ok := isUniqueViolation(err); ok {
+			return ErrExerciseExists
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM exercise_aliases WHERE exercise_id = $1`, exercise.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertExerciseAliases(tx, exercise)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresExerciseStore) DeleteExercise(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM exercises WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func textArrayToStrings(array pgtype.TextArray) ([]string, error) {
+	values := []string{}
+	if array.Status != pgtype.Present {
+		return values, nil
+	}
+	err := array.AssignTo(&values)
+	return values, err
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
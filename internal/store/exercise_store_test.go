@@ -0,0 +1,90 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExerciseCatalog(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresExerciseStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+	alice := createTestUser(t, db, "catalog_alice")
+	bob := createTestUser(t, db, "catalog_bob")
+
+	seeded, err := store.ResolveExerciseNames(alice.ID, []string{"OHP", " bench press ", "Zercher Carry"})
+	require.NoError(t, err)
+	require.Len(t, seeded, 2)
+	assert.NotContains(t, seeded, "Zercher Carry")
+
+	// custom names are unique per user, not across users
+	aliceCarry := &Exercise{Name: "Zercher Carry", Aliases: []string{"ZC"}, MovementType: "carry", CreatedBy: &alice.ID}
+	require.NoError(t, store.CreateExercise(aliceCarry))
+	assert.ErrorIs(t, store.CreateExercise(&Exercise{Name: "zercher carry", CreatedBy: &alice.ID}), ErrExerciseExists)
+	assert.ErrorIs(t, store.CreateExercise(&Exercise{Name: "Other", Aliases: []string{"zc"}, CreatedBy: &alice.ID}), ErrExerciseExists)
+
+	bobCarry := &Exercise{Name: "Zercher Carry", Aliases: []string{"ZC"}, CreatedBy: &bob.ID}
+	require.NoError(t, store.CreateExercise(bobCarry))
+	assert.NotEqual(t, aliceCarry.ID, bobCarry.ID)
+
+	// a custom exercise shadows the catalog entry of the same name for its owner only
+	aliceBench := &Exercise{Name: "Bench Press", CreatedBy: &alice.ID}
+	require.NoError(t, store.CreateExercise(aliceBench))
+
+	resolved, err := store.ResolveExerciseNames(alice.ID, []string{"Bench Press", "zc"})
+	require.NoError(t, err)
+	assert.Equal(t, aliceBench.ID, resolved["Bench Press"])
+	assert.Equal(t, aliceCarry.ID, resolved["zc"])
+
+	resolved, err = store.ResolveExerciseNames(bob.ID, []string{"Bench Press", "zc"})
+	require.NoError(t, err)
+	assert.Equal(t, seeded[" bench press "], resolved["Bench Press"])
+	assert.Equal(t, bobCarry.ID, resolved["zc"])
+
+	listed, err := store.ListExercises(ExerciseFilter{UserID: bob.ID, Search: "zercher"})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, bobCarry.ID, listed[0].ID)
+	assert.Equal(t, []string{"ZC"}, listed[0].Aliases)
+
+	listed, err = store.ListExercises(ExerciseFilter{UserID: bob.ID, MuscleGroup: "chest"})
+	require.NoError(t, err)
+	for _, exercise := range listed {
+		assert.Nil(t, exercise.CreatedBy, exercise.Name)
+	}
+
+	// logged entries resolve by name in their owner's namespace, and an
+	// exercise_id from someone else's namespace is not linked
+	workout, err := workoutStore.CreateWorkout(&Workout{
+		UserID: bob.ID, Title: "carries", DurationMinutes: 20,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "ZC", Sets: 3, Reps: IntPtr(1), OrderIndex: 1},
+			{ExerciseName: "Bench Press", ExerciseID: &aliceBench.ID, Sets: 3, Reps: IntPtr(5), OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, workout.Entries[0].ExerciseID)
+	assert.Equal(t, bobCarry.ID, *workout.Entries[0].ExerciseID)
+	require.NotNil(t, workout.Entries[1].ExerciseID)
+	assert.Equal(t, seeded[" bench press "], *workout.Entries[1].ExerciseID)
+
+	aliceCarry.Name = "Zercher Hold"
+	aliceCarry.Aliases = nil
+	require.NoError(t, store.UpdateExercise(aliceCarry))
+
+	got, err := store.GetExerciseByID(int64(aliceCarry.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "Zercher Hold", got.Name)
+	assert.Empty(t, got.Aliases)
+
+	require.NoError(t, store.DeleteExercise(int64(aliceCarry.ID)))
+	got, err = store.GetExerciseByID(int64(aliceCarry.ID))
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	assert.ErrorIs(t, store.DeleteExercise(int64(aliceCarry.ID)), sql.ErrNoRows)
+}
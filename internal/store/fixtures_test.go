@@ -0,0 +1,51 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	if err != nil {
+		t.Fatalf("opening test db: %v", err)
+	}
+
+	err = Migrate(db, "../../migrations")
+	if err != nil {
+		t.Fatalf("migration test db: %v", err)
+	}
+
+	_, err = db.Exec("TRUNCATE workouts, workout_entries CASCADE")
+
+	if err != nil {
+		t.Fatalf("truncate test db: %v", err)
+	}
+
+	return db
+}
+
+func createTestUser(t *testing.T, db *sql.DB, username string) *User {
+	t.Helper()
+
+	userStore := NewPostgreUserStore(db)
+	user := &User{UserName: username, Email: username + "@example.com"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, userStore.CreateUser(user))
+
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM users WHERE id = $1", user.ID)
+	})
+	return user
+}
+
+func IntPtr(i int) *int {
+	return &i
+}
+
+func FloatPtr(i float64) *float64 {
+	return &i
+}
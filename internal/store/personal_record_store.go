@@ -0,0 +1,436 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/ruhan/internal/calc/estimate"
+)
+
+const (
+	RecordMaxWeight    = "max_weight"
+	RecordMaxReps      = "max_reps"
+	RecordEstimated1RM = "estimated_1rm"
+	RecordMaxDuration  = "max_duration"
+	RecordMaxDistance  = "max_distance"
+)
+
+// PersonalRecord is one point on a user's PR timeline. Weight is only set
+// for max_reps records, which are tracked separately for every weight.
+type PersonalRecord struct {
+	ID             int       `json:"id"`
+	ExerciseID     int       `json:"exercise_id"`
+	ExerciseName   string    `json:"exercise_name"`
+	WorkoutID      int       `json:"workout_id"`
+	WorkoutEntryID int       `json:"workout_entry_id"`
+	RecordType     string    `json:"record_type"`
+	Value          float64   `json:"value"`
+	Weight         *float64  `json:"weight,omitempty"`
+	PreviousValue  *float64  `json:"previous_value"`
+	AchievedAt     time.Time `json:"achieved_at"`
+}
+
+type PersonalRecordStore interface {
+	ListRecords(userID int, exerciseID *int) ([]*PersonalRecord, error)
+	BestEstimatedOneRepMaxes(userID int) (map[int]float64, error)
+}
+
+type PostgresPersonalRecordStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPersonalRecordStore(db *sql.DB) *PostgresPersonalRecordStore {
+	return &PostgresPersonalRecordStore{db: db}
+}
+
+// ListRecords returns the user's PR timeline, oldest first, optionally
+// narrowed down to a single exercise.
+func (pg *PostgresPersonalRecordStore) ListRecords(userID int, exerciseID *int) ([]*PersonalRecord, error) {
+	query := `
+		SELECT pr.id, pr.exercise_id, x.name, pr.workout_id, pr.workout_entry_id,
+			pr.record_type, pr.value, pr.weight, pr.previous_value, pr.achieved_at
+		FROM personal_records pr
+		INNER JOIN exercises x ON x.id = pr.exercise_id
+		WHERE pr.user_id = $1 AND ($2::BIGINT IS NULL OR pr.exercise_id = $2)
+		ORDER BY pr.achieved_at, pr.id
+	`
+
+	rows, err := pg.db.Query(query, userID, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*PersonalRecord{}
+	for rows.Next() {
+		record := &PersonalRecord{}
+		err = rows.Scan(
+			&record.ID, &record.ExerciseID, &record.ExerciseName,
+			&record.WorkoutID, &record.WorkoutEntryID,
+			&record.RecordType, &record.Value, &record.Weight,
+			&record.PreviousValue, &record.AchievedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// BestEstimatedOneRepMaxes returns the user's best estimated one-rep max
+// keyed by exercise id.
+func (pg *PostgresPersonalRecordStore) BestEstimatedOneRepMaxes(userID int) (map[int]float64, error) {
+	query := `
+		SELECT exercise_id, MAX(value)
+		FROM personal_records
+		WHERE user_id = $1 AND record_type = $2
+		GROUP BY exercise_id
+	`
+
+	rows, err := pg.db.Query(query, userID, RecordEstimated1RM)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bests := map[int]float64{}
+	for rows.Next() {
+		var exerciseID int
+		var value float64
+		err = rows.Scan(&exerciseID, &value)
+		if err != nil {
+			return nil, err
+		}
+		bests[exerciseID] = value
+	}
+	return bests, rows.Err()
+}
+
+type recordKey struct {
+	exerciseID int
+	recordType string
+	weight     float64
+}
+
+type recordCandidate struct {
+	key    recordKey
+	value  float64
+	weight *float64
+}
+
+// entryRecordCandidates lists the best record each key could get from the
+// counting sets of an entry. Reps are only compared at the same weight, and
+// bodyweight work counts as weight 0. Cardio entries compete on duration
+// and distance (in meters).
+func entryRecordCandidates(entry *WorkoutEntry) []recordCandidate {
+	exerciseID := *entry.ExerciseID
+	candidates := []recordCandidate{}
+
+	if entry.Kind == EntryKindCardio {
+		if entry.DurationSeconds != nil && *entry.DurationSeconds > 0 {
+			candidates = append(candidates, recordCandidate{
+				key:   recordKey{exerciseID, RecordMaxDuration, 0},
+				value: float64(*entry.DurationSeconds),
+			})
+		}
+		if meters := entry.DistanceMeters(); meters != nil && *meters > 0 {
+			candidates = append(candidates, recordCandidate{
+				key:   recordKey{exerciseID, RecordMaxDistance, 0},
+				value: *meters,
+			})
+		}
+		return candidates
+	}
+	positions := map[recordKey]int{}
+
+	add := func(candidate recordCandidate) {
+		if i, ok := positions[candidate.key]; ok {
+			if candidate.value > candidates[i].value {
+				candidates[i] = candidate
+			}
+			return
+		}
+		positions[candidate.key] = len(candidates)
+		candidates = append(candidates, candidate)
+	}
+
+	for _, set := range entry.SetDetails {
+		if !set.Counts() {
+			continue
+		}
+
+		if set.DurationSeconds != nil && *set.DurationSeconds > 0 {
+			add(recordCandidate{
+				key:   recordKey{exerciseID, RecordMaxDuration, 0},
+				value: float64(*set.DurationSeconds),
+			})
+		}
+
+		if set.Reps == nil || *set.Reps <= 0 {
+			continue
+		}
+
+		weight := 0.0
+		if set.Weight != nil {
+			weight = *set.Weight
+		}
+
+		add(recordCandidate{
+			key:    recordKey{exerciseID, RecordMaxReps, weight},
+			value:  float64(*set.Reps),
+			weight: &weight,
+		})
+
+		if weight > 0 {
+			add(recordCandidate{key: recordKey{exerciseID, RecordMaxWeight, 0}, value: weight})
+			add(recordCandidate{key: recordKey{exerciseID, RecordEstimated1RM, 0}, value: estimate.OneRepMax(estimate.Epley, weight, *set.Reps)})
+		}
+	}
+	return candidates
+}
+
+// roundRecordValue rounds a record to the two decimals personal_records
+// stores, so repeating a lift whose estimate has more decimals, or a
+// distance converted to meters, doesn't beat its own stored value.
+func roundRecordValue(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// historyEntry is an entry as recomputing records reads it back.
+type historyEntry struct {
+	workoutID  int
+	achievedAt time.Time
+	entry      WorkoutEntry
+}
+
+// recomputePersonalRecords rebuilds the user's records for the given
+// exercises from the workout (from, fromID) onward, in created_at order,
+// so a workout written, edited, back-dated or deleted in the middle of the
+// history leaves no stale flags or previous values on the workouts after
+// it. Records before that point are kept as the history to beat. It runs
+// in the caller's transaction and returns the record types by entry id.
+func recomputePersonalRecords(tx *sql.Tx, userID int, exerciseIDs []int, from time.Time, fromID int) (map[int][]string, error) {
+	recordsByEntry := map[int][]string{}
+	if len(exerciseIDs) == 0 {
+		return recordsByEntry, nil
+	}
+
+	_, err := tx.Exec(`
+		DELETE FROM personal_records pr
+		USING workouts w
+		WHERE w.id = pr.workout_id AND pr.user_id = $1 AND pr.exercise_id = ANY($2)
+			AND (w.created_at, w.id) >= ($3, $4)
+	`, userID, exerciseIDs, from, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT exercise_id, record_type, COALESCE(weight, 0), MAX(value)
+		FROM personal_records
+		WHERE user_id = $1 AND exercise_id = ANY($2)
+		GROUP BY exercise_id, record_type, COALESCE(weight, 0)
+	`, userID, exerciseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	bests := map[recordKey]float64{}
+	for rows.Next() {
+		var key recordKey
+		var value float64
+		err = rows.Scan(&key.exerciseID, &key.recordType, &key.weight, &value)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if key.recordType != RecordMaxReps {
+			key.weight = 0
+		}
+		bests[key] = value
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// the entries are read in full before any record is written, since the
+	// transaction's connection can't interleave the two
+	rows, err = tx.Query(`
+		SELECT w.id, w.created_at, e.id, e.exercise_id, e.kind, e.sets, e.reps, e.weight,
+			e.duration_seconds, e.distance_meters,
+			(
+				SELECT json_agg(json_build_object(
+					'set_type', s.set_type, 'reps', s.reps, 'weight', s.weight,
+					'duration_seconds', s.duration_seconds, 'completed', s.completed
+				) ORDER BY s.set_index)
+				FROM workout_sets s
+				WHERE s.entry_id = e.id
+			)
+		FROM workout_entries e
+		INNER JOIN workouts w ON w.id = e.workout_id
+		WHERE w.user_id = $1 AND e.exercise_id = ANY($2) AND (w.created_at, w.id) >= ($3, $4)
+		ORDER BY w.created_at, w.id, e.order_index
+	`, userID, exerciseIDs, from, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []historyEntry{}
+	for rows.Next() {
+		var item historyEntry
+		var distanceMeters *float64
+		var sets []byte
+		err = rows.Scan(
+			&item.workoutID, &item.achievedAt, &item.entry.ID, &item.entry.ExerciseID, &item.entry.Kind,
+			&item.entry.Sets, &item.entry.Reps, &item.entry.Weight, &item.entry.DurationSeconds, &distanceMeters, &sets,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if distanceMeters != nil {
+			item.entry.Distance, item.entry.DistanceUnit = distanceMeters, "m"
+		}
+		if sets != nil {
+			err = json.Unmarshal(sets, &item.entry.SetDetails)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+		}
+		history = append(history, item)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	insertQuery := `
+		INSERT INTO personal_records (user_id, exercise_id, workout_id, workout_entry_id, record_type, value, weight, previous_value, achieved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	for _, item := range history {
+		for _, candidate := range entryRecordCandidates(&item.entry) {
+			candidate.value = roundRecordValue(candidate.value)
+			var previous *float64
+			if best, ok := bests[candidate.key]; ok {
+				if candidate.value <= best {
+					continue
+				}
+				previous = &best
+			}
+
+			_, err = tx.Exec(
+				insertQuery,
+				userID, candidate.key.exerciseID, item.workoutID, item.entry.ID,
+				candidate.key.recordType, candidate.value, candidate.weight, previous, item.achievedAt,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			bests[candidate.key] = candidate.value
+			if !slices.Contains(recordsByEntry[item.entry.ID], candidate.key.recordType) {
+				recordsByEntry[item.entry.ID] = append(recordsByEntry[item.entry.ID], candidate.key.recordType)
+			}
+		}
+	}
+
+	return recordsByEntry, nil
+}
+
+// updatePersonalRecords recomputes the records of the workout's exercises,
+// and of previousExerciseIDs it no longer has, from the workout onward and
+// flags its entries with the records they set.
+func updatePersonalRecords(tx *sql.Tx, workout *Workout, previousExerciseIDs []int) error {
+	exerciseIDs := previousExerciseIDs
+	for _, entry := range workout.Entries {
+		if entry.ExerciseID != nil && !slices.Contains(exerciseIDs, *entry.ExerciseID) {
+			exerciseIDs = append(exerciseIDs, *entry.ExerciseID)
+		}
+	}
+
+	recordsByEntry, err := recomputePersonalRecords(tx, workout.UserID, exerciseIDs, workout.CreatedAt, workout.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range workout.Entries {
+		workout.Entries[i].PersonalRecords = recordsByEntry[workout.Entries[i].ID]
+	}
+	return nil
+}
+
+// workoutExerciseIDs returns the exercises the workout's stored entries are
+// linked to.
+func workoutExerciseIDs(tx *sql.Tx, workoutID int) ([]int, error) {
+	rows, err := tx.Query(`
+		SELECT DISTINCT exercise_id
+		FROM workout_entries
+		WHERE workout_id = $1 AND exercise_id IS NOT NULL
+	`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// attachPersonalRecords flags the entries of the given workouts with the
+// record types they set.
+func attachPersonalRecords(db *sql.DB, workouts ...*Workout) error {
+	ids := []int{}
+	for _, workout := range workouts {
+		ids = append(ids, workout.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT workout_entry_id, record_type
+		FROM personal_records
+		WHERE workout_id = ANY($1)
+		ORDER BY id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	recordsByEntry := map[int][]string{}
+	for rows.Next() {
+		var entryID int
+		var recordType string
+		err = rows.Scan(&entryID, &recordType)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(recordsByEntry[entryID], recordType) {
+			recordsByEntry[entryID] = append(recordsByEntry[entryID], recordType)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, workout := range workouts {
+		for i := range workout.Entries {
+			workout.Entries[i].PersonalRecords = recordsByEntry[workout.Entries[i].ID]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersonalRecordDetection(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	recordStore := NewPostgresPersonalRecordStore(db)
+	user := createTestUser(t, db, "personal_records_user")
+
+	first, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "week 1", DurationMinutes: 60,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, first.Entries[0].ExerciseID)
+	assert.ElementsMatch(t, []string{RecordMaxReps, RecordMaxWeight, RecordEstimated1RM}, first.Entries[0].PersonalRecords)
+
+	second, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "week 2", DurationMinutes: 60,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "BB Bench", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(100), OrderIndex: 1},
+			{ExerciseName: "Bench Press", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(90), OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, *first.Entries[0].ExerciseID, *second.Entries[0].ExerciseID)
+	assert.ElementsMatch(t, []string{RecordMaxReps, RecordEstimated1RM}, second.Entries[0].PersonalRecords)
+	assert.ElementsMatch(t, []string{RecordMaxReps}, second.Entries[1].PersonalRecords)
+
+	retrieved, err := store.GetWorkoutByID(int64(second.ID))
+	require.NoError(t, err)
+	assert.Equal(t, second.Entries[0].PersonalRecords, retrieved.Entries[0].PersonalRecords)
+
+	records, err := recordStore.ListRecords(user.ID, first.Entries[0].ExerciseID)
+	require.NoError(t, err)
+	assert.Len(t, records, 6)
+
+	// 80x8 estimates a 1RM of 101.333..., stored as 101.33, and 3.1 mi
+	// converts to 4988.9664 m; the same workout again sets no records
+	squatDay := func() *Workout {
+		workout, err := store.CreateWorkout(&Workout{
+			UserID: user.ID, Title: "squats", DurationMinutes: 30,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 1, Reps: IntPtr(8), Weight: FloatPtr(80), OrderIndex: 1},
+				{ExerciseName: "Running", Kind: EntryKindCardio, Sets: 1, Distance: FloatPtr(3.1), DistanceUnit: "mi", OrderIndex: 2},
+			},
+		})
+		require.NoError(t, err)
+		return workout
+	}
+	squatDay()
+	repeat := squatDay()
+	assert.Empty(t, repeat.Entries[0].PersonalRecords)
+	assert.Empty(t, repeat.Entries[1].PersonalRecords)
+
+	squatRecords, err := recordStore.ListRecords(user.ID, nil)
+	require.NoError(t, err)
+	estimates := 0
+	for _, record := range squatRecords {
+		if record.ExerciseName == "Squat" && record.RecordType == RecordEstimated1RM {
+			estimates++
+			assert.InDelta(t, 101.33, record.Value, 0.001)
+		}
+	}
+	assert.Equal(t, 1, estimates)
+
+	// editing week 1 only compares it against what was logged before it, so
+	// week 2's 8 reps at 100 don't keep its 6 from being a record
+	first.Entries = []WorkoutEntry{
+		{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(6), Weight: FloatPtr(100), OrderIndex: 1},
+	}
+	require.NoError(t, store.UpdateWorkout(first))
+	assert.ElementsMatch(t, []string{RecordMaxReps, RecordMaxWeight, RecordEstimated1RM}, first.Entries[0].PersonalRecords)
+}
+
+func TestPersonalRecordRecompute(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	recordStore := NewPostgresPersonalRecordStore(db)
+	user := createTestUser(t, db, "recompute_user")
+
+	bench := func(title string, weight float64) *Workout {
+		workout, err := store.CreateWorkout(&Workout{
+			UserID: user.ID, Title: title, DurationMinutes: 30,
+			Entries: []WorkoutEntry{{ExerciseName: "Bench Press", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(weight), OrderIndex: 1}},
+		})
+		require.NoError(t, err)
+		return workout
+	}
+
+	// maxWeights lists the max_weight timeline as "value after previous"
+	maxWeights := func() []string {
+		records, err := recordStore.ListRecords(user.ID, nil)
+		require.NoError(t, err)
+		timeline := []string{}
+		for _, record := range records {
+			if record.RecordType != RecordMaxWeight {
+				continue
+			}
+			previous := "-"
+			if record.PreviousValue != nil {
+				previous = fmt.Sprint(*record.PreviousValue)
+			}
+			timeline = append(timeline, fmt.Sprintf("%v after %s", record.Value, previous))
+		}
+		return timeline
+	}
+
+	first := bench("first", 100)
+	second := bench("second", 110)
+	bench("third", 120)
+	assert.Equal(t, []string{"100 after -", "110 after 100", "120 after 110"}, maxWeights())
+
+	// editing the second workout past the third takes the third's record
+	second.Entries = []WorkoutEntry{{ExerciseName: "Bench Press", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(130), OrderIndex: 1}}
+	require.NoError(t, store.UpdateWorkout(second))
+	assert.Equal(t, []string{"100 after -", "130 after 100"}, maxWeights())
+
+	// an edit that drops the exercise hands the record back
+	second.Entries = []WorkoutEntry{{ExerciseName: "Plank", Sets: 1, DurationSeconds: IntPtr(60), OrderIndex: 1}}
+	require.NoError(t, store.UpdateWorkout(second))
+	assert.Equal(t, []string{"100 after -", "120 after 100"}, maxWeights())
+
+	// deleting the first workout leaves the third as the first record
+	require.NoError(t, store.DeleteWorkout(int64(first.ID)))
+	assert.Equal(t, []string{"120 after -"}, maxWeights())
+
+	// a back-dated import comes before everything logged since
+	_, _, err := store.ImportWorkouts(user.ID, []*Workout{{
+		Title: "old", DurationMinutes: 30, ExternalID: "recompute:1", CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Entries: []WorkoutEntry{{ExerciseName: "Bench Press", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(90), OrderIndex: 1}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"90 after -", "120 after 90"}, maxWeights())
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrOccurrenceAlreadyCompleted = errors.New("this planned workout has already been completed on that date")
+	ErrWorkoutAlreadyLinked       = errors.New("this workout already completes another planned workout")
+)
+
+// PlannedWorkout is a workout scheduled ahead of time. Without an RRule it
+// happens once on ScheduledDate, otherwise ScheduledDate starts the series.
+// Dates are local calendar dates stored as midnight UTC.
+type PlannedWorkout struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	TemplateID    *int      `json:"template_id"`
+	ScheduledDate time.Time `json:"scheduled_date"`
+	RRule         string    `json:"rrule,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PlannedWorkoutCompletion links one occurrence of a planned workout to the
+// workout that was logged for it.
+type PlannedWorkoutCompletion struct {
+	PlannedWorkoutID int       `json:"planned_workout_id"`
+	OccurrenceDate   time.Time `json:"occurrence_date"`
+	WorkoutID        int       `json:"workout_id"`
+}
+
+// LoggedWorkout is a logged workout placed on the user's local calendar.
+type LoggedWorkout struct {
+	ID              int       `json:"id"`
+	Title           string    `json:"title"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Date            time.Time `json:"date"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type PlannedWorkoutStore interface {
+	CreatePlannedWorkout(*PlannedWorkout) error
+	GetPlannedWorkoutByID(id int64) (*PlannedWorkout, error)
+	ListPlannedWorkouts(userID int, from, to time.Time) ([]*PlannedWorkout, error)
+	DeletePlannedWorkout(id int64) error
+	CompleteOccurrence(*PlannedWorkoutCompletion) error
+	ListCompletions(userID int, from, to time.Time) ([]*PlannedWorkoutCompletion, error)
+	ListLoggedWorkouts(userID int, timezone string, from, to time.Time) ([]*LoggedWorkout, error)
+}
+
+type PostgresPlannedWorkoutStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPlannedWorkoutStore(db *sql.DB) *PostgresPlannedWorkoutStore {
+	return &PostgresPlannedWorkoutStore{db: db}
+}
+
+func (pg *PostgresPlannedWorkoutStore) CreatePlannedWorkout(planned *PlannedWorkout) error {
+	query := `
+		INSERT INTO planned_workouts (user_id, title, description, template_id, scheduled_date, rrule)
+		VALUES ($1, $2, $3, $4, $5::DATE, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	return pg.db.QueryRow(
+		query,
+		planned.UserID, planned.Title, planned.Description, planned.TemplateID,
+		planned.ScheduledDate.Format(time.DateOnly), nullString(planned.RRule),
+	).Scan(&planned.ID, &planned.CreatedAt, &planned.UpdatedAt)
+}
+
+const plannedWorkoutColumns = `id, user_id, title, COALESCE(description, ''), template_id, scheduled_date, COALESCE(rrule, ''), created_at, updated_at`
+
+func scanPlannedWorkout(row rowScanner) (*PlannedWorkout, error) {
+	planned := &PlannedWorkout{}
+	err := row.Scan(
+		&planned.ID, &planned.UserID, &planned.Title, &planned.Description, &planned.TemplateID,
+		&planned.ScheduledDate, &planned.RRule, &planned.CreatedAt, &planned.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return planned, nil
+}
+
+func (pg *PostgresPlannedWorkoutStore) GetPlannedWorkoutByID(id int64) (*PlannedWorkout, error) {
+	query := `SELECT ` + plannedWorkoutColumns + ` FROM planned_workouts WHERE id = $1`
+
+	planned, err := scanPlannedWorkout(pg.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return planned, nil
+}
+
+// ListPlannedWorkouts returns the user's planned workouts that can occur
+// between from and to: one-off plans inside the range and every series that
+// starts on or before to.
+func (pg *PostgresPlannedWorkoutStore) ListPlannedWorkouts(userID int, from, to time.Time) ([]*PlannedWorkout, error) {
+	query := `
+		SELECT ` + plannedWorkoutColumns + `
+		FROM planned_workouts
+		WHERE user_id = $1
+			AND scheduled_date <= $3::DATE
+			AND (rrule IS NOT NULL OR scheduled_date >= $2::DATE)
+		ORDER BY scheduled_date, id
+	`
+
+	rows, err := pg.db.Query(query, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []*PlannedWorkout{}
+	for rows.Next() {
+		planned, err := scanPlannedWorkout(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, planned)
+	}
+	return plans, rows.Err()
+}
+
+func (pg *PostgresPlannedWorkoutStore) DeletePlannedWorkout(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM planned_workouts WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresPlannedWorkoutStore) CompleteOccurrence(completion *PlannedWorkoutCompletion) error {
+	query := `
+		INSERT INTO planned_workout_completions (planned_workout_id, occurrence_date, workout_id)
+		VALUES ($1, $2::DATE, $3)
+	`
+
+	_, err := pg.db.Exec(query, completion.PlannedWorkoutID, completion.OccurrenceDate.Format(time.DateOnly), completion.WorkoutID)
+	if pgErr, ok := isUniqueViolation(err); ok {
+		if pgErr.ConstraintName == "planned_workout_completions_workout_key" {
+			return ErrWorkoutAlreadyLinked
+		}
+		return ErrOccurrenceAlreadyCompleted
+	}
+	return err
+}
+
+func (pg *PostgresPlannedWorkoutStore) ListCompletions(userID int, from, to time.Time) ([]*PlannedWorkoutCompletion, error) {
+	query := `
+		SELECT c.planned_workout_id, c.occurrence_date, c.workout_id
+		FROM planned_workout_completions c
+		INNER JOIN planned_workouts p ON p.id = c.planned_workout_id
+		WHERE p.user_id = $1 AND c.occurrence_date BETWEEN $2::DATE AND $3::DATE
+		ORDER BY c.occurrence_date, c.id
+	`
+
+	rows, err := pg.db.Query(query, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completions := []*PlannedWorkoutCompletion{}
+	for rows.Next() {
+		completion := &PlannedWorkoutCompletion{}
+		err = rows.Scan(&completion.PlannedWorkoutID, &completion.OccurrenceDate, &completion.WorkoutID)
+		if err != nil {
+			return nil, err
+		}
+		completions = append(completions, completion)
+	}
+	return completions, rows.Err()
+}
+
+// ListLoggedWorkouts returns the user's workouts logged on the local dates
+// from through to in timezone, oldest first.
+func (pg *PostgresPlannedWorkoutStore) ListLoggedWorkouts(userID int, timezone string, from, to time.Time) ([]*LoggedWorkout, error) {
+	query := `
+		SELECT id, title, duration_minutes, (created_at AT TIME ZONE $2)::DATE AS day, created_at
+		FROM workouts
+		WHERE user_id = $1
+			AND created_at AT TIME ZONE $2 >= $3::DATE
+			AND created_at AT TIME ZONE $2 < $4::DATE + 1
+		ORDER BY created_at, id
+	`
+
+	rows, err := pg.db.Query(query, userID, timezone, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []*LoggedWorkout{}
+	for rows.Next() {
+		workout := &LoggedWorkout{}
+		err = rows.Scan(&workout.ID, &workout.Title, &workout.DurationMinutes, &workout.Date, &workout.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+	return workouts, rows.Err()
+}
@@ -0,0 +1,309 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	ProgressionLinearLoad        = "linear_load"
+	ProgressionDoubleProgression = "double_progression"
+	ProgressionPercentOneRepMax  = "percent_1rm"
+)
+
+var ErrSlotAlreadyCompleted = errors.New("this program day has already been logged")
+
+// ProgressionRule adjusts a slot's template entries for the week of the
+// program. A rule without ExerciseID applies to every entry of the slot.
+//
+//   - linear_load adds Increment to the weight every week.
+//   - double_progression adds a rep each week from MinReps up to MaxReps,
+//     then adds Increment to the weight and starts over at MinReps.
+//   - percent_1rm prescribes Percentages[week] percent of the user's best
+//     estimated one-rep max for Reps reps, rounded to RoundTo.
+type ProgressionRule struct {
+	Type        string    `json:"type"`
+	ExerciseID  *int      `json:"exercise_id,omitempty"`
+	Increment   float64   `json:"increment,omitempty"`
+	MinReps     int       `json:"min_reps,omitempty"`
+	MaxReps     int       `json:"max_reps,omitempty"`
+	Percentages []float64 `json:"percentages,omitempty"`
+	Reps        int       `json:"reps,omitempty"`
+	RoundTo     float64   `json:"round_to,omitempty"`
+}
+
+// ProgramSlot schedules a template on a day (1 = first day of the week) of
+// a program week (1-based).
+type ProgramSlot struct {
+	ID          int               `json:"id"`
+	Week        int               `json:"week"`
+	Day         int               `json:"day"`
+	TemplateID  int               `json:"template_id"`
+	Progression []ProgressionRule `json:"progression"`
+}
+
+type Program struct {
+	ID          int           `json:"id"`
+	UserID      int           `json:"user_id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Weeks       int           `json:"weeks"`
+	IsPublic    bool          `json:"is_public"`
+	Slots       []ProgramSlot `json:"slots"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// ProgramEnrollment is a user following a program from StartDate, a local
+// calendar date stored as midnight UTC.
+type ProgramEnrollment struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	ProgramID int       `json:"program_id"`
+	StartDate time.Time `json:"start_date"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ProgramStore interface {
+	CreateProgram(*Program) error
+	GetProgramByID(id int64) (*Program, error)
+	ListPrograms(userID int, includePublic bool) ([]*Program, error)
+	CreateEnrollment(*ProgramEnrollment) error
+	GetEnrollmentByID(id int64) (*ProgramEnrollment, error)
+	ListEnrollments(userID int) ([]*ProgramEnrollment, error)
+	GetSlotCompletion(enrollmentID, slotID int) (*int, error)
+	CompleteSlot(enrollmentID, slotID, workoutID int) error
+}
+
+type PostgresProgramStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProgramStore(db *sql.DB) *PostgresProgramStore {
+	return &PostgresProgramStore{db: db}
+}
+
+func (pg *PostgresProgramStore) CreateProgram(program *Program) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO programs (user_id, name, description, weeks, is_public)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = tx.QueryRow(
+		query,
+		program.UserID, program.Name, program.Description, program.Weeks, program.IsPublic,
+	).Scan(&program.ID, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	slotQuery := `
+		INSERT INTO program_slots (program_id, week, day, template_id, progression)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	for i := range program.Slots {
+		slot := &program.Slots[i]
+		if slot.Progression == nil {
+			slot.Progression = []ProgressionRule{}
+		}
+
+		progression, err := json.Marshal(slot.Progression)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(slotQuery, program.ID, slot.Week, slot.Day, slot.TemplateID, progression).Scan(&slot.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) GetProgramByID(id int64) (*Program, error) {
+	programs, err := pg.queryPrograms(`WHERE p.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(programs) == 0 {
+		return nil, nil
+	}
+	return programs[0], nil
+}
+
+func (pg *PostgresProgramStore) ListPrograms(userID int, includePublic bool) ([]*Program, error) {
+	return pg.queryPrograms(`WHERE p.user_id = $1 OR ($2 AND p.is_public)`, userID, includePublic)
+}
+
+func (pg *PostgresProgramStore) queryPrograms(where string, args ...any) ([]*Program, error) {
+	query := `
+		SELECT p.id, p.user_id, p.name, COALESCE(p.description, ''), p.weeks, p.is_public, p.created_at, p.updated_at
+		FROM programs p
+		` + where + `
+		ORDER BY p.name, p.id
+	`
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := []*Program{}
+	programsByID := map[int]*Program{}
+	ids := []int{}
+	for rows.Next() {
+		program := &Program{Slots: []ProgramSlot{}}
+		err = rows.Scan(
+			&program.ID, &program.UserID, &program.Name, &program.Description,
+			&program.Weeks, &program.IsPublic, &program.CreatedAt, &program.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+		programsByID[program.ID] = program
+		ids = append(ids, program.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return programs, nil
+	}
+
+	slotRows, err := pg.db.Query(`
+		SELECT program_id, id, week, day, template_id, progression
+		FROM program_slots
+		WHERE program_id = ANY($1)
+		ORDER BY program_id, week, day
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer slotRows.Close()
+
+	for slotRows.Next() {
+		var programID int
+		var slot ProgramSlot
+		var progression []byte
+		err = slotRows.Scan(&programID, &slot.ID, &slot.Week, &slot.Day, &slot.TemplateID, &progression)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(progression, &slot.Progression)
+		if err != nil {
+			return nil, err
+		}
+
+		program := programsByID[programID]
+		program.Slots = append(program.Slots, slot)
+	}
+
+	return programs, slotRows.Err()
+}
+
+func (pg *PostgresProgramStore) CreateEnrollment(enrollment *ProgramEnrollment) error {
+	query := `
+		INSERT INTO program_enrollments (user_id, program_id, start_date)
+		VALUES ($1, $2, $3::DATE)
+		RETURNING id, created_at
+	`
+
+	return pg.db.QueryRow(
+		query,
+		enrollment.UserID, enrollment.ProgramID, enrollment.StartDate.Format(time.DateOnly),
+	).Scan(&enrollment.ID, &enrollment.CreatedAt)
+}
+
+const enrollmentColumns = `id, user_id, program_id, start_date, created_at`
+
+func scanEnrollment(row rowScanner) (*ProgramEnrollment, error) {
+	enrollment := &ProgramEnrollment{}
+	err := row.Scan(&enrollment.ID, &enrollment.UserID, &enrollment.ProgramID, &enrollment.StartDate, &enrollment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (pg *PostgresProgramStore) GetEnrollmentByID(id int64) (*ProgramEnrollment, error) {
+	query := `SELECT ` + enrollmentColumns + ` FROM program_enrollments WHERE id = $1`
+
+	enrollment, err := scanEnrollment(pg.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (pg *PostgresProgramStore) ListEnrollments(userID int) ([]*ProgramEnrollment, error) {
+	query := `SELECT ` + enrollmentColumns + ` FROM program_enrollments WHERE user_id = $1 ORDER BY start_date DESC, id DESC`
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []*ProgramEnrollment{}
+	for rows.Next() {
+		enrollment, err := scanEnrollment(rows)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, rows.Err()
+}
+
+// GetSlotCompletion returns the workout logged for a program day, or nil
+// if the day has not been done yet.
+func (pg *PostgresProgramStore) GetSlotCompletion(enrollmentID, slotID int) (*int, error) {
+	var workoutID int
+
+	query := `
+		SELECT workout_id
+		FROM program_slot_completions
+		WHERE enrollment_id = $1 AND slot_id = $2
+	`
+
+	err := pg.db.QueryRow(query, enrollmentID, slotID).Scan(&workoutID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &workoutID, nil
+}
+
+func (pg *PostgresProgramStore) CompleteSlot(enrollmentID, slotID, workoutID int) error {
+	query := `
+		INSERT INTO program_slot_completions (enrollment_id, slot_id, workout_id)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := pg.db.Exec(query, enrollmentID, slotID, workoutID)
+	if _, ok := isUniqueViolation(err); ok {
+		return ErrSlotAlreadyCompleted
+	}
+	return err
+}
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// StatsQuery covers the local calendar dates From through To (inclusive)
+// in Timezone, bucketed by day, week (starting Monday) or month.
+type StatsQuery struct {
+	From     time.Time
+	To       time.Time
+	Bucket   string
+	Timezone string
+}
+
+type StatsBucket struct {
+	Start                string  `json:"start"`
+	WorkoutCount         int     `json:"workout_count"`
+	TotalDurationMinutes int     `json:"total_duration_minutes"`
+	TotalCaloriesBurned  int     `json:"total_calories_burned"`
+	TotalVolume          float64 `json:"total_volume"`
+	TotalDistanceKm      float64 `json:"total_distance_km"`
+}
+
+type MuscleGroupVolume struct {
+	MuscleGroup string  `json:"muscle_group"`
+	Volume      float64 `json:"volume"`
+}
+
+type StatsAverages struct {
+	DurationMinutes   float64 `json:"duration_minutes"`
+	CaloriesBurned    float64 `json:"calories_burned"`
+	Volume            float64 `json:"volume"`
+	DistanceKm        float64 `json:"distance_km"`
+	WorkoutsPerBucket float64 `json:"workouts_per_bucket"`
+}
+
+type UserStats struct {
+	From                 string              `json:"from"`
+	To                   string              `json:"to"`
+	Bucket               string              `json:"bucket"`
+	Timezone             string              `json:"timezone"`
+	Totals               StatsBucket         `json:"totals"`
+	Averages             StatsAverages       `json:"averages"`
+	Buckets              []StatsBucket       `json:"buckets"`
+	VolumePerMuscleGroup []MuscleGroupVolume `json:"volume_per_muscle_group"`
+	SessionsPerWeekday   map[string]int      `json:"sessions_per_weekday"`
+}
+
+type StatsStore interface {
+	GetUserStats(userID int, query StatsQuery) (*UserStats, error)
+	GetWorkoutDays(userID int, timezone string) ([]time.Time, error)
+	TimezoneSupported(name string) (bool, error)
+}
+
+type PostgresStatsStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStatsStore(db *sql.DB) *PostgresStatsStore {
+	return &PostgresStatsStore{db: db}
+}
+
+// statsWorkoutsCTE selects the user's workouts in the requested local date
+// range together with their local timestamp, training volume and cardio
+// distance. It binds $1 user id, $2 time zone, $3 first date and $4 last
+// date.
+const statsWorkoutsCTE = `
+	WITH ranged AS (
+		SELECT w.id, w.duration_minutes, COALESCE(w.calories_burned, 0) AS calories_burned,
+			w.created_at AT TIME ZONE $2 AS local_at,
+			COALESCE((
+				SELECT SUM(workout_entry_volume(e.id))
+				FROM workout_entries e
+				WHERE e.workout_id = w.id
+			), 0) AS volume,
+			COALESCE((
+				SELECT SUM(e.distance_meters)
+				FROM workout_entries e
+				WHERE e.workout_id = w.id
+			), 0) AS distance_meters
+		FROM workouts w
+		WHERE w.user_id = $1
+			AND w.created_at AT TIME ZONE $2 >= $3::DATE
+			AND w.created_at AT TIME ZONE $2 < $4::DATE + 1
+	)
+`
+
+func (pg *PostgresStatsStore) GetUserStats(userID int, query StatsQuery) (*UserStats, error) {
+	from := query.From.Format(time.DateOnly)
+	to := query.To.Format(time.DateOnly)
+
+	stats := &UserStats{
+		From:                 from,
+		To:                   to,
+		Bucket:               query.Bucket,
+		Timezone:             query.Timezone,
+		Buckets:              []StatsBucket{},
+		VolumePerMuscleGroup: []MuscleGroupVolume{},
+		SessionsPerWeekday:   map[string]int{},
+	}
+
+	bucketQuery := statsWorkoutsCTE + `
+		SELECT b.start::DATE,
+			COUNT(r.id),
+			COALESCE(SUM(r.duration_minutes), 0),
+			COALESCE(SUM(r.calories_burned), 0),
+			COALESCE(SUM(r.volume), 0),
+			COALESCE(SUM(r.distance_meters), 0) / 1000
+		FROM generate_series(
+			date_trunc($5, $3::DATE::TIMESTAMP),
+			date_trunc($5, $4::DATE::TIMESTAMP),
+			('1 ' || $5)::INTERVAL
+		) AS b (start)
+		LEFT JOIN ranged r ON date_trunc($5, r.local_at) = b.start
+		GROUP BY b.start
+		ORDER BY b.start
+	`
+
+	rows, err := pg.db.Query(bucketQuery, userID, query.Timezone, from, to, query.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket StatsBucket
+		var start time.Time
+		err = rows.Scan(
+			&start, &bucket.WorkoutCount, &bucket.TotalDurationMinutes,
+			&bucket.TotalCaloriesBurned, &bucket.TotalVolume, &bucket.TotalDistanceKm,
+		)
+		if err != nil {
+			return nil, err
+		}
+		bucket.Start = start.Format(time.DateOnly)
+		stats.Buckets = append(stats.Buckets, bucket)
+
+		stats.Totals.WorkoutCount += bucket.WorkoutCount
+		stats.Totals.TotalDurationMinutes += bucket.TotalDurationMinutes
+		stats.Totals.TotalCaloriesBurned += bucket.TotalCaloriesBurned
+		stats.Totals.TotalVolume += bucket.TotalVolume
+		stats.Totals.TotalDistanceKm += bucket.TotalDistanceKm
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	stats.Totals.Start = from
+
+	if stats.Totals.WorkoutCount > 0 {
+		count := float64(stats.Totals.WorkoutCount)
+		stats.Averages = StatsAverages{
+			DurationMinutes:   float64(stats.Totals.TotalDurationMinutes) / count,
+			CaloriesBurned:    float64(stats.Totals.TotalCaloriesBurned) / count,
+			Volume:            stats.Totals.TotalVolume / count,
+			DistanceKm:        stats.Totals.TotalDistanceKm / count,
+			WorkoutsPerBucket: count / float64(len(stats.Buckets)),
+		}
+	}
+
+	// volume is credited in full to every primary muscle of the exercise
+	muscleQuery := statsWorkoutsCTE + `
+		SELECT m.muscle_group, SUM(workout_entry_volume(e.id)) AS volume
+		FROM ranged r
+		INNER JOIN workout_entries e ON e.workout_id = r.id
+		INNER JOIN exercises x ON x.id = e.exercise_id
+		CROSS JOIN LATERAL unnest(x.primary_muscles) AS m (muscle_group)
+		GROUP BY m.muscle_group
+		HAVING SUM(workout_entry_volume(e.id)) > 0
+		ORDER BY volume DESC, m.muscle_group
+	`
+
+	muscleRows, err := pg.db.Query(muscleQuery, userID, query.Timezone, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer muscleRows.Close()
+
+	for muscleRows.Next() {
+		var volume MuscleGroupVolume
+		err = muscleRows.Scan(&volume.MuscleGroup, &volume.Volume)
+		if err != nil {
+			return nil, err
+		}
+		stats.VolumePerMuscleGroup = append(stats.VolumePerMuscleGroup, volume)
+	}
+	if err = muscleRows.Err(); err != nil {
+		return nil, err
+	}
+
+	weekdayQuery := statsWorkoutsCTE + `
+		SELECT EXTRACT(ISODOW FROM local_at)::INTEGER, COUNT(*)
+		FROM ranged
+		GROUP BY 1
+	`
+
+	weekdayRows, err := pg.db.Query(weekdayQuery, userID, query.Timezone, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer weekdayRows.Close()
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		stats.SessionsPerWeekday[day.String()] = 0
+	}
+	for weekdayRows.Next() {
+		var isoDay, count int
+		err = weekdayRows.Scan(&isoDay, &count)
+		if err != nil {
+			return nil, err
+		}
+		stats.SessionsPerWeekday[time.Weekday(isoDay%7).String()] = count
+	}
+
+	return stats, weekdayRows.Err()
+}
+
+// GetWorkoutDays returns the distinct local dates, oldest first, on which
+// the user logged a workout, as midnight UTC values.
+func (pg *PostgresStatsStore) GetWorkoutDays(userID int, timezone string) ([]time.Time, error) {
+	query := `
+		SELECT DISTINCT (created_at AT TIME ZONE $2)::DATE AS day
+		FROM workouts
+		WHERE user_id = $1
+		ORDER BY day
+	`
+
+	rows, err := pg.db.Query(query, userID, timezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []time.Time{}
+	for rows.Next() {
+		var day time.Time
+		err = rows.Scan(&day)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC))
+	}
+	return days, rows.Err()
+}
+
+// TimezoneSupported reports whether Postgres knows the named time zone. Go
+// and Postgres ship their own zone databases, and AT TIME ZONE fails on a
+// name only Go accepts.
+func (pg *PostgresStatsStore) TimezoneSupported(name string) (bool, error) {
+	return timezoneSupported(pg.db, name)
+}
+
+func timezoneSupported(db *sql.DB, name string) (bool, error) {
+	var supported bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = $1)`, name).Scan(&supported)
+	return supported, err
+}
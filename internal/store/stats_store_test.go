@@ -0,0 +1,56 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	statsStore := NewPostgresStatsStore(db)
+	user := createTestUser(t, db, "stats_user")
+
+	supported, err := statsStore.TimezoneSupported("Europe/Berlin")
+	require.NoError(t, err)
+	assert.True(t, supported)
+	supported, err = statsStore.TimezoneSupported("Local")
+	require.NoError(t, err)
+	assert.False(t, supported)
+
+	// Sunday 23:30 UTC is already Monday in Berlin
+	_, _, err = workoutStore.ImportWorkouts(user.ID, []*Workout{
+		{Title: "late run", DurationMinutes: 30, ExternalID: "stats:1", CreatedAt: time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC), Entries: []WorkoutEntry{
+			{ExerciseName: "Running", Kind: EntryKindCardio, Sets: 1, DurationSeconds: IntPtr(1800), Distance: FloatPtr(5), DistanceUnit: "km", OrderIndex: 1},
+		}},
+		{Title: "squats", DurationMinutes: 60, ExternalID: "stats:2", CreatedAt: time.Date(2026, 3, 4, 18, 0, 0, 0, time.UTC), Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Kind: EntryKindStrength, Sets: 2, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		}},
+	})
+	require.NoError(t, err)
+
+	stats, err := statsStore.GetUserStats(user.ID, StatsQuery{
+		From: time.Date(2026, 2, 23, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC),
+		Bucket: BucketWeek, Timezone: "Europe/Berlin",
+	})
+	require.NoError(t, err)
+	require.Len(t, stats.Buckets, 2)
+	assert.Equal(t, "2026-02-23", stats.Buckets[0].Start)
+	assert.Equal(t, 0, stats.Buckets[0].WorkoutCount)
+	assert.Equal(t, "2026-03-02", stats.Buckets[1].Start)
+	assert.Equal(t, 2, stats.Buckets[1].WorkoutCount)
+	assert.Equal(t, 90, stats.Totals.TotalDurationMinutes)
+	assert.InDelta(t, 5, stats.Totals.TotalDistanceKm, 0.001)
+	assert.InDelta(t, 1000, stats.Totals.TotalVolume, 0.001)
+	assert.Equal(t, 1, stats.SessionsPerWeekday["Monday"])
+	assert.Equal(t, 0, stats.SessionsPerWeekday["Sunday"])
+
+	days, err := statsStore.GetWorkoutDays(user.ID, "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)}, days)
+}
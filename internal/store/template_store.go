@@ -0,0 +1,219 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// WorkoutTemplate is a reusable routine. Its entries mirror workout entries
+// so a template can be instantiated straight into a workout.
+type WorkoutTemplate struct {
+	ID              int                 `json:"id"`
+	UserID          int                 `json:"user_id"`
+	Title           string              `json:"title"`
+	Description     string              `json:"description"`
+	DurationMinutes int                 `json:"duration_minutes"`
+	IsPublic        bool                `json:"is_public"`
+	Groups          []WorkoutEntryGroup `json:"groups"`
+	Entries         []WorkoutEntry      `json:"entries"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+type TemplateStore interface {
+	CreateTemplate(*WorkoutTemplate) error
+	GetTemplateByID(id int64) (*WorkoutTemplate, error)
+	ListTemplates(userID int, includePublic bool) ([]*WorkoutTemplate, error)
+	DeleteTemplate(id int64) error
+}
+
+type PostgresTemplateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTemplateStore(db *sql.DB) *PostgresTemplateStore {
+	return &PostgresTemplateStore{db: db}
+}
+
+func (pg *PostgresTemplateStore) CreateTemplate(template *WorkoutTemplate) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO workout_templates (user_id, title, description, duration_minutes, is_public)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = tx.QueryRow(
+		query,
+		template.UserID, template.Title, template.Description, template.DurationMinutes, template.IsPublic,
+	).Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	template.Groups = nonNilGroups(template.Groups)
+	err = insertEntryGroups(tx, templateGroupTable, templateGroupOwner, template.ID, template.Groups)
+	if err != nil {
+		return err
+	}
+
+	entryQuery := `
+		INSERT INTO workout_template_entries (
+			template_id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index, group_index,
+			kind, distance_meters, distance_unit, elevation_gain_meters, avg_heart_rate, max_heart_rate, avg_cadence
+		)
+		VALUES ($1, COALESCE(visible_exercise_id($2, $18), resolve_exercise_id($3, $18)), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id, exercise_id
+	`
+
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		entry.PersonalRecords = nil
+
+		syncEntrySets(entry)
+
+		err = tx.QueryRow(
+			entryQuery,
+			template.ID, entry.ExerciseID, entry.ExerciseName, entry.Sets,
+			entry.Reps, entry.DurationSeconds, entry.Weight,
+			entry.Notes, entry.OrderIndex, entry.GroupIndex,
+			entry.Kind, entry.DistanceMeters(), nullString(entry.DistanceUnit), entry.ElevationGainMeters,
+			entry.AvgHeartRate, entry.MaxHeartRate, entry.AvgCadence, template.UserID,
+		).Scan(&entry.ID, &entry.ExerciseID)
+		if err != nil {
+			return err
+		}
+
+		err = insertWorkoutSets(tx, templateSetTable, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresTemplateStore) GetTemplateByID(id int64) (*WorkoutTemplate, error) {
+	templates, err := pg.queryTemplates(`WHERE t.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	return templates[0], nil
+}
+
+// ListTemplates returns the user's own templates and, if asked, every
+// public template shared by other users.
+func (pg *PostgresTemplateStore) ListTemplates(userID int, includePublic bool) ([]*WorkoutTemplate, error) {
+	return pg.queryTemplates(`WHERE t.user_id = $1 OR ($2 AND t.is_public)`, userID, includePublic)
+}
+
+func (pg *PostgresTemplateStore) queryTemplates(where string, args ...any) ([]*WorkoutTemplate, error) {
+	query := `
+		SELECT t.id, t.user_id, t.title, COALESCE(t.description, ''), COALESCE(t.duration_minutes, 0),
+			t.is_public, t.created_at, t.updated_at
+		FROM workout_templates t
+		` + where + `
+		ORDER BY t.title, t.id
+	`
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*WorkoutTemplate{}
+	templatesByID := map[int]*WorkoutTemplate{}
+	ids := []int{}
+	for rows.Next() {
+		template := &WorkoutTemplate{Groups: []WorkoutEntryGroup{}, Entries: []WorkoutEntry{}}
+		err = rows.Scan(
+			&template.ID, &template.UserID, &template.Title, &template.Description,
+			&template.DurationMinutes, &template.IsPublic, &template.CreatedAt, &template.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+		templatesByID[template.ID] = template
+		ids = append(ids, template.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return templates, nil
+	}
+
+	groups, err := loadEntryGroups(pg.db, templateGroupTable, templateGroupOwner, ids)
+	if err != nil {
+		return nil, err
+	}
+	for templateID, templateGroups := range groups {
+		templatesByID[templateID].Groups = templateGroups
+	}
+
+	entryQuery := `
+		SELECT template_id, ` + workoutEntryColumns + `
+		FROM workout_template_entries
+		WHERE template_id = ANY($1)
+		ORDER BY template_id, order_index
+	`
+
+	entryRows, err := pg.db.Query(entryQuery, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer entryRows.Close()
+
+	for entryRows.Next() {
+		var templateID int
+		var entry WorkoutEntry
+		err = scanWorkoutEntry(prefixedScanner{entryRows, &templateID}, &entry)
+		if err != nil {
+			return nil, err
+		}
+		template := templatesByID[templateID]
+		template.Entries = append(template.Entries, entry)
+	}
+	if err = entryRows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries := []*WorkoutEntry{}
+	for _, template := range templates {
+		for i := range template.Entries {
+			entries = append(entries, &template.Entries[i])
+		}
+	}
+	err = attachEntrySets(pg.db, templateSetTable, entries)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (pg *PostgresTemplateStore) DeleteTemplate(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM workout_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
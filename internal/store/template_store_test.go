@@ -0,0 +1,110 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	templateStore := NewPostgresTemplateStore(db)
+	coach := createTestUser(t, db, "template_coach")
+	athlete := createTestUser(t, db, "template_athlete")
+
+	private := &WorkoutTemplate{
+		UserID: coach.ID, Title: "push", DurationMinutes: 45,
+		Groups: []WorkoutEntryGroup{{GroupIndex: 1, Type: GroupSuperset, Rounds: IntPtr(3)}},
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", OrderIndex: 1, GroupIndex: IntPtr(1), SetDetails: []WorkoutSet{
+				{Type: SetTypeWarmup, Reps: IntPtr(10), Weight: FloatPtr(40)},
+				{Reps: IntPtr(5), Weight: FloatPtr(80)},
+				{Reps: IntPtr(5), Weight: FloatPtr(85)},
+			}},
+			{ExerciseName: "Push Up", OrderIndex: 2, GroupIndex: IntPtr(1), Sets: 3, Reps: IntPtr(15)},
+		},
+	}
+	require.NoError(t, templateStore.CreateTemplate(private))
+	require.NotZero(t, private.ID)
+
+	public := &WorkoutTemplate{
+		UserID: coach.ID, Title: "easy run", IsPublic: true,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Running", Kind: EntryKindCardio, Sets: 1, DurationSeconds: IntPtr(1800), OrderIndex: 1},
+		},
+	}
+	require.NoError(t, templateStore.CreateTemplate(public))
+
+	got, err := templateStore.GetTemplateByID(int64(private.ID))
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "push", got.Title)
+	require.Len(t, got.Groups, 1)
+	assert.Equal(t, GroupSuperset, got.Groups[0].Type)
+	require.Len(t, got.Entries, 2)
+	assert.NotNil(t, got.Entries[0].ExerciseID)
+
+	// the set log is kept as prescribed, warm-up included, and the
+	// aggregates are derived from it as for workouts
+	bench := got.Entries[0]
+	assert.Equal(t, 2, bench.Sets)
+	assert.Equal(t, 5, *bench.Reps)
+	assert.InDelta(t, 85, *bench.Weight, 0.001)
+	require.Len(t, bench.SetDetails, 3)
+	assert.Equal(t, SetTypeWarmup, bench.SetDetails[0].Type)
+	assert.InDelta(t, 40, *bench.SetDetails[0].Weight, 0.001)
+	assert.Equal(t, SetTypeWorking, bench.SetDetails[2].Type)
+	assert.Equal(t, 3, bench.SetDetails[2].SetIndex)
+	assert.Len(t, got.Entries[1].SetDetails, 3)
+
+	// a template of nothing but warm-ups still round-trips
+	warmup := &WorkoutTemplate{
+		UserID: coach.ID, Title: "warm-up",
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", OrderIndex: 1, SetDetails: []WorkoutSet{
+				{Type: SetTypeWarmup, Reps: IntPtr(10), Weight: FloatPtr(20)},
+				{Type: SetTypeWarmup, Reps: IntPtr(5), Weight: FloatPtr(60)},
+			}},
+		},
+	}
+	require.NoError(t, templateStore.CreateTemplate(warmup))
+	got, err = templateStore.GetTemplateByID(int64(warmup.ID))
+	require.NoError(t, err)
+	require.Len(t, got.Entries, 1)
+	assert.Len(t, got.Entries[0].SetDetails, 2)
+	require.NoError(t, templateStore.DeleteTemplate(int64(warmup.ID)))
+
+	missing, err := templateStore.GetTemplateByID(int64(public.ID + 100))
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	titles := func(templates []*WorkoutTemplate) []string {
+		names := []string{}
+		for _, template := range templates {
+			names = append(names, template.Title)
+		}
+		return names
+	}
+
+	templates, err := templateStore.ListTemplates(coach.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"easy run", "push"}, titles(templates))
+
+	templates, err = templateStore.ListTemplates(athlete.ID, false)
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+
+	templates, err = templateStore.ListTemplates(athlete.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"easy run"}, titles(templates))
+
+	require.NoError(t, templateStore.DeleteTemplate(int64(private.ID)))
+	assert.ErrorIs(t, templateStore.DeleteTemplate(int64(private.ID)), sql.ErrNoRows)
+	got, err = templateStore.GetTemplateByID(int64(private.ID))
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
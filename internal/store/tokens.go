@@ -1,7 +1,9 @@
 package store
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/ruhan/internal/tokens"
@@ -21,6 +23,52 @@ type TokenStore interface {
 	Insert(token *tokens.Token) error
 	CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error)
 	DeleteAllTokens(userId int, scope string) error
+	ListTokens(userID int) ([]*TokenMetadata, error)
+	ListSessions(userID int) ([]*Session, error)
+	DeleteSession(userID int, id int64) error
+	DeleteOtherSessions(userID int, keepID int64) error
+	TouchToken(tokenID int64, at time.Time) error
+	GetRefreshToken(tokenPlainText string) (*RefreshToken, error)
+	UseRefreshToken(id int64) error
+	CleanUpTokens() (int64, error)
+	CreateAPIKey(key *APIKey, token *tokens.Token) error
+	ListAPIKeys(userID int) ([]*APIKey, error)
+	DeleteAPIKey(userID int, id int64) error
+}
+
+// ErrRefreshTokenReused is returned for a refresh token that was already
+// exchanged, which means it leaked or a client replayed it.
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+// RefreshToken is a stored refresh token. UsedAt is set once it has been
+// exchanged for a new pair.
+type RefreshToken struct {
+	ID       int64
+	UserID   int
+	FamilyID int64
+	Expiry   time.Time
+	UsedAt   *time.Time
+}
+
+// TokenMetadata describes a token without revealing it.
+type TokenMetadata struct {
+	Scope  string    `json:"scope"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// Session is a login as its owner sees it: a token family, identified by
+// its family ID.
+type Session struct {
+	ID int64 `json:"id"`
+	// TokenID is the access token a request was authenticated with.
+	TokenID    int64      `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Expiry     time.Time  `json:"expiry"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	// Current marks the session the request listing them was made with.
+	Current bool `json:"current"`
 }
 
 func (t *PostgersTokenStore) CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
@@ -34,15 +82,12 @@ func (t *PostgersTokenStore) CreateNewToken(userID int, ttl time.Duration, scope
 
 func (t *PostgersTokenStore) Insert(token *tokens.Token) error {
 	query := `
-			INSERT INTO tokens(hash, user_id, expiry, scope)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO tokens(hash, user_id, expiry, scope, user_agent, ip_address, family_id)
+			VALUES ($1, $2, $3, $4, $5, $6, COALESCE(NULLIF($7, 0), nextval('token_families_seq')))
+			RETURNING id, family_id
 		`
 
-	_, err := t.db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope)
-	if err != nil {
-		return err
-	}
-	return nil
+	return t.db.QueryRow(query, token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.IPAddress, token.FamilyID).Scan(&token.ID, &token.FamilyID)
 }
 
 func (t *PostgersTokenStore) DeleteAllTokens(userId int, scope string) error {
@@ -54,3 +99,162 @@ func (t *PostgersTokenStore) DeleteAllTokens(userId int, scope string) error {
 	_, err := t.db.Exec(query, scope, userId)
 	return err
 }
+
+func (t *PostgersTokenStore) ListTokens(userID int) ([]*TokenMetadata, error) {
+	rows, err := t.db.Query(`
+		SELECT scope, expiry
+		FROM tokens
+		WHERE user_id = $1
+		ORDER BY expiry
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metadata := []*TokenMetadata{}
+	for rows.Next() {
+		token := &TokenMetadata{}
+		err = rows.Scan(&token.Scope, &token.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		metadata = append(metadata, token)
+	}
+	return metadata, rows.Err()
+}
+
+// ListSessions returns the user's logins that still have an unexpired
+// token, most recently used first. The user agent and IP address are those
+// of the newest token, so they follow the client as it refreshes.
+func (t *PostgersTokenStore) ListSessions(userID int) ([]*Session, error) {
+	rows, err := t.db.Query(`
+		SELECT family_id, MIN(created_at), MAX(last_used_at), MAX(expiry) FILTER (WHERE used_at IS NULL),
+			(ARRAY_AGG(user_agent ORDER BY id DESC))[1], (ARRAY_AGG(ip_address ORDER BY id DESC))[1]
+		FROM tokens
+		WHERE user_id = $1 AND scope IN ($2, $3)
+		GROUP BY family_id
+		HAVING BOOL_OR(expiry > $4 AND used_at IS NULL)
+		ORDER BY COALESCE(MAX(last_used_at), MIN(created_at)) DESC, family_id DESC
+	`, userID, tokens.ScopeAuth, tokens.ScopeRefresh, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session := &Session{}
+		err = rows.Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.Expiry, &session.UserAgent, &session.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession revokes every access and refresh token of one of the user's
+// logins. It returns sql.ErrNoRows if the user has no such session.
+func (t *PostgersTokenStore) DeleteSession(userID int, id int64) error {
+	result, err := t.db.Exec(`
+		DELETE FROM tokens
+		WHERE family_id = $1 AND user_id = $2 AND scope IN ($3, $4)
+	`, id, userID, tokens.ScopeAuth, tokens.ScopeRefresh)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteOtherSessions revokes the access and refresh tokens of every login
+// of the user except the session keepID. A keepID of zero revokes them all.
+func (t *PostgersTokenStore) DeleteOtherSessions(userID int, keepID int64) error {
+	_, err := t.db.Exec(`
+		DELETE FROM tokens
+		WHERE user_id = $1 AND scope IN ($2, $3) AND family_id <> $4
+	`, userID, tokens.ScopeAuth, tokens.ScopeRefresh, keepID)
+	return err
+}
+
+// TouchToken records that an access token or API key was used at the given
+// time.
+func (t *PostgersTokenStore) TouchToken(tokenID int64, at time.Time) error {
+	_, err := t.db.Exec(`
+		UPDATE tokens
+		SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $1)
+	`, at, tokenID)
+	return err
+}
+
+// GetRefreshToken looks up a refresh token, used or not and expired or not,
+// so a replayed token is recognized even after it expired. It returns nil if
+// there is none.
+func (t *PostgersTokenStore) GetRefreshToken(tokenPlainText string) (*RefreshToken, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	token := &RefreshToken{}
+	err := t.db.QueryRow(`
+		SELECT id, user_id, family_id, expiry, used_at
+		FROM tokens
+		WHERE hash = $1 AND scope = $2
+	`, tokenHash[:], tokens.ScopeRefresh).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.Expiry, &token.UsedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// UseRefreshToken marks a refresh token as exchanged. Only one caller can
+// do so; the others get ErrRefreshTokenReused.
+func (t *PostgersTokenStore) UseRefreshToken(id int64) error {
+	result, err := t.db.Exec(`
+		UPDATE tokens
+		SET used_at = CURRENT_TIMESTAMP, last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND used_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// CleanUpTokens deletes expired tokens and used refresh tokens. Refresh
+// tokens, used or expired, are kept while their login still has a live
+// refresh token, so replaying one still revokes that login. It returns the
+// number of tokens deleted.
+func (t *PostgersTokenStore) CleanUpTokens() (int64, error) {
+	result, err := t.db.Exec(`
+		DELETE FROM tokens t
+		WHERE (t.expiry <= CURRENT_TIMESTAMP OR t.used_at IS NOT NULL)
+			AND NOT (t.scope = $1 AND EXISTS (
+				SELECT 1 FROM tokens live
+				WHERE live.family_id = t.family_id AND live.scope = $1
+					AND live.used_at IS NULL AND live.expiry > CURRENT_TIMESTAMP
+			))
+	`, tokens.ScopeRefresh)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
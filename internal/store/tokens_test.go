@@ -0,0 +1,217 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "sessions_user")
+	other := createTestUser(t, db, "sessions_other")
+
+	phone, err := tokens.GenerateToken(user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	phone.UserAgent, phone.IPAddress = "phone", "10.0.0.1"
+	require.NoError(t, tokenStore.Insert(phone))
+	laptop, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	_, err = tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeCalendarFeed)
+	require.NoError(t, err)
+
+	got, session, err := userStore.GetUserSession(phone.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, phone.FamilyID, session.ID)
+	assert.Equal(t, phone.ID, session.TokenID)
+	assert.Equal(t, "phone", session.UserAgent)
+	assert.Nil(t, session.LastUsedAt)
+
+	require.NoError(t, tokenStore.TouchToken(phone.ID, time.Now()))
+	sessions, err := tokenStore.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, phone.FamilyID, sessions[0].ID)
+	assert.NotNil(t, sessions[0].LastUsedAt)
+	assert.Equal(t, laptop.FamilyID, sessions[1].ID)
+
+	tests := []struct {
+		name   string
+		userID int
+		id     int64
+		err    error
+	}{
+		{name: "someone else's session", userID: other.ID, id: laptop.FamilyID, err: sql.ErrNoRows},
+		{name: "own session", userID: user.ID, id: laptop.FamilyID},
+		{name: "already revoked", userID: user.ID, id: laptop.FamilyID, err: sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tokenStore.DeleteSession(tt.userID, tt.id)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	got, session, err = userStore.GetUserSession(laptop.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	assert.Nil(t, session)
+}
+
+func TestDeleteOtherSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "other_sessions_user")
+
+	current, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	refresh, err := tokens.GenerateToken(user.ID, time.Hour, tokens.ScopeRefresh)
+	require.NoError(t, err)
+	refresh.FamilyID = current.FamilyID
+	require.NoError(t, tokenStore.Insert(refresh))
+	stale, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	feed, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeCalendarFeed)
+	require.NoError(t, err)
+
+	require.NoError(t, tokenStore.DeleteOtherSessions(user.ID, current.FamilyID))
+
+	sessions, err := tokenStore.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.FamilyID, sessions[0].ID)
+
+	got, _, err := userStore.GetUserSession(stale.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = userStore.GetUserToken(tokens.ScopeCalendarFeed, feed.PlainText)
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+
+	require.NoError(t, tokenStore.DeleteOtherSessions(user.ID, 0))
+	sessions, err = tokenStore.ListSessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestRefreshTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "refresh_user")
+
+	access, err := tokenStore.CreateNewToken(user.ID, time.Minute, tokens.ScopeAuth)
+	require.NoError(t, err)
+	refresh, err := tokens.GenerateToken(user.ID, time.Hour, tokens.ScopeRefresh)
+	require.NoError(t, err)
+	refresh.FamilyID = access.FamilyID
+	require.NoError(t, tokenStore.Insert(refresh))
+	assert.Equal(t, access.FamilyID, refresh.FamilyID)
+
+	got, err := tokenStore.GetRefreshToken(refresh.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, refresh.ID, got.ID)
+	assert.Equal(t, access.FamilyID, got.FamilyID)
+	assert.Nil(t, got.UsedAt)
+
+	require.NoError(t, tokenStore.UseRefreshToken(got.ID))
+	assert.ErrorIs(t, tokenStore.UseRefreshToken(got.ID), ErrRefreshTokenReused)
+
+	got, err = tokenStore.GetRefreshToken(refresh.PlainText)
+	require.NoError(t, err)
+	assert.NotNil(t, got.UsedAt)
+
+	// the access token isn't a refresh token
+	got, err = tokenStore.GetRefreshToken(access.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// expired tokens are still found, so their reuse can be detected
+	expired, err := tokens.GenerateToken(user.ID, -time.Minute, tokens.ScopeRefresh)
+	require.NoError(t, err)
+	expired.FamilyID = access.FamilyID
+	require.NoError(t, tokenStore.Insert(expired))
+	got, err = tokenStore.GetRefreshToken(expired.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.True(t, got.Expiry.Before(time.Now()))
+
+	sessions, err := tokenStore.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, access.FamilyID, sessions[0].ID)
+
+	require.NoError(t, tokenStore.DeleteSession(user.ID, access.FamilyID))
+	got, err = tokenStore.GetRefreshToken(refresh.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestCleanUpTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "cleanup_user")
+
+	insert := func(ttl time.Duration, scope string, familyID int64) *tokens.Token {
+		token, err := tokens.GenerateToken(user.ID, ttl, scope)
+		require.NoError(t, err)
+		token.FamilyID = familyID
+		require.NoError(t, tokenStore.Insert(token))
+		return token
+	}
+	exists := func(token *tokens.Token) bool {
+		var found bool
+		require.NoError(t, db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tokens WHERE id = $1)`, token.ID).Scan(&found))
+		return found
+	}
+
+	// a live login: its used and expired refresh tokens stay for reuse
+	// detection, its expired access token goes
+	live := insert(time.Hour, tokens.ScopeRefresh, 0)
+	liveUsed := insert(time.Hour, tokens.ScopeRefresh, live.FamilyID)
+	require.NoError(t, tokenStore.UseRefreshToken(liveUsed.ID))
+	liveExpired := insert(-time.Minute, tokens.ScopeRefresh, live.FamilyID)
+	expiredAccess := insert(-time.Minute, tokens.ScopeAuth, live.FamilyID)
+	access := insert(time.Hour, tokens.ScopeAuth, live.FamilyID)
+
+	// a lapsed login whose only refresh token was used
+	lapsed := insert(time.Hour, tokens.ScopeRefresh, 0)
+	require.NoError(t, tokenStore.UseRefreshToken(lapsed.ID))
+	lapsedExpired := insert(-time.Minute, tokens.ScopeRefresh, lapsed.FamilyID)
+
+	reset := insert(-time.Minute, tokens.ScopePasswordReset, 0)
+
+	deleted, err := tokenStore.CleanUpTokens()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(4))
+
+	for _, token := range []*tokens.Token{live, liveUsed, liveExpired, access} {
+		assert.True(t, exists(token), token.Scope)
+	}
+	for _, token := range []*tokens.Token{expiredAccess, lapsed, lapsedExpired, reset} {
+		assert.False(t, exists(token), token.Scope)
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	TrackFormatGPX = "gpx"
+	TrackFormatTCX = "tcx"
+	TrackFormatFIT = "fit"
+)
+
+// WorkoutTrack is the route of an imported GPS recording, simplified and
+// stored as a Google encoded polyline for drawing on a map. StartedAt is nil
+// when the recording had no timestamps.
+type WorkoutTrack struct {
+	WorkoutID          int        `json:"workout_id"`
+	SourceFormat       string     `json:"source_format"`
+	Polyline           string     `json:"polyline"`
+	PointCount         int        `json:"point_count"`
+	OriginalPointCount int        `json:"original_point_count"`
+	StartedAt          *time.Time `json:"started_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+type TrackStore interface {
+	ImportWorkout(workout *Workout, track *WorkoutTrack, startedAt *time.Time) (*Workout, error)
+	GetTrackByWorkoutID(workoutID int64) (*WorkoutTrack, error)
+}
+
+// PostgresTrackStore creates imported workouts through the workout store so
+// they get the same entries, sets and record detection as logged ones.
+type PostgresTrackStore struct {
+	db       *sql.DB
+	workouts *PostgresWorkoutStore
+}
+
+func NewPostgresTrackStore(db *sql.DB, workouts *PostgresWorkoutStore) *PostgresTrackStore {
+	return &PostgresTrackStore{db: db, workouts: workouts}
+}
+
+// ImportWorkout creates the workout and its track in one transaction, dating
+// the workout to startedAt when the recording has a start time. track is nil
+// for recordings without a route, such as strength sessions.
+func (pg *PostgresTrackStore) ImportWorkout(workout *Workout, track *WorkoutTrack, startedAt *time.Time) (*Workout, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = pg.workouts.createWorkout(tx, workout, startedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if track != nil {
+		track.WorkoutID = workout.ID
+		err = tx.QueryRow(`
+			INSERT INTO workout_tracks (workout_id, source_format, polyline, point_count, original_point_count, started_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING created_at
+		`, track.WorkoutID, track.SourceFormat, track.Polyline, track.PointCount, track.OriginalPointCount, track.StartedAt).Scan(&track.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return workout, nil
+}
+
+func (pg *PostgresTrackStore) GetTrackByWorkoutID(workoutID int64) (*WorkoutTrack, error) {
+	track := &WorkoutTrack{}
+	err := pg.db.QueryRow(`
+		SELECT workout_id, source_format, polyline, point_count, original_point_count, started_at, created_at
+		FROM workout_tracks
+		WHERE workout_id = $1
+	`, workoutID).Scan(
+		&track.WorkoutID, &track.SourceFormat, &track.Polyline, &track.PointCount,
+		&track.OriginalPointCount, &track.StartedAt, &track.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportWorkout(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	store := NewPostgresTrackStore(db, workoutStore)
+	user := createTestUser(t, db, "track_user")
+
+	startedAt := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	workout, err := store.ImportWorkout(&Workout{
+		UserID: user.ID, Title: "Morning Run", DurationMinutes: 30,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Running", Kind: EntryKindCardio, OrderIndex: 1, DurationSeconds: IntPtr(1800), Distance: FloatPtr(5.2), DistanceUnit: "km"},
+		},
+	}, &WorkoutTrack{SourceFormat: TrackFormatGPX, Polyline: "_p~iF~ps|U_ulLnnqC", PointCount: 2, OriginalPointCount: 40, StartedAt: &startedAt}, &startedAt)
+	require.NoError(t, err)
+	assert.True(t, startedAt.Equal(workout.CreatedAt))
+
+	track, err := store.GetTrackByWorkoutID(int64(workout.ID))
+	require.NoError(t, err)
+	require.NotNil(t, track)
+	assert.Equal(t, "_p~iF~ps|U_ulLnnqC", track.Polyline)
+	assert.Equal(t, 40, track.OriginalPointCount)
+
+	// a failing track insert leaves no workout behind
+	_, err = store.ImportWorkout(&Workout{
+		UserID: user.ID, Title: "broken", DurationMinutes: 10,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Running", Kind: EntryKindCardio, OrderIndex: 1, DurationSeconds: IntPtr(600)},
+		},
+	}, &WorkoutTrack{SourceFormat: "kml"}, nil)
+	require.Error(t, err)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE title = 'broken'`).Scan(&count))
+	assert.Equal(t, 0, count)
+
+	track, err = store.GetTrackByWorkoutID(0)
+	require.NoError(t, err)
+	assert.Nil(t, track)
+}
@@ -4,9 +4,10 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
-	"fmt"
 	"time"
 
+	"github.com/jackc/pgtype"
+	"github.com/ruhan/internal/tokens"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -40,14 +41,35 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
+const (
+	ProfilePublic  = "public"
+	ProfilePrivate = "private"
+)
+
+var (
+	ErrUsernameTaken = errors.New("username is already taken")
+	ErrEmailTaken    = errors.New("email is already in use")
+)
+
 type User struct {
-	ID           int       `json:"id"`
-	UserName     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash password  `json:"-"`
-	Bio          string    `json:"bio"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           int      `json:"id"`
+	UserName     string   `json:"username"`
+	Email        string   `json:"email"`
+	PasswordHash password `json:"-"`
+	Bio          string   `json:"bio"`
+	Timezone     string   `json:"timezone"`
+	RestDays     []string `json:"rest_days"`
+	WeeklyTarget int      `json:"weekly_workout_target"`
+	// ProfileVisibility decides what GET /users/{username} shows others.
+	ProfileVisibility string    `json:"profile_visibility"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	// DeletionScheduledFor is set while the account waits out the grace
+	// period before it is deleted.
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for"`
+	// EmailVerifiedAt is nil until the user confirms their email address,
+	// and again after they change it.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 var AnonymousUser = &User{}
@@ -56,6 +78,10 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+func (u *User) IsVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
 type PostgresUserStore struct {
 	db *sql.DB
 }
@@ -69,97 +95,234 @@ func NewPostgreUserStore(db *sql.DB) *PostgresUserStore {
 type UserStore interface {
 	CreateUser(*User) error
 	GetUserByUserName(username string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
 	UpdateUser(*User) error
+	UpdatePassword(user *User, keepSession int64) error
+	VerifyEmail(*User) error
 	GetUserToken(scope, tokenPlainText string) (*User, error)
+	GetUserSession(tokenPlainText string) (*User, *Session, error)
+	GetUserAPIKey(tokenPlainText string) (*User, *APIKey, error)
+	UpdateTrainingSettings(*User) error
+	ScheduleDeletion(user *User, at time.Time) error
+	CancelDeletion(userID int) error
+	ListDueDeletions() ([]int, error)
+	PurgeUser(userID int) (anonymized bool, err error)
+	TimezoneSupported(name string) (bool, error)
+}
+
+// TimezoneSupported reports whether Postgres knows the named time zone, so
+// one only Go accepts is rejected before it breaks the user's stats.
+func (s *PostgresUserStore) TimezoneSupported(name string) (bool, error) {
+	return timezoneSupported(s.db, name)
 }
 
 func (s *PostgresUserStore) CreateUser(user *User) error {
 	query := `
-	INSERT INTO users (username, email, password_hash, bio)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id, created_at, updated_at
+	INSERT INTO users (username, email, password_hash, bio, timezone)
+	VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'UTC'))
+	RETURNING id, timezone, weekly_workout_target, profile_visibility, created_at, updated_at
 	`
 
-	err := s.db.QueryRow(query, user.UserName, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRow(query, user.UserName, user.Email, user.PasswordHash.hash, user.Bio, user.Timezone).Scan(&user.ID, &user.Timezone, &user.WeeklyTarget, &user.ProfileVisibility, &user.CreatedAt, &user.UpdatedAt)
+	user.RestDays = []string{}
 
 	if err != nil {
-		return err
+		return userConflict(err)
 	}
 	return nil
 }
 
+// userConflict maps violations of the unique constraints on users to
+// ErrUsernameTaken and ErrEmailTaken.
+func userConflict(err error) error {
+	pgErr, ok := isUniqueViolation(err)
+	if !ok {
+		return err
+	}
+
+	switch pgErr.ConstraintName {
+	case "users_username_key":
+		return ErrUsernameTaken
+	case "users_email_key":
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// UpdateUser saves the profile: username, email, bio and visibility. A new
+// email address has to be verified again, so the activation tokens sent to
+// the old one are revoked in the same transaction.
 func (s *PostgresUserStore) UpdateUser(user *User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		UPDATE users
-		SET username = $1, email = $2, bio = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $4
-		RETURNING updated_at
+		UPDATE users u
+		SET username = $1, email = $2, bio = $3, profile_visibility = $4, updated_at = CURRENT_TIMESTAMP,
+			email_verified_at = CASE WHEN LOWER(u.email) = LOWER($2) THEN u.email_verified_at END
+		FROM users previous
+		WHERE u.id = $5 AND previous.id = u.id
+		RETURNING u.updated_at, u.email_verified_at, LOWER(previous.email) <> LOWER(u.email)
 	`
 
-	result, err := s.db.Exec(query, user.UserName, user.Email, user.Bio, user.ID)
+	var emailChanged bool
+	err = tx.QueryRow(query, user.UserName, user.Email, user.Bio, user.ProfileVisibility, user.ID).Scan(&user.UpdatedAt, &user.EmailVerifiedAt, &emailChanged)
 	if err != nil {
-		return err
+		return userConflict(err)
 	}
 
-	rowsEffected, err := result.RowsAffected()
+	if emailChanged {
+		_, err = tx.Exec(`DELETE FROM tokens WHERE user_id = $1 AND scope = $2`, user.ID, tokens.ScopeActivation)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresUserStore) UpdateTrainingSettings(user *User) error {
+	query := `
+		UPDATE users
+		SET timezone = $1, rest_days = $2, weekly_workout_target = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRow(query, user.Timezone, nonNilStrings(user.RestDays), user.WeeklyTarget, user.ID).Scan(&user.UpdatedAt)
 	if err != nil {
 		return err
 	}
-	if rowsEffected == 0 {
-		return sql.ErrNoRows
-	}
 	return nil
 }
 
-func (s *PostgresUserStore) GetUserByUserName(username string) (*User, error) {
+// userColumns is the column list read by scanUser, for a users table
+// aliased as u.
+const userColumns = `
+	u.id, u.username, u.email, u.password_hash, COALESCE(u.bio, ''), u.timezone,
+	u.rest_days, u.weekly_workout_target, u.profile_visibility, u.created_at, u.updated_at, u.deletion_scheduled_for,
+	u.email_verified_at
+`
+
+func scanUser(row rowScanner) (*User, error) {
 	user := &User{
 		PasswordHash: password{},
 	}
+	var restDays pgtype.TextArray
 
-	query := `
-		SELECT id, username, email, password_hash, bio, created_at, updated_at
-		FROM users
-		WHERE username = $1
-	`
-
-	err := s.db.QueryRow(query, username).Scan(
+	err := row.Scan(
 		&user.ID,
 		&user.UserName,
 		&user.Email,
 		&user.PasswordHash.hash,
 		&user.Bio,
+		&user.Timezone,
+		&restDays,
+		&user.WeeklyTarget,
+		&user.ProfileVisibility,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.DeletionScheduledFor,
+		&user.EmailVerifiedAt,
 	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("no rows found")
+	if err != nil {
+		return nil, err
+	}
+
+	user.RestDays, err = textArrayToStrings(restDays)
+	if err != nil {
+		return nil, err
 	}
 	return user, nil
 }
 
+func (s *PostgresUserStore) GetUserByUserName(username string) (*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users u
+		WHERE u.username = $1
+	`
+
+	user, err := scanUser(s.db.QueryRow(query, username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users u
+		WHERE LOWER(u.email) = LOWER($1)
+	`
+
+	user, err := scanUser(s.db.QueryRow(query, email))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// UpdatePassword saves the password last set with user.PasswordHash.Set
+// and, in the same transaction, revokes the user's password reset tokens
+// and every session but keepSession, which is zero to revoke them all.
+func (s *PostgresUserStore) UpdatePassword(user *User, keepSession int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING updated_at
+	`
+
+	err = tx.QueryRow(query, user.PasswordHash.hash, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM tokens
+		WHERE user_id = $1 AND (scope = $2 OR (scope IN ($3, $4) AND family_id <> $5))
+	`, user.ID, tokens.ScopePasswordReset, tokens.ScopeAuth, tokens.ScopeRefresh, keepSession)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// VerifyEmail marks the user's current email address as verified.
+func (s *PostgresUserStore) VerifyEmail(user *User) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING email_verified_at, updated_at
+	`
+
+	return s.db.QueryRow(query, user.ID).Scan(&user.EmailVerifiedAt, &user.UpdatedAt)
+}
+
 func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(plaintextPassword))
 
 	query := `
-  SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at
+  SELECT ` + userColumns + `
   FROM users u
   INNER JOIN tokens t ON t.user_id = u.id
   WHERE t.hash = $1 AND t.scope = $2 and t.expiry > $3
   `
 
-	user := &User{
-		PasswordHash: password{},
-	}
-
-	err := s.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(
-		&user.ID,
-		&user.UserName,
-		&user.Email,
-		&user.PasswordHash.hash,
-		&user.Bio,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(s.db.QueryRow(query, tokenHash[:], scope, time.Now()))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -171,3 +334,44 @@ func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User
 
 	return user, nil
 }
+
+// GetUserSession is GetUserToken for authentication tokens, also returning
+// the session the token belongs to. Both are nil if the token is unknown or
+// expired.
+func (s *PostgresUserStore) GetUserSession(tokenPlainText string) (*User, *Session, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	query := `
+		SELECT ` + userColumns + `,
+			t.family_id, t.id, t.created_at, t.last_used_at, t.expiry, t.user_agent, t.ip_address
+		FROM users u
+		INNER JOIN tokens t ON t.user_id = u.id
+		WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
+	`
+
+	session := &Session{Current: true}
+	row := withColumns{
+		s.db.QueryRow(query, tokenHash[:], tokens.ScopeAuth, time.Now()),
+		[]any{&session.ID, &session.TokenID, &session.CreatedAt, &session.LastUsedAt, &session.Expiry, &session.UserAgent, &session.IPAddress},
+	}
+
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, session, nil
+}
+
+// withColumns lets scanUser read a row that has more columns after the
+// user's, scanning them into extra.
+type withColumns struct {
+	row   rowScanner
+	extra []any
+}
+
+func (w withColumns) Scan(dest ...any) error {
+	return w.row.Scan(append(dest, w.extra...)...)
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ruhan/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	user := createTestUser(t, db, "profile_user")
+	other := createTestUser(t, db, "profile_other")
+	assert.Equal(t, ProfilePrivate, user.ProfileVisibility)
+
+	user.Bio = "Lifting since 2019"
+	user.ProfileVisibility = ProfilePublic
+	require.NoError(t, userStore.UpdateUser(user))
+
+	got, err := userStore.GetUserByUserName("profile_user")
+	require.NoError(t, err)
+	assert.Equal(t, "Lifting since 2019", got.Bio)
+	assert.Equal(t, ProfilePublic, got.ProfileVisibility)
+
+	tests := []struct {
+		name   string
+		update func(u *User)
+		err    error
+	}{
+		{name: "taken username", update: func(u *User) { u.UserName = other.UserName }, err: ErrUsernameTaken},
+		{name: "taken email", update: func(u *User) { u.Email = other.Email }, err: ErrEmailTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := *got
+			tt.update(&changed)
+			assert.ErrorIs(t, userStore.UpdateUser(&changed), tt.err)
+		})
+	}
+
+	missing, err := userStore.GetUserByUserName("no_such_user")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestUpdateUserRevokesActivationTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "activation_user")
+	other := createTestUser(t, db, "activation_other")
+
+	_, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeActivation)
+	require.NoError(t, err)
+	activationTokens := func() int {
+		metadata, err := tokenStore.ListTokens(user.ID)
+		require.NoError(t, err)
+		count := 0
+		for _, token := range metadata {
+			if token.Scope == tokens.ScopeActivation {
+				count++
+			}
+		}
+		return count
+	}
+
+	// neither a failed change of address nor a change of case revokes them
+	changed := *user
+	changed.Email = other.Email
+	assert.ErrorIs(t, userStore.UpdateUser(&changed), ErrEmailTaken)
+	assert.Equal(t, 1, activationTokens())
+
+	changed = *user
+	changed.Email = "Activation_User@example.com"
+	require.NoError(t, userStore.UpdateUser(&changed))
+	assert.Equal(t, 1, activationTokens())
+
+	changed.Email = "new_address@example.com"
+	require.NoError(t, userStore.UpdateUser(&changed))
+	assert.Equal(t, 0, activationTokens())
+}
+
+func TestUpdatePassword(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "password_user")
+
+	current, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	_, err = tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	_, err = tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopePasswordReset)
+	require.NoError(t, err)
+
+	require.NoError(t, user.PasswordHash.Set("new password"))
+	require.NoError(t, userStore.UpdatePassword(user, current.FamilyID))
+
+	// the other session and the reset token went with the old password
+	sessions, err := tokenStore.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.FamilyID, sessions[0].ID)
+	metadata, err := tokenStore.ListTokens(user.ID)
+	require.NoError(t, err)
+	for _, token := range metadata {
+		assert.NotEqual(t, tokens.ScopePasswordReset, token.Scope)
+	}
+
+	got, err := userStore.GetUserByEmail("Password_User@Example.com")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+
+	matches, err := got.PasswordHash.Matches("new password")
+	require.NoError(t, err)
+	assert.True(t, matches)
+
+	matches, err = got.PasswordHash.Matches("password")
+	require.NoError(t, err)
+	assert.False(t, matches)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgreUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "verify_user")
+	assert.False(t, user.IsVerified())
+
+	token, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeActivation)
+	require.NoError(t, err)
+
+	got, err := userStore.GetUserToken(tokens.ScopeActivation, token.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.NoError(t, userStore.VerifyEmail(got))
+	assert.True(t, got.IsVerified())
+
+	tests := []struct {
+		name     string
+		email    string
+		verified bool
+	}{
+		{name: "same email in other case", email: "Verify_User@Example.com", verified: true},
+		{name: "new email", email: "verify_user@example.org", verified: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got.Email = tt.email
+			require.NoError(t, userStore.UpdateUser(got))
+			assert.Equal(t, tt.verified, got.IsVerified())
+
+			reloaded, err := userStore.GetUserByEmail(tt.email)
+			require.NoError(t, err)
+			assert.Equal(t, tt.verified, reloaded.IsVerified())
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/jackc/pgtype"
+)
+
+// WorkoutExternalID is the external ID a workout that was logged in the app
+// is exported under. Importing it back into the same account matches the
+// workout itself, so a user's own export never duplicates their history.
+func WorkoutExternalID(workoutID int) string {
+	return "workout:" + strconv.Itoa(workoutID)
+}
+
+// exportQuery reads one row per entry, or a single row without an entry
+// (entry id 0) for a workout that has none. Groups and set logs ride along
+// as JSON so the whole export is one pass over the result.
+const exportQuery = `
+	SELECT w.id, w.user_id, w.title, COALESCE(w.description, ''), w.duration_minutes,
+		COALESCE(w.calories_burned, 0), w.created_at, COALESCE(w.external_id, ''),
+		(
+			SELECT json_agg(json_build_object(
+				'id', g.id, 'group_index', g.group_index, 'type', g.group_type, 'rounds', g.rounds,
+				'rest_seconds', g.rest_seconds, 'round_rest_seconds', g.round_rest_seconds,
+				'interval_seconds', g.interval_seconds, 'time_cap_seconds', g.time_cap_seconds,
+				'notes', COALESCE(g.notes, '')
+			) ORDER BY g.group_index)
+			FROM workout_entry_groups g
+			WHERE g.workout_id = w.id
+		),
+		COALESCE(e.id, 0), e.exercise_id, COALESCE(e.exercise_name, ''), COALESCE(e.sets, 0), e.reps,
+		e.duration_seconds, e.weight, COALESCE(e.notes, ''), COALESCE(e.order_index, 0), e.group_index,
+		COALESCE(e.kind, ''), e.distance_meters, COALESCE(e.distance_unit, ''), e.elevation_gain_meters,
+		e.avg_heart_rate, e.max_heart_rate, e.avg_cadence,
+		(
+			SELECT json_agg(json_build_object(
+				'id', s.id, 'set_index', s.set_index, 'set_type', s.set_type, 'reps', s.reps,
+				'weight', s.weight, 'duration_seconds', s.duration_seconds, 'rpe', s.rpe,
+				'rir', s.rir, 'completed', s.completed
+			) ORDER BY s.set_index)
+			FROM workout_sets s
+			WHERE s.entry_id = e.id
+		),
+		ARRAY(
+			SELECT pr.record_type
+			FROM personal_records pr
+			WHERE pr.workout_entry_id = e.id
+			GROUP BY pr.record_type
+			ORDER BY MIN(pr.id)
+		)
+	FROM workouts w
+	LEFT JOIN workout_entries e ON e.workout_id = w.id
+	WHERE w.user_id = $1
+	ORDER BY w.created_at, w.id, e.order_index
+`
+
+// surroundedScanner scans the columns before and after the ones the
+// wrapped destinations take.
+type surroundedScanner struct {
+	row    rowScanner
+	before []any
+	after  []any
+}
+
+func (s surroundedScanner) Scan(dest ...any) error {
+	all := append(append(append([]any{}, s.before...), dest...), s.after...)
+	return s.row.Scan(all...)
+}
+
+// ExportWorkouts calls fn with each of the user's workouts, oldest first,
+// with their groups, entries and sets. Workouts are streamed row by row, so
+// only the one being assembled is held in memory; fn's error stops the
+// export and is returned.
+func (pg *PostgresWorkoutStore) ExportWorkouts(userID int, fn func(*Workout) error) error {
+	rows, err := pg.db.Query(exportQuery, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var workout *Workout
+	for rows.Next() {
+		row := &Workout{}
+		var groups, sets []byte
+		var records pgtype.TextArray
+		var entry WorkoutEntry
+
+		err = scanWorkoutEntry(surroundedScanner{
+			row: rows,
+			before: []any{
+				&row.ID, &row.UserID, &row.Title, &row.Description, &row.DurationMinutes,
+				&row.CaloriesBurned, &row.CreatedAt, &row.ExternalID, &groups,
+			},
+			after: []any{&sets, &records},
+		}, &entry)
+		if err != nil {
+			return err
+		}
+
+		if workout == nil || workout.ID != row.ID {
+			if workout != nil {
+				err = fn(workout)
+				if err != nil {
+					return err
+				}
+			}
+
+			workout = row
+			workout.Entries = []WorkoutEntry{}
+			if groups != nil {
+				err = json.Unmarshal(groups, &workout.Groups)
+				if err != nil {
+					return err
+				}
+			}
+			workout.Groups = nonNilGroups(workout.Groups)
+		}
+
+		if entry.ID == 0 {
+			continue
+		}
+		if sets != nil {
+			err = json.Unmarshal(sets, &entry.SetDetails)
+			if err != nil {
+				return err
+			}
+		}
+		if entry.PersonalRecords, err = textArrayToStrings(records); err != nil {
+			return err
+		}
+		if len(entry.PersonalRecords) == 0 {
+			entry.PersonalRecords = nil
+		}
+		workout.Entries = append(workout.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if workout == nil {
+		return nil
+	}
+	return fn(workout)
+}
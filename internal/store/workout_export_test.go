@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "export_user")
+
+	workouts := []*Workout{}
+	for day := 3; day >= 1; day-- {
+		workouts = append(workouts, &Workout{
+			Title: fmt.Sprintf("Day %d", day), DurationMinutes: 30, ExternalID: fmt.Sprintf("test:%d", day),
+			CreatedAt: time.Date(2026, 2, day, 18, 0, 0, 0, time.UTC),
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Kind: EntryKindStrength, OrderIndex: 1, Sets: 2, Reps: IntPtr(5), Weight: FloatPtr(100)},
+			},
+		})
+	}
+	_, _, err := store.ImportWorkouts(user.ID, workouts)
+	require.NoError(t, err)
+
+	logged, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "Logged", DurationMinutes: 40,
+		Groups: []WorkoutEntryGroup{{GroupIndex: 1, Type: GroupSuperset}},
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Kind: EntryKindStrength, OrderIndex: 1, GroupIndex: IntPtr(1), Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(60)},
+			{ExerciseName: "Barbell Row", Kind: EntryKindStrength, OrderIndex: 2, GroupIndex: IntPtr(1), Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(50)},
+		},
+	})
+	require.NoError(t, err)
+	empty, err := store.CreateWorkout(&Workout{UserID: user.ID, Title: "Rest", DurationMinutes: 10, Entries: []WorkoutEntry{}})
+	require.NoError(t, err)
+
+	titles := []string{}
+	exported := []*Workout{}
+	err = store.ExportWorkouts(user.ID, func(workout *Workout) error {
+		titles = append(titles, workout.Title)
+		exported = append(exported, workout)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Day 1", "Day 2", "Day 3", "Logged", "Rest"}, titles)
+
+	assert.Len(t, exported[0].Entries[0].SetDetails, 2)
+	assert.Equal(t, "test:1", exported[0].ExternalID)
+
+	require.Len(t, exported[3].Entries, 2)
+	assert.Equal(t, logged.ID, exported[3].ID)
+	assert.Equal(t, []string{"Bench Press", "Barbell Row"}, []string{exported[3].Entries[0].ExerciseName, exported[3].Entries[1].ExerciseName})
+	assert.Len(t, exported[3].Entries[1].SetDetails, 3)
+	require.Len(t, exported[3].Groups, 1)
+	assert.Equal(t, GroupSuperset, exported[3].Groups[0].Type)
+
+	assert.Equal(t, empty.ID, exported[4].ID)
+	assert.Empty(t, exported[4].Entries)
+	assert.Empty(t, exported[4].Groups)
+
+	// importing the export back into the same account finds every workout,
+	// including the ones that were logged in the app
+	for _, workout := range exported {
+		if workout.ExternalID == "" {
+			workout.ExternalID = WorkoutExternalID(workout.ID)
+		}
+		workout.ID = 0
+	}
+	imported, duplicates, err := store.ImportWorkouts(user.ID, exported)
+	require.NoError(t, err)
+	assert.Empty(t, imported)
+	assert.Equal(t, 5, duplicates)
+
+	stop := errors.New("stop")
+	err = store.ExportWorkouts(user.ID, func(*Workout) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrImportConflict = errors.New("these workouts are being imported by another request")
+
+// FindImportedWorkouts returns the IDs of the user's workouts that were
+// imported under the given external IDs, or that were exported under them
+// (see WorkoutExternalID), keyed by external ID.
+func (pg *PostgresWorkoutStore) FindImportedWorkouts(userID int, externalIDs []string) (map[string]int, error) {
+	return findImportedWorkouts(pg.db, userID, externalIDs)
+}
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func findImportedWorkouts(db querier, userID int, externalIDs []string) (map[string]int, error) {
+	rows, err := db.Query(`
+		SELECT k.external_id, w.id
+		FROM unnest($2::TEXT[]) AS k (external_id)
+		INNER JOIN workouts w ON w.user_id = $1 AND (
+			w.external_id = k.external_id
+			OR w.id = CASE WHEN k.external_id ~ '^workout:[0-9]{1,18}$' THEN substr(k.external_id, 9)::BIGINT END
+		)
+	`, userID, externalIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]int{}
+	for rows.Next() {
+		var externalID string
+		var id int
+		err = rows.Scan(&externalID, &id)
+		if err != nil {
+			return nil, err
+		}
+		found[externalID] = id
+	}
+	return found, rows.Err()
+}
+
+// ImportWorkouts creates the user's imported workouts in one transaction,
+// oldest first and each dated by its CreatedAt. Workouts whose external ID was imported
+// before are skipped and counted as duplicates, so importing the same file
+// twice is harmless.
+func (pg *PostgresWorkoutStore) ImportWorkouts(userID int, workouts []*Workout) ([]*Workout, int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	externalIDs := []string{}
+	for _, workout := range workouts {
+		externalIDs = append(externalIDs, workout.ExternalID)
+	}
+	existing, err := findImportedWorkouts(tx, userID, externalIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// oldest first, so each workout's records are measured against the
+	// history before it whatever order the file lists them in
+	workouts = slices.Clone(workouts)
+	slices.SortStableFunc(workouts, func(a, b *Workout) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ExternalID, b.ExternalID)
+	})
+
+	imported := []*Workout{}
+	duplicates := 0
+	for _, workout := range workouts {
+		if _, ok := existing[workout.ExternalID]; ok {
+			duplicates++
+			continue
+		}
+
+		workout.UserID = userID
+		createdAt := workout.CreatedAt
+		err = pg.createWorkout(tx, workout, &createdAt)
+		if pgErr, ok := isUniqueViolation(err); ok && pgErr.ConstraintName == "idx_workouts_user_external_id" {
+			return nil, 0, ErrImportConflict
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		imported = append(imported, workout)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+	return imported, duplicates, nil
+}
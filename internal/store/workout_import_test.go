@@ -0,0 +1,94 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "csv_user")
+
+	newWorkouts := func() []*Workout {
+		return []*Workout{
+			{
+				Title: "Push", DurationMinutes: 60, ExternalID: "strong:a",
+				CreatedAt: time.Date(2026, 2, 1, 18, 0, 0, 0, time.UTC),
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Bench Press", Kind: EntryKindStrength, OrderIndex: 1, Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(80)},
+				},
+			},
+			{
+				Title: "Pull", DurationMinutes: 45, ExternalID: "strong:b",
+				CreatedAt: time.Date(2026, 2, 3, 18, 0, 0, 0, time.UTC),
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Deadlift", Kind: EntryKindStrength, OrderIndex: 1, Sets: 1, Reps: IntPtr(5), Weight: FloatPtr(140)},
+				},
+			},
+		}
+	}
+
+	imported, duplicates, err := store.ImportWorkouts(user.ID, newWorkouts())
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	assert.Equal(t, 0, duplicates)
+	assert.True(t, imported[0].CreatedAt.Equal(time.Date(2026, 2, 1, 18, 0, 0, 0, time.UTC)))
+
+	got, err := store.GetWorkoutByID(int64(imported[1].ID))
+	require.NoError(t, err)
+	assert.Equal(t, "strong:b", got.ExternalID)
+
+	// importing the same file again creates nothing
+	imported, duplicates, err = store.ImportWorkouts(user.ID, newWorkouts())
+	require.NoError(t, err)
+	assert.Empty(t, imported)
+	assert.Equal(t, 2, duplicates)
+
+	found, err := store.FindImportedWorkouts(user.ID, []string{"strong:a", "strong:c"})
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Contains(t, found, "strong:a")
+}
+
+func TestImportWorkoutsNewestFirst(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "hevy_user")
+
+	// Hevy lists the latest workout first; each squat is heavier than the
+	// one logged before it
+	workouts := []*Workout{}
+	for i, weight := range []float64{100, 90, 80} {
+		workouts = append(workouts, &Workout{
+			Title: fmt.Sprintf("Legs %d", 3-i), DurationMinutes: 45, ExternalID: fmt.Sprintf("hevy:%d", 3-i),
+			CreatedAt: time.Date(2026, 2, 3-i, 18, 0, 0, 0, time.UTC),
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Kind: EntryKindStrength, OrderIndex: 1, Sets: 1, Reps: IntPtr(5), Weight: FloatPtr(weight)},
+			},
+		})
+	}
+
+	imported, _, err := store.ImportWorkouts(user.ID, workouts)
+	require.NoError(t, err)
+	require.Len(t, imported, 3)
+	assert.Equal(t, []string{"Legs 1", "Legs 2", "Legs 3"}, []string{imported[0].Title, imported[1].Title, imported[2].Title})
+
+	records, err := NewPostgresPersonalRecordStore(db).ListRecords(user.ID, nil)
+	require.NoError(t, err)
+	previous := []float64{}
+	for _, record := range records {
+		if record.RecordType == RecordMaxWeight && record.PreviousValue != nil {
+			previous = append(previous, *record.PreviousValue)
+		}
+	}
+	assert.Equal(t, []float64{80, 90}, previous)
+}
@@ -0,0 +1,201 @@
+package store
+
+import "database/sql"
+
+const (
+	SetTypeWarmup  = "warmup"
+	SetTypeWorking = "working"
+	SetTypeDrop    = "drop"
+	SetTypeFailure = "failure"
+)
+
+// WorkoutSet is one logged set of an entry. A set has either reps or a
+// duration, like the entry it belongs to.
+type WorkoutSet struct {
+	ID              int      `json:"id"`
+	SetIndex        int      `json:"set_index"`
+	Type            string   `json:"set_type"`
+	Reps            *int     `json:"reps"`
+	Weight          *float64 `json:"weight"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	RPE             *float64 `json:"rpe"`
+	RIR             *int     `json:"rir"`
+	Completed       *bool    `json:"completed"`
+}
+
+// Counts reports whether the set counts towards volume, records and the
+// entry's aggregate fields: it was completed and was not a warm-up.
+func (s WorkoutSet) Counts() bool {
+	return (s.Completed == nil || *s.Completed) && s.Type != SetTypeWarmup
+}
+
+// syncEntrySets reconciles an entry's set log with its aggregate fields.
+// Entries logged the old way get one working set per set; entries with a
+// set log get sets, reps, duration and weight derived from it, taken from
+// the top set among the sets that count. Cardio entries have no set log.
+func syncEntrySets(entry *WorkoutEntry) {
+	if entry.Kind == "" {
+		entry.Kind = EntryKindStrength
+	}
+
+	if entry.Kind == EntryKindCardio {
+		entry.SetDetails = nil
+		if entry.Sets < 1 {
+			entry.Sets = 1
+		}
+		return
+	}
+
+	if len(entry.SetDetails) == 0 {
+		for i := 0; i < entry.Sets; i++ {
+			completed := true
+			entry.SetDetails = append(entry.SetDetails, WorkoutSet{
+				SetIndex:        i + 1,
+				Type:            SetTypeWorking,
+				Reps:            entry.Reps,
+				Weight:          entry.Weight,
+				DurationSeconds: entry.DurationSeconds,
+				Completed:       &completed,
+			})
+		}
+		return
+	}
+
+	counted := []WorkoutSet{}
+	for i := range entry.SetDetails {
+		set := &entry.SetDetails[i]
+		set.SetIndex = i + 1
+		if set.Type == "" {
+			set.Type = SetTypeWorking
+		}
+		if set.Completed == nil {
+			completed := true
+			set.Completed = &completed
+		}
+		if set.Counts() {
+			counted = append(counted, *set)
+		}
+	}
+
+	entry.Sets = len(counted)
+	if len(counted) == 0 {
+		// keep the entry well-formed when nothing counted
+		counted = entry.SetDetails
+	}
+
+	top := counted[0]
+	for _, set := range counted[1:] {
+		if isHeavierSet(set, top) {
+			top = set
+		}
+	}
+	entry.Reps, entry.Weight, entry.DurationSeconds = top.Reps, top.Weight, top.DurationSeconds
+}
+
+// isHeavierSet orders sets by weight, then reps, then duration.
+func isHeavierSet(a, b WorkoutSet) bool {
+	value := func(p *float64) float64 {
+		if p == nil {
+			return 0
+		}
+		return *p
+	}
+	count := func(p *int) int {
+		if p == nil {
+			return 0
+		}
+		return *p
+	}
+
+	switch {
+	case value(a.Weight) != value(b.Weight):
+		return value(a.Weight) > value(b.Weight)
+	case count(a.Reps) != count(b.Reps):
+		return count(a.Reps) > count(b.Reps)
+	default:
+		return count(a.DurationSeconds) > count(b.DurationSeconds)
+	}
+}
+
+// Sets of workout entries and of template entries live in twin tables
+// keyed by the owning entry.
+const (
+	workoutSetTable  = "workout_sets"
+	templateSetTable = "workout_template_sets"
+)
+
+func insertWorkoutSets(tx *sql.Tx, table string, entry *WorkoutEntry) error {
+	query := `
+		INSERT INTO ` + table + ` (entry_id, set_index, set_type, reps, weight, duration_seconds, rpe, rir, completed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+
+	for i := range entry.SetDetails {
+		set := &entry.SetDetails[i]
+
+		err := tx.QueryRow(
+			query,
+			entry.ID, set.SetIndex, set.Type, set.Reps, set.Weight,
+			set.DurationSeconds, set.RPE, set.RIR, *set.Completed,
+		).Scan(&set.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachWorkoutSets loads the set log of every entry of the given workouts.
+func attachWorkoutSets(db *sql.DB, workouts ...*Workout) error {
+	entries := []*WorkoutEntry{}
+	for _, workout := range workouts {
+		for i := range workout.Entries {
+			entries = append(entries, &workout.Entries[i])
+		}
+	}
+	return attachEntrySets(db, workoutSetTable, entries)
+}
+
+// attachEntrySets reads the sets of the given entries from table in one
+// query and fills in their SetDetails.
+func attachEntrySets(db *sql.DB, table string, entries []*WorkoutEntry) error {
+	ids := []int{}
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT entry_id, id, set_index, set_type, reps, weight,
+			duration_seconds, rpe, rir, completed
+		FROM `+table+`
+		WHERE entry_id = ANY($1)
+		ORDER BY entry_id, set_index
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	setsByEntry := map[int][]WorkoutSet{}
+	for rows.Next() {
+		var entryID int
+		var set WorkoutSet
+		err = rows.Scan(
+			&entryID, &set.ID, &set.SetIndex, &set.Type, &set.Reps, &set.Weight,
+			&set.DurationSeconds, &set.RPE, &set.RIR, &set.Completed,
+		)
+		if err != nil {
+			return err
+		}
+		setsByEntry[entryID] = append(setsByEntry[entryID], set)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entry.SetDetails = setsByEntry[entry.ID]
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutSets(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "workout_sets_user")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "pyramid", DurationMinutes: 40,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", OrderIndex: 1, SetDetails: []WorkoutSet{
+				{Type: SetTypeWarmup, Reps: IntPtr(12), Weight: FloatPtr(40)},
+				{Reps: IntPtr(10), Weight: FloatPtr(60)},
+				{Reps: IntPtr(8), Weight: FloatPtr(70), RPE: FloatPtr(8)},
+				{Reps: IntPtr(6), Weight: FloatPtr(80), RIR: IntPtr(1)},
+			}},
+			{ExerciseName: "plank", Sets: 2, DurationSeconds: IntPtr(60), OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+
+	bench := retrieved.Entries[0]
+	assert.Equal(t, 3, bench.Sets)
+	assert.Equal(t, 6, *bench.Reps)
+	assert.InDelta(t, 80, *bench.Weight, 0.001)
+	require.Len(t, bench.SetDetails, 4)
+	assert.Equal(t, SetTypeWarmup, bench.SetDetails[0].Type)
+	assert.Equal(t, 4, bench.SetDetails[3].SetIndex)
+	assert.InDelta(t, 8, *bench.SetDetails[2].RPE, 0.001)
+	assert.ElementsMatch(t, []string{RecordMaxReps, RecordMaxWeight, RecordEstimated1RM}, bench.PersonalRecords)
+
+	plank := retrieved.Entries[1]
+	require.Len(t, plank.SetDetails, 2)
+	assert.Equal(t, 60, *plank.SetDetails[1].DurationSeconds)
+}
@@ -2,28 +2,50 @@ package store
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Workout struct {
-	ID              int            `json:"id"`
-	UserID          int            `json:"user_id"`
-	Title           string         `json:"title"`
-	Description     string         `json:"descripiton"`
-	DurationMinutes int            `json:"duration_minutes"`
-	CaloriesBurned  int            `json:"calories_burned"`
-	Entries         []WorkoutEntry `json:"entries"`
+	ID              int                 `json:"id"`
+	UserID          int                 `json:"user_id"`
+	Title           string              `json:"title"`
+	Description     string              `json:"descripiton"`
+	DurationMinutes int                 `json:"duration_minutes"`
+	CaloriesBurned  int                 `json:"calories_burned"`
+	CreatedAt       time.Time           `json:"created_at"`
+	ExternalID      string              `json:"external_id,omitempty"`
+	Groups          []WorkoutEntryGroup `json:"groups"`
+	Entries         []WorkoutEntry      `json:"entries"`
 }
 
+// WorkoutEntry is one exercise of a workout. Strength entries have either
+// reps or a duration; cardio entries have a duration and/or a distance in
+// DistanceUnit instead of reps.
 type WorkoutEntry struct {
-	ID              int      `json:"id"`
-	ExerciseName    string   `json:"exercise_name"`
-	Sets            int      `json:"sets"`
-	Reps            *int     `json:"reps"`
-	DurationSeconds *int     `json:"duration_seconds"`
-	Weight          *float64 `json:"weight"`
-	Notes           string   `json:"notes"`
-	OrderIndex      int      `json:"order_index"`
+	ID                  int          `json:"id"`
+	ExerciseID          *int         `json:"exercise_id"`
+	ExerciseName        string       `json:"exercise_name"`
+	Kind                string       `json:"kind"`
+	Sets                int          `json:"sets"`
+	Reps                *int         `json:"reps"`
+	DurationSeconds     *int         `json:"duration_seconds"`
+	Weight              *float64     `json:"weight"`
+	Distance            *float64     `json:"distance,omitempty"`
+	DistanceUnit        string       `json:"distance_unit,omitempty"`
+	ElevationGainMeters *float64     `json:"elevation_gain_meters,omitempty"`
+	AvgHeartRate        *int         `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate        *int         `json:"max_heart_rate,omitempty"`
+	AvgCadence          *int         `json:"avg_cadence,omitempty"`
+	Notes               string       `json:"notes"`
+	OrderIndex          int          `json:"order_index"`
+	GroupIndex          *int         `json:"group_index"`
+	SetDetails          []WorkoutSet `json:"set_details,omitempty"`
+	PersonalRecords     []string     `json:"personal_records,omitempty"`
 }
 
 type WorkoutStore interface {
@@ -32,6 +54,80 @@ type WorkoutStore interface {
 	UpdateWorkout(*Workout) error
 	DeleteWorkout(id int64) error
 	GetWorkoutOwner(id int64) (int, error)
+	ListWorkouts(filter WorkoutFilter) ([]*Workout, string, error)
+	SearchWorkouts(userID int, query string, limit int) ([]*WorkoutSearchResult, error)
+	FindImportedWorkouts(userID int, externalIDs []string) (map[string]int, error)
+	ImportWorkouts(userID int, workouts []*Workout) (imported []*Workout, duplicates int, err error)
+	ExportWorkouts(userID int, fn func(*Workout) error) error
+}
+
+// WorkoutSearchResult is a workout matching a full-text search. Snippet
+// highlights the match in the title/description and Entries lists the
+// entries whose exercise name or notes matched. Snippets are HTML: the
+// user's text is escaped and the matched words are wrapped in <mark>.
+type WorkoutSearchResult struct {
+	WorkoutID int                `json:"workout_id"`
+	Title     string             `json:"title"`
+	CreatedAt time.Time          `json:"created_at"`
+	Rank      float64            `json:"rank"`
+	Snippet   string             `json:"snippet"`
+	Entries   []EntrySearchMatch `json:"entries"`
+}
+
+type EntrySearchMatch struct {
+	EntryID      int     `json:"entry_id"`
+	ExerciseName string  `json:"exercise_name"`
+	Rank         float64 `json:"rank"`
+	Snippet      string  `json:"snippet"`
+}
+
+const (
+	WorkoutSortCreatedAt = "created_at"
+	WorkoutSortDuration  = "duration"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// WorkoutFilter describes a page of a user's workouts, created from From
+// up to but not including To. Cursor is the opaque value returned by the
+// previous page, empty for the first one.
+type WorkoutFilter struct {
+	UserID      int
+	From        *time.Time
+	To          *time.Time
+	MinDuration *int
+	MaxDuration *int
+	MinCalories *int
+	MaxCalories *int
+	SortBy      string
+	Descending  bool
+	Limit       int
+	Cursor      string
+}
+
+type workoutCursor struct {
+	CreatedAt time.Time `json:"c,omitempty"`
+	Duration  int       `json:"d,omitempty"`
+	ID        int       `json:"i"`
+}
+
+func encodeWorkoutCursor(w *Workout) string {
+	js, _ := json.Marshal(workoutCursor{CreatedAt: w.CreatedAt, Duration: w.DurationMinutes, ID: w.ID})
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeWorkoutCursor(cursor string) (*workoutCursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c workoutCursor
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
 }
 
 type PostgresWorkoutStore struct {
@@ -51,54 +147,62 @@ func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error
 
 	defer tx.Rollback()
 
-	query := `
-		INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id;
-	`
+	err = pg.createWorkout(tx, workout, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	err = tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
+	err = tx.Commit()
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Insert into entries
-	for _, entry := range workout.Entries {
-		query := `
-			INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			RETURNING id;
-		`
+	return workout, nil
+}
 
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+// createWorkout inserts the workout with its groups and entries and detects
+// the records it sets, all in the caller's transaction. createdAt backdates
+// the workout and defaults to now.
+func (pg *PostgresWorkoutStore) createWorkout(tx *sql.Tx, workout *Workout, createdAt *time.Time) error {
+	query := `
+		INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned, created_at, external_id)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, CURRENT_TIMESTAMP), $7)
+		RETURNING id, created_at;
+	`
 
-		if err != nil {
-			return nil, err
-		}
+	err := tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, createdAt, nullString(workout.ExternalID)).Scan(&workout.ID, &workout.CreatedAt)
+
+	if err != nil {
+		return err
 	}
 
-	err = tx.Commit()
+	workout.Groups = nonNilGroups(workout.Groups)
+	err = insertEntryGroups(tx, workoutGroupTable, workoutGroupOwner, workout.ID, workout.Groups)
+	if err != nil {
+		return err
+	}
 
+	err = insertWorkoutEntries(tx, workout)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return workout, nil
+	return updatePersonalRecords(tx, workout, nil)
 }
 
 func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 	workout := &Workout{}
 
 	query := `
-		SELECT id, title, description, duration_minutes, calories_burned
+		SELECT id, user_id, title, description, duration_minutes, calories_burned, created_at, COALESCE(external_id, '')
 		FROM workouts
 		WHERE id = $1
 	`
 	err := pg.db.QueryRow(query, id).Scan(
-		&workout.ID, &workout.Title,
+		&workout.ID, &workout.UserID, &workout.Title,
 		&workout.Description, &workout.DurationMinutes,
-		&workout.CaloriesBurned,
+		&workout.CaloriesBurned, &workout.CreatedAt, &workout.ExternalID,
 	)
 
 	if err == sql.ErrNoRows {
@@ -109,11 +213,9 @@ func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 		return nil, err
 	}
 
-	fmt.Println("workout 1", workout)
-
 	// Let's gen entries
 	entryQuery := `
-		SELECT id , exercise_name, sets, reps, duration_seconds, weight, notes, order_index
+		SELECT ` + workoutEntryColumns + `
 		FROM workout_entries
 		WHERE workout_id = $1
 		ORDER BY order_index
@@ -128,18 +230,29 @@ func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 
 	for rows.Next() {
 		var entry WorkoutEntry
-		err = rows.Scan(
-			&entry.ID, &entry.ExerciseName,
-			&entry.Sets, &entry.Reps,
-			&entry.DurationSeconds, &entry.Weight,
-			&entry.Notes, &entry.OrderIndex,
-		)
+		err = scanWorkoutEntry(rows, &entry)
 		if err != nil {
 			return nil, err
 		}
 		workout.Entries = append(workout.Entries, entry)
 	}
 
+	groups, err := loadEntryGroups(pg.db, workoutGroupTable, workoutGroupOwner, []int{workout.ID})
+	if err != nil {
+		return nil, err
+	}
+	workout.Groups = nonNilGroups(groups[workout.ID])
+
+	err = attachWorkoutSets(pg.db, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = attachPersonalRecords(pg.db, workout)
+	if err != nil {
+		return nil, err
+	}
+
 	return workout, nil
 }
 
@@ -154,68 +267,145 @@ func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
 		UPDATE workouts
 		SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4
 		WHERE id = $5
+		RETURNING user_id, created_at
 	`
 
-	result, err := txn.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	err = txn.QueryRow(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID).Scan(&workout.UserID, &workout.CreatedAt)
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	// records of exercises the edit removes are recomputed too
+	previousExerciseIDs, err := workoutExerciseIDs(txn, workout.ID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	_, err = txn.Exec(`DELETE FROM workout_entries WHERE workout_id = $1`, workout.ID)
+	if err != nil {
+		return err
 	}
 
-	_, err = txn.Exec(`DELETE FROM workout_entries WHERE workout_id = $1`, workout.ID)
+	_, err = txn.Exec(`DELETE FROM workout_entry_groups WHERE workout_id = $1`, workout.ID)
+	if err != nil {
+		return err
+	}
+
+	workout.Groups = nonNilGroups(workout.Groups)
+	err = insertEntryGroups(txn, workoutGroupTable, workoutGroupOwner, workout.ID, workout.Groups)
+	if err != nil {
+		return err
+	}
+
+	err = insertWorkoutEntries(txn, workout)
+	if err != nil {
+		return err
+	}
+
+	err = updatePersonalRecords(txn, workout, previousExerciseIDs)
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// workoutEntryColumns is the column list read by scanWorkoutEntry.
+const workoutEntryColumns = `
+	id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, COALESCE(notes, ''), order_index, group_index,
+	kind, distance_meters, COALESCE(distance_unit, ''), elevation_gain_meters, avg_heart_rate, max_heart_rate, avg_cadence
+`
+
+func scanWorkoutEntry(row rowScanner, entry *WorkoutEntry) error {
+	var distanceMeters *float64
+
+	err := row.Scan(
+		&entry.ID, &entry.ExerciseID, &entry.ExerciseName,
+		&entry.Sets, &entry.Reps,
+		&entry.DurationSeconds, &entry.Weight,
+		&entry.Notes, &entry.OrderIndex, &entry.GroupIndex,
+		&entry.Kind, &distanceMeters, &entry.DistanceUnit, &entry.ElevationGainMeters,
+		&entry.AvgHeartRate, &entry.MaxHeartRate, &entry.AvgCadence,
+	)
 	if err != nil {
-		return nil
+		return err
 	}
 
-	for _, entry := range workout.Entries {
-		query := `
-			INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			RETURNING id;
-		`
+	entry.Distance = distanceInUnit(distanceMeters, entry.DistanceUnit)
+	return nil
+}
 
-		_, err := txn.Exec(
+// insertWorkoutEntries writes workout.Entries and their sets in place,
+// filling in each entry's ID and, when the client left it empty, the catalog
+// exercise resolved from its name.
+func insertWorkoutEntries(tx *sql.Tx, workout *Workout) error {
+	query := `
+		INSERT INTO workout_entries (
+			workout_id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index, group_index,
+			kind, distance_meters, distance_unit, elevation_gain_meters, avg_heart_rate, max_heart_rate, avg_cadence
+		)
+		VALUES ($1, COALESCE(visible_exercise_id($2, $18), resolve_exercise_id($3, $18)), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id, exercise_id;
+	`
+
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+		syncEntrySets(entry)
+
+		err := tx.QueryRow(
 			query,
-			workout.ID, entry.ExerciseName, entry.Sets,
+			workout.ID, entry.ExerciseID, entry.ExerciseName, entry.Sets,
 			entry.Reps, entry.DurationSeconds, entry.Weight,
-			entry.Notes, entry.OrderIndex,
-		)
+			entry.Notes, entry.OrderIndex, entry.GroupIndex,
+			entry.Kind, entry.DistanceMeters(), nullString(entry.DistanceUnit), entry.ElevationGainMeters,
+			entry.AvgHeartRate, entry.MaxHeartRate, entry.AvgCadence, workout.UserID,
+		).Scan(&entry.ID, &entry.ExerciseID)
 
 		if err != nil {
 			return err
 		}
-	}
 
-	txn.Commit()
+		err = insertWorkoutSets(tx, workoutSetTable, entry)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// DeleteWorkout deletes the workout and recomputes the records of its
+// exercises for the workouts logged after it.
 func (pg *PostgresWorkoutStore) DeleteWorkout(id int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exerciseIDs, err := workoutExerciseIDs(tx, int(id))
+	if err != nil {
+		return err
+	}
+
 	query := `
 		DELETE FROM workouts
 		WHERE id = $1
+		RETURNING user_id, created_at
 	`
 
-	result, err := pg.db.Exec(query, id)
+	var userID int
+	var createdAt time.Time
+	err = tx.QueryRow(query, id).Scan(&userID, &createdAt)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	_, err = recomputePersonalRecords(tx, userID, exerciseIDs, createdAt, int(id))
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-	return nil
+
+	return tx.Commit()
 }
 
 func (pg *PostgresWorkoutStore) GetWorkoutOwner(workoutID int64) (int, error) {
@@ -226,9 +416,250 @@ func (pg *PostgresWorkoutStore) GetWorkoutOwner(workoutID int64) (int, error) {
 		FROM workouts
 		WHERE id = $1
 	`
-	err := pg.db.QueryRow(query, workoutID).Scan(userID)
+	err := pg.db.QueryRow(query, workoutID).Scan(&userID)
 	if err != nil {
 		return 0, err
 	}
 	return userID, nil
 }
+
+func (pg *PostgresWorkoutStore) ListWorkouts(filter WorkoutFilter) ([]*Workout, string, error) {
+	conditions := []string{"user_id = $1"}
+	args := []any{filter.UserID}
+
+	addCondition := func(format string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+	if filter.MinDuration != nil {
+		addCondition("duration_minutes >= $%d", *filter.MinDuration)
+	}
+	if filter.MaxDuration != nil {
+		addCondition("duration_minutes <= $%d", *filter.MaxDuration)
+	}
+	if filter.MinCalories != nil {
+		addCondition("calories_burned >= $%d", *filter.MinCalories)
+	}
+	if filter.MaxCalories != nil {
+		addCondition("calories_burned <= $%d", *filter.MaxCalories)
+	}
+
+	sortColumn := "created_at"
+	if filter.SortBy == WorkoutSortDuration {
+		sortColumn = "duration_minutes"
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeWorkoutCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		var sortValue any = cursor.CreatedAt
+		if sortColumn == "duration_minutes" {
+			sortValue = cursor.Duration
+		}
+		args = append(args, sortValue, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d, $%d)", sortColumn, comparison, len(args)-1, len(args)))
+	}
+
+	// fetch one extra row to know whether there is a next page
+	args = append(args, filter.Limit+1)
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, title, description, duration_minutes, calories_burned, created_at, COALESCE(external_id, '')
+		FROM workouts
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), sortColumn, direction, direction, len(args))
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	workoutsByID := map[int]*Workout{}
+	ids := []int{}
+
+	for rows.Next() {
+		workout := &Workout{Entries: []WorkoutEntry{}}
+		var description sql.NullString
+		var calories sql.NullInt64
+		err = rows.Scan(
+			&workout.ID, &workout.UserID, &workout.Title,
+			&description, &workout.DurationMinutes,
+			&calories, &workout.CreatedAt, &workout.ExternalID,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		workout.Description = description.String
+		workout.CaloriesBurned = int(calories.Int64)
+
+		workouts = append(workouts, workout)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(workouts) > filter.Limit {
+		workouts = workouts[:filter.Limit]
+		nextCursor = encodeWorkoutCursor(workouts[len(workouts)-1])
+	}
+
+	if len(workouts) == 0 {
+		return workouts, nextCursor, nil
+	}
+
+	for _, workout := range workouts {
+		workoutsByID[workout.ID] = workout
+		ids = append(ids, workout.ID)
+	}
+
+	entries, err := pg.getEntriesForWorkouts(ids)
+	if err != nil {
+		return nil, "", err
+	}
+	for workoutID, workoutEntries := range entries {
+		workoutsByID[workoutID].Entries = workoutEntries
+	}
+
+	groups, err := loadEntryGroups(pg.db, workoutGroupTable, workoutGroupOwner, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, workout := range workouts {
+		workout.Groups = nonNilGroups(groups[workout.ID])
+	}
+
+	err = attachWorkoutSets(pg.db, workouts...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = attachPersonalRecords(pg.db, workouts...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return workouts, nextCursor, nil
+}
+
+// getEntriesForWorkouts loads the entries of several workouts in one query,
+// keyed by workout id.
+func (pg *PostgresWorkoutStore) getEntriesForWorkouts(ids []int) (map[int][]WorkoutEntry, error) {
+	entryQuery := `
+		SELECT workout_id, ` + workoutEntryColumns + `
+		FROM workout_entries
+		WHERE workout_id = ANY($1)
+		ORDER BY workout_id, order_index
+	`
+
+	rows, err := pg.db.Query(entryQuery, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := map[int][]WorkoutEntry{}
+	for rows.Next() {
+		var workoutID int
+		var entry WorkoutEntry
+		err = scanWorkoutEntry(prefixedScanner{rows, &workoutID}, &entry)
+		if err != nil {
+			return nil, err
+		}
+		entries[workoutID] = append(entries[workoutID], entry)
+	}
+	return entries, rows.Err()
+}
+
+// prefixedScanner scans a leading column into prefix before handing the
+// remaining columns to the wrapped destinations.
+type prefixedScanner struct {
+	row    rowScanner
+	prefix any
+}
+
+func (p prefixedScanner) Scan(dest ...any) error {
+	return p.row.Scan(append([]any{p.prefix}, dest...)...)
+}
+
+func (pg *PostgresWorkoutStore) SearchWorkouts(userID int, search string, limit int) ([]*WorkoutSearchResult, error) {
+	query := `
+		WITH q AS (
+			SELECT websearch_to_tsquery('english', $2) AS query
+		),
+		entry_hits AS (
+			SELECT e.workout_id, e.id, e.exercise_name,
+				ts_rank(e.search_vector, q.query) AS rank,
+				ts_headline('english', html_escape(e.exercise_name || ' ' || COALESCE(e.notes, '')), q.query,
+					'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet
+			FROM workout_entries e
+			INNER JOIN workouts w ON w.id = e.workout_id
+			CROSS JOIN q
+			WHERE w.user_id = $1 AND e.search_vector @@ q.query
+		)
+		SELECT w.id, w.title, w.created_at,
+			GREATEST(
+				CASE WHEN w.search_vector @@ q.query THEN ts_rank(w.search_vector, q.query) ELSE 0 END,
+				COALESCE(MAX(h.rank), 0)
+			) AS rank,
+			ts_headline('english', html_escape(w.title || ' ' || COALESCE(w.description, '')), q.query,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet,
+			COALESCE(
+				json_agg(
+					json_build_object('entry_id', h.id, 'exercise_name', h.exercise_name, 'rank', h.rank, 'snippet', h.snippet)
+					ORDER BY h.rank DESC
+				) FILTER (WHERE h.id IS NOT NULL),
+				'[]'
+			) AS entries
+		FROM workouts w
+		CROSS JOIN q
+		LEFT JOIN entry_hits h ON h.workout_id = w.id
+		WHERE w.user_id = $1 AND (w.search_vector @@ q.query OR h.id IS NOT NULL)
+		GROUP BY w.id, q.query
+		ORDER BY rank DESC, w.created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := pg.db.Query(query, userID, search, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*WorkoutSearchResult{}
+	for rows.Next() {
+		result := &WorkoutSearchResult{}
+		var entries []byte
+		err = rows.Scan(&result.WorkoutID, &result.Title, &result.CreatedAt, &result.Rank, &result.Snippet, &entries)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(entries, &result.Entries)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
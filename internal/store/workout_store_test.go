@@ -1,34 +1,12 @@
 package store
 
 import (
-	"database/sql"
 	"testing"
 
-	_ "github.com/jackc/pgx/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
-	if err != nil {
-		t.Fatalf("opening test db: %v", err)
-	}
-
-	err = Migrate(db, "../../migrations")
-	if err != nil {
-		t.Fatalf("migration test db: %v", err)
-	}
-
-	_, err = db.Exec("TRUNCATE workouts, workout_entries CASCADE")
-
-	if err != nil {
-		t.Fatalf("truncate test db: %v", err)
-	}
-
-	return db
-}
-
 func TestCreateWorkout(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -114,10 +92,124 @@ func TestCreateWorkout(t *testing.T) {
 
 }
 
-func IntPtr(i int) *int {
-	return &i
+func TestListWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "list_workouts_user")
+
+	for i, duration := range []int{30, 45, 60, 75, 90} {
+		_, err := store.CreateWorkout(&Workout{
+			UserID:          user.ID,
+			Title:           "workout",
+			DurationMinutes: duration,
+			CaloriesBurned:  100 * (i + 1),
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), OrderIndex: 1},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name      string
+		filter    WorkoutFilter
+		wantPages [][]int
+	}{
+		{
+			name:      "duration ascending in pages of two",
+			filter:    WorkoutFilter{UserID: user.ID, SortBy: WorkoutSortDuration, Limit: 2},
+			wantPages: [][]int{{30, 45}, {60, 75}, {90}},
+		},
+		{
+			name:      "duration descending",
+			filter:    WorkoutFilter{UserID: user.ID, SortBy: WorkoutSortDuration, Descending: true, Limit: 3},
+			wantPages: [][]int{{90, 75, 60}, {45, 30}},
+		},
+		{
+			name:      "filtered by duration and calories",
+			filter:    WorkoutFilter{UserID: user.ID, SortBy: WorkoutSortDuration, MinDuration: IntPtr(45), MaxCalories: IntPtr(400), Limit: 10},
+			wantPages: [][]int{{45, 60, 75}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			for i, want := range tt.wantPages {
+				workouts, next, err := store.ListWorkouts(filter)
+				require.NoError(t, err)
+
+				durations := []int{}
+				for _, w := range workouts {
+					durations = append(durations, w.DurationMinutes)
+					assert.Len(t, w.Entries, 1)
+				}
+				assert.Equal(t, want, durations)
+
+				if i == len(tt.wantPages)-1 {
+					assert.Empty(t, next)
+				}
+				filter.Cursor = next
+			}
+		})
+	}
+
+	_, _, err := store.ListWorkouts(WorkoutFilter{UserID: user.ID, Limit: 1, Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
 }
 
-func FloatPtr(i float64) *float64 {
-	return &i
+func TestSearchWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "search_user")
+	other := createTestUser(t, db, "search_other_user")
+
+	titled, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "Bulgarian split squat day", Description: `<img src=x onerror="alert(1)"> legs`, DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Goblet Squat", Sets: 3, Reps: IntPtr(10), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	noted, err := store.CreateWorkout(&Workout{
+		UserID: user.ID, Title: "Leg day", DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Lunge", Sets: 3, Reps: IntPtr(10), OrderIndex: 1, Notes: "next time <b>bulgarian</b> splits instead"},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = store.CreateWorkout(&Workout{
+		UserID: other.ID, Title: "Bulgarian split squats", DurationMinutes: 30,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bulgarian Split Squat", Sets: 3, Reps: IntPtr(8), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	results, err := store.SearchWorkouts(user.ID, "bulgarian", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	// a title match outranks a match in an entry's notes
+	assert.Equal(t, titled.ID, results[0].WorkoutID)
+	assert.Equal(t, noted.ID, results[1].WorkoutID)
+	assert.Greater(t, results[0].Rank, results[1].Rank)
+
+	assert.Contains(t, results[0].Snippet, "<mark>Bulgarian</mark>")
+	assert.NotContains(t, results[0].Snippet, "<img")
+	assert.Empty(t, results[0].Entries)
+
+	require.Len(t, results[1].Entries, 1)
+	assert.Equal(t, "Lunge", results[1].Entries[0].ExerciseName)
+	assert.Contains(t, results[1].Entries[0].Snippet, "&lt;b&gt;<mark>bulgarian</mark>&lt;/b&gt;")
+
+	results, err = store.SearchWorkouts(user.ID, "deadlift", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
 }
@@ -8,15 +8,42 @@ import (
 )
 
 const (
-	ScopeAuth = "authentication"
+	ScopeAuth          = "authentication"
+	ScopeCalendarFeed  = "calendar_feed"
+	ScopePasswordReset = "password-reset"
+	ScopeActivation    = "activation"
+	ScopeRefresh       = "refresh"
+	ScopeAPIKey        = "api_key"
 )
 
+// APIKeyPrefix starts every API key, so they can be told apart from session
+// tokens, including by secret scanners.
+const APIKeyPrefix = "wkt_"
+
+// The permissions an API key can be granted.
+const (
+	PermissionWorkoutsRead  = "workouts:read"
+	PermissionWorkoutsWrite = "workouts:write"
+	PermissionProfileRead   = "profile:read"
+	PermissionProfileWrite  = "profile:write"
+)
+
+var Permissions = []string{PermissionWorkoutsRead, PermissionWorkoutsWrite, PermissionProfileRead, PermissionProfileWrite}
+
 type Token struct {
+	ID        int64     `json:"-"`
 	PlainText string    `json:"token"`
 	Hash      []byte    `json:"-"`
 	UserID    int       `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	// FamilyID groups the access and refresh tokens of one login. Zero
+	// starts a new family.
+	FamilyID int64 `json:"-"`
+	// UserAgent and IPAddress describe the client an authentication token
+	// was issued to.
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
 func GenerateToken(userId int, ttl time.Duration, scope string) (*Token, error) {
@@ -37,3 +64,17 @@ func GenerateToken(userId int, ttl time.Duration, scope string) (*Token, error)
 	token.Hash = hash[:]
 	return token, nil
 }
+
+// GenerateAPIKey is GenerateToken for API keys, whose plaintext carries
+// APIKeyPrefix.
+func GenerateAPIKey(userId int, ttl time.Duration) (*Token, error) {
+	token, err := GenerateToken(userId, ttl, ScopeAPIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	token.PlainText = APIKeyPrefix + token.PlainText
+	hash := sha256.Sum256([]byte(token.PlainText))
+	token.Hash = hash[:]
+	return token, nil
+}
@@ -0,0 +1,79 @@
+package track
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type gpxFile struct {
+	XMLName  xml.Name `xml:"gpx"`
+	Metadata struct {
+		Name string `xml:"name"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// gpxPoint reads heart rate and cadence from the Garmin TrackPointExtension,
+// which most devices and apps write regardless of vendor.
+type gpxPoint struct {
+	Lat       float64   `xml:"lat,attr"`
+	Lon       float64   `xml:"lon,attr"`
+	Elevation *float64  `xml:"ele"`
+	Time      time.Time `xml:"time"`
+	HeartRate *int      `xml:"extensions>TrackPointExtension>hr"`
+	Cadence   *int      `xml:"extensions>TrackPointExtension>cad"`
+}
+
+// ParseGPX reads a GPX 1.1 (or 1.0) file. Every trk and trkseg becomes a
+// segment; routes and waypoints are ignored.
+func ParseGPX(r io.Reader) (*Track, error) {
+	var file gpxFile
+	err := xml.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GPX file: %w", err)
+	}
+
+	track := &Track{Name: file.Metadata.Name}
+	for _, trk := range file.Tracks {
+		if track.Name == "" {
+			track.Name = trk.Name
+		}
+		if track.Sport == "" {
+			track.Sport = normalizeSport(trk.Type)
+		}
+
+		for _, trkseg := range trk.Segments {
+			segment := []Point{}
+			for _, p := range trkseg.Points {
+				if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+					return nil, fmt.Errorf("invalid GPX file: coordinates %v,%v out of range", p.Lat, p.Lon)
+				}
+				segment = append(segment, Point{
+					Lat:         p.Lat,
+					Lon:         p.Lon,
+					HasPosition: true,
+					Elevation:   p.Elevation,
+					Time:        p.Time,
+					HeartRate:   p.HeartRate,
+					Cadence:     p.Cadence,
+				})
+			}
+			if len(segment) > 0 {
+				track.Segments = append(track.Segments, segment)
+			}
+		}
+	}
+
+	if track.pointCount() == 0 {
+		return nil, ErrNoPoints
+	}
+	return track, nil
+}
@@ -0,0 +1,98 @@
+package track
+
+import (
+	"math"
+	"strings"
+)
+
+// Simplify reduces points with the Douglas-Peucker algorithm, dropping every
+// point that lies closer than toleranceMeters to the line kept around it.
+// The first and last point are always kept.
+func Simplify(points []Point, toleranceMeters float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// an explicit stack keeps long recordings from recursing deeply
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDistance := -1, 0.0
+		for i := s.first + 1; i < s.last; i++ {
+			d := crossTrackDistance(points[i], points[s.first], points[s.last])
+			if d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+
+		if farthest >= 0 && maxDistance > toleranceMeters {
+			keep[farthest] = true
+			stack = append(stack, span{s.first, farthest}, span{farthest, s.last})
+		}
+	}
+
+	simplified := []Point{}
+	for i, point := range points {
+		if keep[i] {
+			simplified = append(simplified, point)
+		}
+	}
+	return simplified
+}
+
+// crossTrackDistance is the distance in meters from p to the segment a-b on
+// a local flat projection, which is accurate enough over the few kilometers
+// between track points.
+func crossTrackDistance(p, a, b Point) float64 {
+	scale := math.Cos(radians((a.Lat + b.Lat) / 2))
+	project := func(q Point) (float64, float64) {
+		return radians(q.Lon) * scale * earthRadiusMeters, radians(q.Lat) * earthRadiusMeters
+	}
+
+	px, py := project(p)
+	ax, ay := project(a)
+	bx, by := project(b)
+
+	dx, dy := bx-ax, by-ay
+	if dx == 0 && dy == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+
+	t := ((px-ax)*dx + (py-ay)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+// EncodePolyline encodes points in the Google encoded polyline format with
+// five decimal places, the format map libraries draw directly.
+func EncodePolyline(points []Point) string {
+	var sb strings.Builder
+	var lastLat, lastLon int
+
+	for _, point := range points {
+		lat := int(math.Round(point.Lat * 1e5))
+		lon := int(math.Round(point.Lon * 1e5))
+		encodePolylineValue(&sb, lat-lastLat)
+		encodePolylineValue(&sb, lon-lastLon)
+		lastLat, lastLon = lat, lon
+	}
+	return sb.String()
+}
+
+func encodePolylineValue(sb *strings.Builder, value int) {
+	v := value << 1
+	if value < 0 {
+		v = ^v
+	}
+	for v >= 0x20 {
+		sb.WriteByte(byte((0x20 | (v & 0x1f)) + 63))
+		v >>= 5
+	}
+	sb.WriteByte(byte(v + 63))
+}
@@ -0,0 +1,101 @@
+package track
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type tcxFile struct {
+	XMLName    xml.Name `xml:"TrainingCenterDatabase"`
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			TotalTimeSeconds *float64 `xml:"TotalTimeSeconds"`
+			DistanceMeters   *float64 `xml:"DistanceMeters"`
+			Tracks           []struct {
+				Points []tcxPoint `xml:"Trackpoint"`
+			} `xml:"Track"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// tcxPoint takes cycling cadence from Cadence and running cadence from the
+// ActivityExtension, which reports it per foot.
+type tcxPoint struct {
+	Time       time.Time `xml:"Time"`
+	Lat        *float64  `xml:"Position>LatitudeDegrees"`
+	Lon        *float64  `xml:"Position>LongitudeDegrees"`
+	Altitude   *float64  `xml:"AltitudeMeters"`
+	HeartRate  *int      `xml:"HeartRateBpm>Value"`
+	Cadence    *int      `xml:"Cadence"`
+	RunCadence *int      `xml:"Extensions>TPX>RunCadence"`
+}
+
+// ParseTCX reads the first activity of a TCX file. Every Track of every lap
+// becomes a segment, and the lap totals are used for duration and distance.
+func ParseTCX(r io.Reader) (*Track, error) {
+	var file tcxFile
+	err := xml.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TCX file: %w", err)
+	}
+	if len(file.Activities) == 0 {
+		return nil, ErrNoPoints
+	}
+
+	activity := file.Activities[0]
+	track := &Track{Sport: normalizeSport(activity.Sport)}
+
+	var totalSeconds, totalMeters float64
+	hasSeconds, hasMeters := false, false
+	for _, lap := range activity.Laps {
+		if lap.TotalTimeSeconds != nil {
+			totalSeconds += *lap.TotalTimeSeconds
+			hasSeconds = true
+		}
+		if lap.DistanceMeters != nil {
+			totalMeters += *lap.DistanceMeters
+			hasMeters = true
+		}
+
+		for _, trk := range lap.Tracks {
+			segment := []Point{}
+			for _, p := range trk.Points {
+				point := Point{
+					Elevation: p.Altitude,
+					Time:      p.Time,
+					HeartRate: p.HeartRate,
+					Cadence:   p.Cadence,
+				}
+				if p.RunCadence != nil {
+					cadence := *p.RunCadence * 2
+					point.Cadence = &cadence
+				}
+				if p.Lat != nil && p.Lon != nil {
+					if *p.Lat < -90 || *p.Lat > 90 || *p.Lon < -180 || *p.Lon > 180 {
+						return nil, fmt.Errorf("invalid TCX file: coordinates %v,%v out of range", *p.Lat, *p.Lon)
+					}
+					point.Lat, point.Lon, point.HasPosition = *p.Lat, *p.Lon, true
+				}
+				segment = append(segment, point)
+			}
+			if len(segment) > 0 {
+				track.Segments = append(track.Segments, segment)
+			}
+		}
+	}
+
+	if hasSeconds {
+		track.TotalSeconds = &totalSeconds
+	}
+	if hasMeters {
+		track.TotalMeters = &totalMeters
+	}
+
+	if track.pointCount() == 0 && !hasMeters {
+		return nil, ErrNoPoints
+	}
+	return track, nil
+}
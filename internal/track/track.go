@@ -0,0 +1,231 @@
+// Package track reads recorded GPS tracks from GPX and TCX files and reduces
+// them to the figures a cardio entry needs and a simplified line to draw.
+package track
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	SportRunning = "running"
+	SportCycling = "cycling"
+	SportRowing  = "rowing"
+	SportWalking = "walking"
+	SportHiking  = "hiking"
+)
+
+var ErrNoPoints = errors.New("track has no points")
+
+// Heart rates outside this range are sensor glitches, e.g. a strap that lost
+// contact, and are left out of the summary.
+const (
+	MinHeartRate = 20
+	MaxHeartRate = 250
+)
+
+// ValidHeartRate reports whether bpm is a heart rate a sensor could really
+// have measured.
+func ValidHeartRate(bpm int) bool {
+	return bpm >= MinHeartRate && bpm <= MaxHeartRate
+}
+
+// Point is one recorded sample. Indoor recordings can carry samples without
+// a position; those only feed the heart-rate and cadence figures.
+type Point struct {
+	Lat         float64
+	Lon         float64
+	HasPosition bool
+	Elevation   *float64
+	Time        time.Time
+	HeartRate   *int
+	Cadence     *int
+}
+
+// Track is a parsed recording. Points are split into segments wherever the
+// device paused or started a new lap, and no distance is counted between
+// segments. TotalSeconds and TotalMeters hold totals reported by the device
+// itself, which are preferred over values computed from the points.
+type Track struct {
+	Name         string
+	Sport        string
+	Segments     [][]Point
+	TotalSeconds *float64
+	TotalMeters  *float64
+}
+
+// Summary holds the figures of a track. StartedAt is zero when the track has
+// no timestamps.
+type Summary struct {
+	StartedAt           time.Time
+	DurationSeconds     int
+	DistanceMeters      float64
+	ElevationGainMeters *float64
+	AvgHeartRate        *int
+	MaxHeartRate        *int
+	AvgCadence          *int
+}
+
+// elevationThreshold is how far the track has to climb above the last low
+// point before the climb counts; it keeps GPS altitude noise out of the gain.
+const elevationThreshold = 3.0
+
+// Summarize computes the summary of the track.
+func (t *Track) Summarize() Summary {
+	var summary Summary
+	var first, last time.Time
+	var heartRateSum, heartRateCount, cadenceSum, cadenceCount int
+	var gain float64
+	var anchor *float64
+	hasElevation := false
+
+	for _, segment := range t.Segments {
+		var previous *Point
+		for i := range segment {
+			point := &segment[i]
+
+			if !point.Time.IsZero() {
+				if first.IsZero() || point.Time.Before(first) {
+					first = point.Time
+				}
+				if point.Time.After(last) {
+					last = point.Time
+				}
+			}
+
+			if point.HasPosition {
+				if previous != nil {
+					summary.DistanceMeters += Distance(*previous, *point)
+				}
+				previous = point
+			}
+
+			if point.Elevation != nil {
+				hasElevation = true
+				elevation := *point.Elevation
+				switch {
+				case anchor == nil || elevation < *anchor:
+					anchor = &elevation
+				case elevation-*anchor >= elevationThreshold:
+					gain += elevation - *anchor
+					anchor = &elevation
+				}
+			}
+
+			if point.HeartRate != nil && ValidHeartRate(*point.HeartRate) {
+				heartRateSum += *point.HeartRate
+				heartRateCount++
+				if summary.MaxHeartRate == nil || *point.HeartRate > *summary.MaxHeartRate {
+					heartRate := *point.HeartRate
+					summary.MaxHeartRate = &heartRate
+				}
+			}
+
+			if point.Cadence != nil && *point.Cadence > 0 {
+				cadenceSum += *point.Cadence
+				cadenceCount++
+			}
+		}
+	}
+
+	summary.StartedAt = first
+	if !first.IsZero() {
+		summary.DurationSeconds = int(last.Sub(first).Seconds())
+	}
+	if t.TotalSeconds != nil {
+		summary.DurationSeconds = int(math.Round(*t.TotalSeconds))
+	}
+	if t.TotalMeters != nil {
+		summary.DistanceMeters = *t.TotalMeters
+	}
+
+	if hasElevation {
+		summary.ElevationGainMeters = &gain
+	}
+	if heartRateCount > 0 {
+		average := int(math.Round(float64(heartRateSum) / float64(heartRateCount)))
+		summary.AvgHeartRate = &average
+	}
+	if cadenceCount > 0 {
+		average := int(math.Round(float64(cadenceSum) / float64(cadenceCount)))
+		summary.AvgCadence = &average
+	}
+	return summary
+}
+
+// Simplified returns the positioned points of the track simplified segment
+// by segment with Simplify, in order.
+func (t *Track) Simplified(toleranceMeters float64) []Point {
+	simplified := []Point{}
+	for _, segment := range t.Segments {
+		positioned := []Point{}
+		for _, point := range segment {
+			if point.HasPosition {
+				positioned = append(positioned, point)
+			}
+		}
+		simplified = append(simplified, Simplify(positioned, toleranceMeters)...)
+	}
+	return simplified
+}
+
+// PositionCount is the number of positioned points across all segments.
+func (t *Track) PositionCount() int {
+	count := 0
+	for _, segment := range t.Segments {
+		for _, point := range segment {
+			if point.HasPosition {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func (t *Track) pointCount() int {
+	count := 0
+	for _, segment := range t.Segments {
+		count += len(segment)
+	}
+	return count
+}
+
+const earthRadiusMeters = 6371008.8
+
+// Distance is the great-circle distance between two points in meters.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := radians(b.Lon - a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// normalizeSport maps the activity names used by devices and apps onto the
+// Sport constants, or returns "" when it isn't recognized.
+func normalizeSport(sport string) string {
+	sport = strings.ToLower(strings.TrimSpace(sport))
+	switch {
+	case sport == "":
+		return ""
+	case strings.Contains(sport, "run"):
+		return SportRunning
+	case strings.Contains(sport, "bik"), strings.Contains(sport, "cycl"), strings.Contains(sport, "ride"):
+		return SportCycling
+	case strings.Contains(sport, "row"):
+		return SportRowing
+	case strings.Contains(sport, "walk"):
+		return SportWalking
+	case strings.Contains(sport, "hik"):
+		return SportHiking
+	}
+	return ""
+}
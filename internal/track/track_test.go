@@ -0,0 +1,177 @@
+package track
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+	xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+	<metadata><name>Morning Run</name></metadata>
+	<trk>
+		<type>running</type>
+		<trkseg>
+			<trkpt lat="52.0000" lon="13.0000"><ele>30</ele><time>2026-03-01T07:00:00Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>120</gpxtpx:hr><gpxtpx:cad>84</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions>
+			</trkpt>
+			<trkpt lat="52.0010" lon="13.0000"><ele>31</ele><time>2026-03-01T07:00:30Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>140</gpxtpx:hr><gpxtpx:cad>86</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions>
+			</trkpt>
+			<trkpt lat="52.0020" lon="13.0000"><ele>36</ele><time>2026-03-01T07:01:00Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>160</gpxtpx:hr><gpxtpx:cad>88</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions>
+			</trkpt>
+		</trkseg>
+		<trkseg>
+			<trkpt lat="52.1000" lon="13.0000"><ele>32</ele><time>2026-03-01T07:05:00Z</time></trkpt>
+			<trkpt lat="52.1010" lon="13.0000"><ele>33</ele><time>2026-03-01T07:05:30Z</time></trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+const sampleTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+	xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+	<Activities>
+		<Activity Sport="Biking">
+			<Id>2026-03-02T17:00:00Z</Id>
+			<Lap StartTime="2026-03-02T17:00:00Z">
+				<TotalTimeSeconds>600</TotalTimeSeconds>
+				<DistanceMeters>5000</DistanceMeters>
+				<Track>
+					<Trackpoint>
+						<Time>2026-03-02T17:00:00Z</Time>
+						<Position><LatitudeDegrees>48.0</LatitudeDegrees><LongitudeDegrees>11.0</LongitudeDegrees></Position>
+						<AltitudeMeters>500</AltitudeMeters>
+						<HeartRateBpm><Value>110</Value></HeartRateBpm>
+						<Cadence>80</Cadence>
+					</Trackpoint>
+					<Trackpoint>
+						<Time>2026-03-02T17:05:00Z</Time>
+						<HeartRateBpm><Value>150</Value></HeartRateBpm>
+						<Cadence>90</Cadence>
+					</Trackpoint>
+				</Track>
+			</Lap>
+			<Lap StartTime="2026-03-02T17:10:00Z">
+				<TotalTimeSeconds>300.4</TotalTimeSeconds>
+				<DistanceMeters>2500</DistanceMeters>
+				<Track>
+					<Trackpoint>
+						<Time>2026-03-02T17:15:00Z</Time>
+						<Position><LatitudeDegrees>48.02</LatitudeDegrees><LongitudeDegrees>11.0</LongitudeDegrees></Position>
+						<AltitudeMeters>510</AltitudeMeters>
+						<HeartRateBpm><Value>160</Value></HeartRateBpm>
+					</Trackpoint>
+				</Track>
+			</Lap>
+		</Activity>
+	</Activities>
+</TrainingCenterDatabase>`
+
+func TestParseGPX(t *testing.T) {
+	track, err := ParseGPX(strings.NewReader(sampleGPX))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Morning Run", track.Name)
+	assert.Equal(t, SportRunning, track.Sport)
+	require.Len(t, track.Segments, 2)
+
+	summary := track.Summarize()
+	assert.Equal(t, time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC), summary.StartedAt.UTC())
+	assert.Equal(t, 330, summary.DurationSeconds)
+	// three 0.001° steps of latitude, none across the segment gap
+	assert.InDelta(t, 333.6, summary.DistanceMeters, 0.5)
+	require.NotNil(t, summary.ElevationGainMeters)
+	assert.InDelta(t, 6, *summary.ElevationGainMeters, 0.001)
+	assert.Equal(t, 140, *summary.AvgHeartRate)
+	assert.Equal(t, 160, *summary.MaxHeartRate)
+	assert.Equal(t, 86, *summary.AvgCadence)
+}
+
+func TestParseTCX(t *testing.T) {
+	track, err := ParseTCX(strings.NewReader(sampleTCX))
+	require.NoError(t, err)
+
+	assert.Equal(t, SportCycling, track.Sport)
+	require.Len(t, track.Segments, 2)
+
+	summary := track.Summarize()
+	assert.Equal(t, 900, summary.DurationSeconds)
+	assert.InDelta(t, 7500, summary.DistanceMeters, 0.001)
+	assert.InDelta(t, 10, *summary.ElevationGainMeters, 0.001)
+	assert.Equal(t, 140, *summary.AvgHeartRate)
+	assert.Equal(t, 160, *summary.MaxHeartRate)
+	assert.Equal(t, 85, *summary.AvgCadence)
+
+	// the sample without a position is left out of the line
+	assert.Len(t, track.Simplified(5), 2)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (*Track, error)
+		input string
+	}{
+		{"malformed GPX", func(s string) (*Track, error) { return ParseGPX(strings.NewReader(s)) }, "<gpx><trk>"},
+		{"empty GPX", func(s string) (*Track, error) { return ParseGPX(strings.NewReader(s)) }, `<gpx version="1.1"></gpx>`},
+		{"TCX given as GPX", func(s string) (*Track, error) { return ParseGPX(strings.NewReader(s)) }, sampleTCX},
+		{"GPX given as TCX", func(s string) (*Track, error) { return ParseTCX(strings.NewReader(s)) }, sampleGPX},
+		{"out of range", func(s string) (*Track, error) { return ParseGPX(strings.NewReader(s)) }, `<gpx><trk><trkseg><trkpt lat="95" lon="0"/></trkseg></trk></gpx>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse(tt.input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// a line north with a 48 m detour east in the middle; the points
+	// between lie within a meter of the detour
+	points := []Point{
+		{Lat: 52.000, Lon: 13.000},
+		{Lat: 52.001, Lon: 13.00036},
+		{Lat: 52.002, Lon: 13.0007},
+		{Lat: 52.003, Lon: 13.00034},
+		{Lat: 52.004, Lon: 13.000},
+	}
+
+	simplified := Simplify(points, 5)
+	assert.Equal(t, []Point{points[0], points[2], points[4]}, simplified)
+
+	assert.Equal(t, []Point{points[0], points[4]}, Simplify(points, 100))
+	assert.Equal(t, points[:2], Simplify(points[:2], 5))
+}
+
+func TestEncodePolyline(t *testing.T) {
+	// the example from the format's documentation
+	points := []Point{
+		{Lat: 38.5, Lon: -120.2},
+		{Lat: 40.7, Lon: -120.95},
+		{Lat: 43.252, Lon: -126.453},
+	}
+	assert.Equal(t, "_p~iF~ps|U_ulLnnqC_mqNvxq`@", EncodePolyline(points))
+	assert.Equal(t, "", EncodePolyline(nil))
+}
+
+func TestSummarizeDropsImplausibleHeartRates(t *testing.T) {
+	heartRate := func(bpm int) *int { return &bpm }
+	recording := &Track{Segments: [][]Point{{
+		{Time: time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC), HeartRate: heartRate(130)},
+		{Time: time.Date(2026, 3, 1, 7, 0, 10, 0, time.UTC), HeartRate: heartRate(255)},
+		{Time: time.Date(2026, 3, 1, 7, 0, 20, 0, time.UTC), HeartRate: heartRate(12)},
+		{Time: time.Date(2026, 3, 1, 7, 0, 30, 0, time.UTC), HeartRate: heartRate(150)},
+	}}}
+
+	summary := recording.Summarize()
+	assert.Equal(t, 140, *summary.AvgHeartRate)
+	assert.Equal(t, 150, *summary.MaxHeartRate)
+}
@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/ruhan/internal/app"
 	"github.com/ruhan/internal/routes"
@@ -12,16 +14,28 @@ import (
 
 func main() {
 	var port int
+	var cfg app.Config
 	flag.IntVar(&port, "port", 8001, "this backend server port")
+	flag.StringVar(&cfg.Mailer, "mailer", "smtp", "how to deliver email: smtp, or log or file for local development")
+	flag.StringVar(&cfg.MailDir, "mail-dir", "mail", "directory the file mailer writes emails to")
+	flag.StringVar(&cfg.SMTPHost, "smtp-host", "localhost", "SMTP server host")
+	flag.IntVar(&cfg.SMTPPort, "smtp-port", 25, "SMTP server port")
+	flag.StringVar(&cfg.SMTPUsername, "smtp-username", "", "SMTP username, authenticated with the SMTP_PASSWORD environment variable; leave empty to send without authenticating")
+	flag.StringVar(&cfg.MailSender, "mail-sender", "Workouts <no-reply@localhost>", "From address of emails")
 	flag.Parse()
 
-	app, err := app.NewApplication()
+	// a flag would show the password in ps and the shell history
+	cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+
+	app, err := app.NewApplication(cfg)
 	if err != nil {
 		panic(err)
 	}
 
 	defer app.DB.Close()
 
+	go app.RunMaintenance(time.Hour)
+
 	routesHandler := routes.SetupRoutes(app)
 
 	server := &http.Server{
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts
+ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+) STORED;
+
+ALTER TABLE workout_entries
+ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(exercise_name, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(notes, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_search_vector ON workouts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_workout_entries_search_vector ON workout_entries USING GIN (search_vector);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workout_entries_search_vector;
+DROP INDEX IF EXISTS idx_workouts_search_vector;
+
+ALTER TABLE workout_entries
+DROP COLUMN search_vector;
+
+ALTER TABLE workouts
+DROP COLUMN search_vector;
+
+-- +goose StatementEnd
@@ -0,0 +1,145 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS exercises (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    primary_muscles TEXT[] NOT NULL DEFAULT '{}',
+    secondary_muscles TEXT[] NOT NULL DEFAULT '{}',
+    equipment VARCHAR(50),
+    movement_type VARCHAR(50),
+    created_by BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_name ON exercises (lower(name));
+
+CREATE TABLE IF NOT EXISTS exercise_aliases (
+    id BIGSERIAL PRIMARY KEY,
+    exercise_id BIGINT NOT NULL REFERENCES exercises (id) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercise_aliases_alias ON exercise_aliases (lower(alias));
+
+-- resolve_exercise_id maps a free-text exercise name to a catalog entry by
+-- canonical name or alias, ignoring case and surrounding whitespace.
+CREATE OR REPLACE FUNCTION resolve_exercise_id(exercise_name TEXT) RETURNS BIGINT AS $$
+    SELECT id FROM (
+        SELECT id FROM exercises WHERE lower(name) = lower(trim(exercise_name))
+        UNION ALL
+        SELECT exercise_id FROM exercise_aliases WHERE lower(alias) = lower(trim(exercise_name))
+    ) matches
+    LIMIT 1
+$$ LANGUAGE SQL STABLE;
+
+INSERT INTO exercises (name, primary_muscles, secondary_muscles, equipment, movement_type) VALUES
+    ('Bench Press', '{chest}', '{triceps,shoulders}', 'barbell', 'push'),
+    ('Incline Bench Press', '{chest}', '{triceps,shoulders}', 'barbell', 'push'),
+    ('Dumbbell Bench Press', '{chest}', '{triceps,shoulders}', 'dumbbell', 'push'),
+    ('Overhead Press', '{shoulders}', '{triceps}', 'barbell', 'push'),
+    ('Push Up', '{chest}', '{triceps,shoulders}', 'bodyweight', 'push'),
+    ('Dip', '{chest,triceps}', '{shoulders}', 'bodyweight', 'push'),
+    ('Squat', '{quads,glutes}', '{hamstrings,core}', 'barbell', 'squat'),
+    ('Front Squat', '{quads}', '{glutes,core}', 'barbell', 'squat'),
+    ('Goblet Squat', '{quads,glutes}', '{core}', 'dumbbell', 'squat'),
+    ('Leg Press', '{quads,glutes}', '{hamstrings}', 'machine', 'squat'),
+    ('Bulgarian Split Squat', '{quads,glutes}', '{hamstrings}', 'dumbbell', 'lunge'),
+    ('Lunge', '{quads,glutes}', '{hamstrings}', 'bodyweight', 'lunge'),
+    ('Deadlift', '{hamstrings,glutes,back}', '{forearms,core}', 'barbell', 'hinge'),
+    ('Romanian Deadlift', '{hamstrings,glutes}', '{back}', 'barbell', 'hinge'),
+    ('Hip Thrust', '{glutes}', '{hamstrings}', 'barbell', 'hinge'),
+    ('Kettlebell Swing', '{glutes,hamstrings}', '{core,shoulders}', 'kettlebell', 'hinge'),
+    ('Pull Up', '{back}', '{biceps}', 'bodyweight', 'pull'),
+    ('Chin Up', '{back,biceps}', '{}', 'bodyweight', 'pull'),
+    ('Barbell Row', '{back}', '{biceps}', 'barbell', 'pull'),
+    ('Lat Pulldown', '{back}', '{biceps}', 'cable', 'pull'),
+    ('Bicep Curl', '{biceps}', '{forearms}', 'dumbbell', 'pull'),
+    ('Tricep Extension', '{triceps}', '{}', 'cable', 'push'),
+    ('Lateral Raise', '{shoulders}', '{}', 'dumbbell', 'push'),
+    ('Calf Raise', '{calves}', '{}', 'machine', 'push'),
+    ('Plank', '{core}', '{shoulders}', 'bodyweight', 'isometric'),
+    ('Running', '{quads,hamstrings,calves}', '{glutes}', 'none', 'cardio'),
+    ('Cycling', '{quads}', '{hamstrings,calves}', 'bike', 'cardio'),
+    ('Rowing', '{back,quads}', '{biceps,core}', 'machine', 'cardio')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO exercise_aliases (exercise_id, alias)
+SELECT x.id, a.alias
+FROM (VALUES
+    ('Bench Press', 'BB Bench'),
+    ('Bench Press', 'Barbell Bench Press'),
+    ('Bench Press', 'Flat Bench'),
+    ('Dumbbell Bench Press', 'DB Bench'),
+    ('Overhead Press', 'OHP'),
+    ('Overhead Press', 'Military Press'),
+    ('Overhead Press', 'Shoulder Press'),
+    ('Push Up', 'Pushup'),
+    ('Push Up', 'Push-up'),
+    ('Squat', 'Back Squat'),
+    ('Squat', 'Barbell Squat'),
+    ('Squat', 'Squats'),
+    ('Bulgarian Split Squat', 'BSS'),
+    ('Bulgarian Split Squat', 'Split Squat'),
+    ('Deadlift', 'Conventional Deadlift'),
+    ('Romanian Deadlift', 'RDL'),
+    ('Kettlebell Swing', 'KB Swing'),
+    ('Pull Up', 'Pullup'),
+    ('Pull Up', 'Pull-up'),
+    ('Chin Up', 'Chinup'),
+    ('Barbell Row', 'Bent Over Row'),
+    ('Bicep Curl', 'Curl'),
+    ('Bicep Curl', 'Biceps Curl'),
+    ('Running', 'Run'),
+    ('Cycling', 'Bike'),
+    ('Rowing', 'Row Erg')
+) AS a (name, alias)
+INNER JOIN exercises x ON x.name = a.name
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE workout_entries
+ADD COLUMN exercise_id BIGINT REFERENCES exercises (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_workout_entries_exercise_id ON workout_entries (exercise_id);
+
+-- back-fill: exact name/alias matches first, then the closest trigram match
+-- above a conservative threshold. Whatever is left stays unlinked, as new
+-- entries with unknown names do, rather than turning a user's free text
+-- into a public catalog entry.
+UPDATE workout_entries
+SET exercise_id = resolve_exercise_id(exercise_name)
+WHERE exercise_id IS NULL;
+
+UPDATE workout_entries e
+SET exercise_id = m.exercise_id
+FROM (
+    SELECT DISTINCT ON (e2.id) e2.id AS entry_id, c.exercise_id
+    FROM workout_entries e2
+    CROSS JOIN (
+        SELECT id AS exercise_id, name AS candidate FROM exercises
+        UNION ALL
+        SELECT exercise_id, alias FROM exercise_aliases
+    ) c
+    WHERE e2.exercise_id IS NULL
+        AND similarity(lower(e2.exercise_name), lower(c.candidate)) >= 0.6
+    ORDER BY e2.id, similarity(lower(e2.exercise_name), lower(c.candidate)) DESC
+) m
+WHERE e.id = m.entry_id;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+DROP COLUMN exercise_id;
+
+DROP FUNCTION IF EXISTS resolve_exercise_id(TEXT);
+DROP TABLE exercise_aliases;
+DROP TABLE exercises;
+
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS personal_records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    exercise_id BIGINT NOT NULL REFERENCES exercises (id) ON DELETE CASCADE,
+    workout_id BIGINT NOT NULL REFERENCES workouts (id) ON DELETE CASCADE,
+    workout_entry_id BIGINT NOT NULL REFERENCES workout_entries (id) ON DELETE CASCADE,
+    record_type VARCHAR(30) NOT NULL,
+    value DECIMAL(10, 2) NOT NULL,
+    weight DECIMAL(5, 2),
+    previous_value DECIMAL(10, 2),
+    achieved_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL,
+        created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_records_user_exercise ON personal_records (user_id, exercise_id, record_type);
+CREATE INDEX IF NOT EXISTS idx_personal_records_workout ON personal_records (workout_id);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE personal_records;
+
+-- +goose StatementEnd
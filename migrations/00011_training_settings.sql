@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN rest_days TEXT[] NOT NULL DEFAULT '{}',
+ADD COLUMN weekly_workout_target INTEGER NOT NULL DEFAULT 3 CHECK (weekly_workout_target BETWEEN 1 AND 7);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN weekly_workout_target,
+DROP COLUMN rest_days;
+
+-- +goose StatementEnd
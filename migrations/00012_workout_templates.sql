@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_templates (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    duration_minutes INTEGER,
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_templates_user_id ON workout_templates (user_id);
+
+CREATE TABLE IF NOT EXISTS workout_template_entries (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT NOT NULL REFERENCES workout_templates (id) ON DELETE CASCADE,
+    exercise_id BIGINT REFERENCES exercises (id) ON DELETE SET NULL,
+    exercise_name VARCHAR(255) NOT NULL,
+    notes TEXT,
+    sets INTEGER NOT NULL,
+    reps INTEGER,
+    duration_seconds INTEGER,
+    weight DECIMAL(5, 2),
+    order_index INTEGER NOT NULL,
+    CONSTRAINT valid_template_entry CHECK (
+        (
+            reps IS NOT NULL
+            OR duration_seconds IS NOT NULL
+        )
+        AND (
+            reps IS NULL
+            or duration_seconds IS NULL
+        )
+    )
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_template_entries;
+DROP TABLE workout_templates;
+
+-- +goose StatementEnd
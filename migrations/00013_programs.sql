@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS programs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    weeks INTEGER NOT NULL CHECK (weeks BETWEEN 1 AND 52),
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS program_slots (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs (id) ON DELETE CASCADE,
+    week INTEGER NOT NULL,
+    day INTEGER NOT NULL CHECK (day BETWEEN 1 AND 7),
+    template_id BIGINT NOT NULL REFERENCES workout_templates (id) ON DELETE CASCADE,
+    progression JSONB NOT NULL DEFAULT '[]',
+    UNIQUE (program_id, week, day)
+);
+
+CREATE TABLE IF NOT EXISTS program_enrollments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    program_id BIGINT NOT NULL REFERENCES programs (id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_program_enrollments_user_id ON program_enrollments (user_id);
+
+CREATE TABLE IF NOT EXISTS program_slot_completions (
+    id BIGSERIAL PRIMARY KEY,
+    enrollment_id BIGINT NOT NULL REFERENCES program_enrollments (id) ON DELETE CASCADE,
+    slot_id BIGINT NOT NULL REFERENCES program_slots (id) ON DELETE CASCADE,
+    workout_id BIGINT NOT NULL REFERENCES workouts (id) ON DELETE CASCADE,
+    completed_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (enrollment_id, slot_id)
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE program_slot_completions;
+DROP TABLE program_enrollments;
+DROP TABLE program_slots;
+DROP TABLE programs;
+
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS planned_workouts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    template_id BIGINT REFERENCES workout_templates (id) ON DELETE SET NULL,
+    scheduled_date DATE NOT NULL,
+    rrule TEXT,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_planned_workouts_user_id ON planned_workouts (user_id, scheduled_date);
+
+CREATE TABLE IF NOT EXISTS planned_workout_completions (
+    id BIGSERIAL PRIMARY KEY,
+    planned_workout_id BIGINT NOT NULL REFERENCES planned_workouts (id) ON DELETE CASCADE,
+    occurrence_date DATE NOT NULL,
+    workout_id BIGINT NOT NULL REFERENCES workouts (id) ON DELETE CASCADE,
+    completed_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        CONSTRAINT planned_workout_completions_occurrence_key UNIQUE (planned_workout_id, occurrence_date),
+        CONSTRAINT planned_workout_completions_workout_key UNIQUE (workout_id)
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE planned_workout_completions;
+DROP TABLE planned_workouts;
+
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_entry_groups (
+    id BIGSERIAL PRIMARY KEY,
+    workout_id BIGINT NOT NULL REFERENCES workouts (id) ON DELETE CASCADE,
+    group_index INTEGER NOT NULL,
+    group_type TEXT NOT NULL CHECK (group_type IN ('straight', 'superset', 'circuit', 'emom', 'amrap')),
+    rounds INTEGER CHECK (rounds >= 1),
+    rest_seconds INTEGER CHECK (rest_seconds >= 0),
+    round_rest_seconds INTEGER CHECK (round_rest_seconds >= 0),
+    interval_seconds INTEGER CHECK (interval_seconds >= 1),
+    time_cap_seconds INTEGER CHECK (time_cap_seconds >= 1),
+    notes TEXT,
+    UNIQUE (workout_id, group_index)
+);
+
+ALTER TABLE workout_entries
+ADD COLUMN group_index INTEGER,
+ADD CONSTRAINT workout_entries_group_fkey FOREIGN KEY (workout_id, group_index) REFERENCES workout_entry_groups (workout_id, group_index);
+
+CREATE TABLE IF NOT EXISTS workout_template_entry_groups (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT NOT NULL REFERENCES workout_templates (id) ON DELETE CASCADE,
+    group_index INTEGER NOT NULL,
+    group_type TEXT NOT NULL CHECK (group_type IN ('straight', 'superset', 'circuit', 'emom', 'amrap')),
+    rounds INTEGER CHECK (rounds >= 1),
+    rest_seconds INTEGER CHECK (rest_seconds >= 0),
+    round_rest_seconds INTEGER CHECK (round_rest_seconds >= 0),
+    interval_seconds INTEGER CHECK (interval_seconds >= 1),
+    time_cap_seconds INTEGER CHECK (time_cap_seconds >= 1),
+    notes TEXT,
+    UNIQUE (template_id, group_index)
+);
+
+ALTER TABLE workout_template_entries
+ADD COLUMN group_index INTEGER,
+ADD CONSTRAINT workout_template_entries_group_fkey FOREIGN KEY (template_id, group_index) REFERENCES workout_template_entry_groups (template_id, group_index);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_template_entries DROP COLUMN group_index;
+DROP TABLE workout_template_entry_groups;
+ALTER TABLE workout_entries DROP COLUMN group_index;
+DROP TABLE workout_entry_groups;
+
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_sets (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES workout_entries (id) ON DELETE CASCADE,
+    set_index INTEGER NOT NULL,
+    set_type TEXT NOT NULL DEFAULT 'working' CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+    reps INTEGER CHECK (reps >= 0),
+    weight DECIMAL(6, 2) CHECK (weight >= 0),
+    duration_seconds INTEGER CHECK (duration_seconds >= 0),
+    rpe DECIMAL(3, 1) CHECK (rpe BETWEEN 1 AND 10),
+    rir INTEGER CHECK (rir >= 0),
+    completed BOOLEAN NOT NULL DEFAULT TRUE,
+    UNIQUE (entry_id, set_index),
+    CONSTRAINT valid_workout_set CHECK (
+        (
+            reps IS NOT NULL
+            OR duration_seconds IS NOT NULL
+        )
+        AND (
+            reps IS NULL
+            or duration_seconds IS NULL
+        )
+    )
+);
+
+-- entries logged before per-set logging become identical working sets
+INSERT INTO workout_sets (entry_id, set_index, reps, weight, duration_seconds)
+SELECT e.id, s.set_index, e.reps, e.weight, e.duration_seconds
+FROM workout_entries e
+CROSS JOIN LATERAL generate_series(1, e.sets) AS s (set_index);
+
+-- volume of the completed, non warm-up sets of an entry
+CREATE OR REPLACE FUNCTION workout_entry_volume(p_entry_id BIGINT) RETURNS NUMERIC AS $$
+    SELECT COALESCE(SUM(s.reps * s.weight), 0)
+    FROM workout_sets s
+    WHERE s.entry_id = p_entry_id AND s.completed AND s.set_type <> 'warmup'
+$$ LANGUAGE SQL STABLE;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS workout_entry_volume(BIGINT);
+DROP TABLE workout_sets;
+
+-- +goose StatementEnd
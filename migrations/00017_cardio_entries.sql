@@ -0,0 +1,80 @@
+-- +goose Up
+-- +goose StatementBegin
+-- strength entries keep their reps XOR duration shape; cardio entries
+-- record a duration and/or a distance instead of reps
+ALTER TABLE workout_entries
+ADD COLUMN kind TEXT NOT NULL DEFAULT 'strength' CHECK (kind IN ('strength', 'cardio')),
+ADD COLUMN distance_meters DECIMAL(10, 2) CHECK (distance_meters >= 0),
+ADD COLUMN distance_unit TEXT CHECK (distance_unit IN ('m', 'km', 'mi')),
+ADD COLUMN elevation_gain_meters DECIMAL(7, 1) CHECK (elevation_gain_meters >= 0),
+ADD COLUMN avg_heart_rate INTEGER CHECK (avg_heart_rate BETWEEN 20 AND 250),
+ADD COLUMN max_heart_rate INTEGER CHECK (max_heart_rate BETWEEN 20 AND 250),
+ADD COLUMN avg_cadence INTEGER CHECK (avg_cadence >= 0),
+DROP CONSTRAINT valid_workout_entry,
+ADD CONSTRAINT valid_workout_entry CHECK (
+    (
+        kind = 'strength'
+        AND (reps IS NULL) <> (duration_seconds IS NULL)
+        AND distance_meters IS NULL
+    )
+    OR (
+        kind = 'cardio'
+        AND reps IS NULL
+        AND (duration_seconds IS NOT NULL OR distance_meters IS NOT NULL)
+    )
+),
+ADD CONSTRAINT valid_workout_entry_distance CHECK ((distance_meters IS NULL) = (distance_unit IS NULL));
+
+ALTER TABLE workout_template_entries
+ADD COLUMN kind TEXT NOT NULL DEFAULT 'strength' CHECK (kind IN ('strength', 'cardio')),
+ADD COLUMN distance_meters DECIMAL(10, 2) CHECK (distance_meters >= 0),
+ADD COLUMN distance_unit TEXT CHECK (distance_unit IN ('m', 'km', 'mi')),
+ADD COLUMN elevation_gain_meters DECIMAL(7, 1) CHECK (elevation_gain_meters >= 0),
+ADD COLUMN avg_heart_rate INTEGER CHECK (avg_heart_rate BETWEEN 20 AND 250),
+ADD COLUMN max_heart_rate INTEGER CHECK (max_heart_rate BETWEEN 20 AND 250),
+ADD COLUMN avg_cadence INTEGER CHECK (avg_cadence >= 0),
+DROP CONSTRAINT valid_template_entry,
+ADD CONSTRAINT valid_template_entry CHECK (
+    (
+        kind = 'strength'
+        AND (reps IS NULL) <> (duration_seconds IS NULL)
+        AND distance_meters IS NULL
+    )
+    OR (
+        kind = 'cardio'
+        AND reps IS NULL
+        AND (duration_seconds IS NOT NULL OR distance_meters IS NOT NULL)
+    )
+),
+ADD CONSTRAINT valid_template_entry_distance CHECK ((distance_meters IS NULL) = (distance_unit IS NULL));
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM workout_template_entries WHERE kind = 'cardio' AND duration_seconds IS NULL;
+ALTER TABLE workout_template_entries
+DROP CONSTRAINT valid_template_entry_distance,
+DROP CONSTRAINT valid_template_entry,
+DROP COLUMN avg_cadence,
+DROP COLUMN max_heart_rate,
+DROP COLUMN avg_heart_rate,
+DROP COLUMN elevation_gain_meters,
+DROP COLUMN distance_unit,
+DROP COLUMN distance_meters,
+DROP COLUMN kind,
+ADD CONSTRAINT valid_template_entry CHECK ((reps IS NULL) <> (duration_seconds IS NULL));
+
+DELETE FROM workout_entries WHERE kind = 'cardio' AND duration_seconds IS NULL;
+ALTER TABLE workout_entries
+DROP CONSTRAINT valid_workout_entry_distance,
+DROP CONSTRAINT valid_workout_entry,
+DROP COLUMN avg_cadence,
+DROP COLUMN max_heart_rate,
+DROP COLUMN avg_heart_rate,
+DROP COLUMN elevation_gain_meters,
+DROP COLUMN distance_unit,
+DROP COLUMN distance_meters,
+DROP COLUMN kind,
+ADD CONSTRAINT valid_workout_entry CHECK ((reps IS NULL) <> (duration_seconds IS NULL));
+
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the simplified line of an imported GPS recording, as a Google encoded
+-- polyline; one per workout
+CREATE TABLE IF NOT EXISTS workout_tracks (
+    workout_id BIGINT PRIMARY KEY REFERENCES workouts (id) ON DELETE CASCADE,
+    source_format TEXT NOT NULL CHECK (source_format IN ('gpx', 'tcx')),
+    polyline TEXT NOT NULL,
+    point_count INTEGER NOT NULL CHECK (point_count >= 0),
+    original_point_count INTEGER NOT NULL CHECK (original_point_count >= 0),
+    started_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_tracks;
+
+-- +goose StatementEnd
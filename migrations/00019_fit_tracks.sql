@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workout_tracks
+DROP CONSTRAINT workout_tracks_source_format_check,
+ADD CONSTRAINT workout_tracks_source_format_check CHECK (source_format IN ('gpx', 'tcx', 'fit'));
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM workout_tracks WHERE source_format = 'fit';
+ALTER TABLE workout_tracks
+DROP CONSTRAINT workout_tracks_source_format_check,
+ADD CONSTRAINT workout_tracks_source_format_check CHECK (source_format IN ('gpx', 'tcx'));
+
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- identifies workouts imported from another app so importing the same
+-- export again doesn't duplicate them
+ALTER TABLE workouts
+ADD COLUMN external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_user_external_id ON workouts (user_id, external_id)
+WHERE external_id IS NOT NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_external_id;
+ALTER TABLE workouts
+DROP COLUMN external_id;
+
+-- +goose StatementEnd
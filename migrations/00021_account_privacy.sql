@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- set while an account waits out the grace period before it is deleted
+ALTER TABLE users
+ADD COLUMN deletion_scheduled_for TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_for ON users (deletion_scheduled_for)
+WHERE deletion_scheduled_for IS NOT NULL;
+
+-- security-relevant account events, kept for the user's data archive;
+-- deleting an account keeps only an anonymous record of the deletion
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    event TEXT NOT NULL,
+    ip_address TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id, created_at);
+
+-- personal data archives, built in the background and kept until they
+-- expire; a user has at most one archive being built at a time
+CREATE TABLE IF NOT EXISTS export_archives (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    data BYTEA,
+    size_bytes BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_export_archives_pending ON export_archives (user_id)
+WHERE status = 'pending';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE export_archives;
+DROP TABLE audit_events;
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_for;
+ALTER TABLE users
+DROP COLUMN deletion_scheduled_for;
+
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- who can see a user's public profile; private profiles only show the
+-- username
+ALTER TABLE users
+ADD COLUMN profile_visibility TEXT NOT NULL DEFAULT 'private' CHECK (profile_visibility IN ('public', 'private'));
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN profile_visibility;
+
+-- +goose StatementEnd
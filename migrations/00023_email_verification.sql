@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- when the user confirmed they own their email address; unverified users
+-- can read but not write
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- accounts from before verification existed keep working
+UPDATE users
+SET email_verified_at = created_at;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN email_verified_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- tokens get an id so sessions can be listed and revoked without revealing
+-- their hash, and metadata to tell them apart
+ALTER TABLE tokens
+DROP CONSTRAINT tokens_pkey;
+
+ALTER TABLE tokens
+ADD COLUMN id BIGSERIAL PRIMARY KEY,
+ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX idx_tokens_hash ON tokens (hash);
+
+CREATE INDEX idx_tokens_user_scope ON tokens (user_id, scope);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_tokens_user_scope;
+
+DROP INDEX idx_tokens_hash;
+
+ALTER TABLE tokens
+DROP COLUMN id,
+DROP COLUMN created_at,
+DROP COLUMN last_used_at,
+DROP COLUMN user_agent,
+DROP COLUMN ip_address;
+
+ALTER TABLE tokens
+ADD PRIMARY KEY (hash);
+
+-- +goose StatementEnd
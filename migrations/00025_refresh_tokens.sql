@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a token family is one login: its first access and refresh token and
+-- every pair issued by refreshing them. A refresh token is marked used
+-- rather than deleted when it is exchanged, so a replay can be recognised.
+CREATE SEQUENCE token_families_seq;
+
+ALTER TABLE tokens
+ADD COLUMN family_id BIGINT NOT NULL DEFAULT nextval('token_families_seq'),
+ADD COLUMN used_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_tokens_family ON tokens (family_id);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_tokens_family;
+
+ALTER TABLE tokens
+DROP COLUMN family_id,
+DROP COLUMN used_at;
+
+DROP SEQUENCE token_families_seq;
+
+-- +goose StatementEnd
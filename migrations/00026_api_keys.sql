@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- API keys are tokens with the api_key scope, named by their owner and
+-- limited to a set of permissions such as workouts:read
+ALTER TABLE tokens
+ADD COLUMN name TEXT NOT NULL DEFAULT '',
+ADD COLUMN permissions TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM tokens
+WHERE scope = 'api_key';
+
+ALTER TABLE tokens
+DROP COLUMN name,
+DROP COLUMN permissions;
+
+-- +goose StatementEnd
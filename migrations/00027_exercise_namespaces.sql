@@ -0,0 +1,80 @@
+-- +goose Up
+-- +goose StatementBegin
+-- custom exercises live in their creator's namespace: names and aliases are
+-- unique among the seeded catalog and within each user's own entries, and a
+-- user's entries shadow catalog entries of the same name
+ALTER TABLE exercises
+DROP CONSTRAINT exercises_created_by_fkey,
+ADD CONSTRAINT exercises_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_exercises_name;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_owner_name ON exercises (COALESCE(created_by, 0), lower(name));
+
+ALTER TABLE exercise_aliases
+ADD COLUMN created_by BIGINT REFERENCES users (id) ON DELETE CASCADE;
+
+UPDATE exercise_aliases a
+SET created_by = x.created_by
+FROM exercises x
+WHERE x.id = a.exercise_id;
+
+DROP INDEX IF EXISTS idx_exercise_aliases_alias;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercise_aliases_owner_alias ON exercise_aliases (COALESCE(created_by, 0), lower(alias));
+
+DROP FUNCTION IF EXISTS resolve_exercise_id(TEXT);
+
+-- resolve_exercise_id maps a free-text exercise name to the catalog entry
+-- owner_id sees under that name or alias, preferring their own exercises
+-- over the seeded catalog.
+CREATE OR REPLACE FUNCTION resolve_exercise_id(exercise_name TEXT, owner_id BIGINT) RETURNS BIGINT AS $$
+    SELECT id FROM (
+        SELECT id, created_by FROM exercises
+        WHERE lower(name) = lower(trim(exercise_name)) AND (created_by IS NULL OR created_by = owner_id)
+        UNION ALL
+        SELECT exercise_id, created_by FROM exercise_aliases
+        WHERE lower(alias) = lower(trim(exercise_name)) AND (created_by IS NULL OR created_by = owner_id)
+    ) matches
+    ORDER BY created_by IS NULL
+    LIMIT 1
+$$ LANGUAGE SQL STABLE;
+
+-- visible_exercise_id returns exercise_id when owner_id may link to it,
+-- that is when it is a catalog entry or one of their own
+CREATE OR REPLACE FUNCTION visible_exercise_id(exercise_id BIGINT, owner_id BIGINT) RETURNS BIGINT AS $$
+    SELECT id FROM exercises
+    WHERE id = exercise_id AND (created_by IS NULL OR created_by = owner_id)
+$$ LANGUAGE SQL STABLE;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS visible_exercise_id(BIGINT, BIGINT);
+DROP FUNCTION IF EXISTS resolve_exercise_id(TEXT, BIGINT);
+
+CREATE OR REPLACE FUNCTION resolve_exercise_id(exercise_name TEXT) RETURNS BIGINT AS $$
+    SELECT id FROM (
+        SELECT id FROM exercises WHERE lower(name) = lower(trim(exercise_name))
+        UNION ALL
+        SELECT exercise_id FROM exercise_aliases WHERE lower(alias) = lower(trim(exercise_name))
+    ) matches
+    LIMIT 1
+$$ LANGUAGE SQL STABLE;
+
+DROP INDEX IF EXISTS idx_exercise_aliases_owner_alias;
+
+ALTER TABLE exercise_aliases
+DROP COLUMN created_by;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercise_aliases_alias ON exercise_aliases (lower(alias));
+
+DROP INDEX IF EXISTS idx_exercises_owner_name;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_name ON exercises (lower(name));
+
+ALTER TABLE exercises
+DROP CONSTRAINT exercises_created_by_fkey,
+ADD CONSTRAINT exercises_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL;
+
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- html_escape makes user text safe to embed in HTML, so search snippets can
+-- be highlighted with <mark> tags without passing through markup that the
+-- user typed into a title or note
+CREATE OR REPLACE FUNCTION html_escape(value TEXT) RETURNS TEXT AS $$
+    SELECT replace(replace(replace(replace(value, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS html_escape(TEXT);
+
+-- +goose StatementEnd
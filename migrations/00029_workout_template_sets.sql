@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- templates keep the set log they were saved with, so instantiating one
+-- prescribes the same warm-ups and working sets
+CREATE TABLE IF NOT EXISTS workout_template_sets (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES workout_template_entries (id) ON DELETE CASCADE,
+    set_index INTEGER NOT NULL,
+    set_type TEXT NOT NULL DEFAULT 'working' CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+    reps INTEGER CHECK (reps >= 0),
+    weight DECIMAL(6, 2) CHECK (weight >= 0),
+    duration_seconds INTEGER CHECK (duration_seconds >= 0),
+    rpe DECIMAL(3, 1) CHECK (rpe BETWEEN 1 AND 10),
+    rir INTEGER CHECK (rir >= 0),
+    completed BOOLEAN NOT NULL DEFAULT TRUE,
+    UNIQUE (entry_id, set_index),
+    CONSTRAINT valid_template_set CHECK (
+        (
+            reps IS NOT NULL
+            OR duration_seconds IS NOT NULL
+        )
+        AND (
+            reps IS NULL
+            or duration_seconds IS NULL
+        )
+    )
+);
+
+-- strength entries saved before template sets become identical working sets
+INSERT INTO workout_template_sets (entry_id, set_index, reps, weight, duration_seconds)
+SELECT e.id, s.set_index, e.reps, e.weight, e.duration_seconds
+FROM workout_template_entries e
+CROSS JOIN LATERAL generate_series(1, e.sets) AS s (set_index)
+WHERE e.kind = 'strength';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_template_sets;
+
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- nothing queries these: volume comes from workout_entry_volume and
+-- estimated 1RM records from package estimate, which the API shares
+DROP FUNCTION IF EXISTS entry_volume(INTEGER, INTEGER, NUMERIC);
+DROP FUNCTION IF EXISTS estimated_one_rep_max(NUMERIC, INTEGER, TEXT);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION estimated_one_rep_max(weight NUMERIC, reps INTEGER, formula TEXT DEFAULT 'epley') RETURNS NUMERIC AS $$
+    SELECT CASE
+        WHEN weight IS NULL OR reps IS NULL OR weight <= 0 OR reps <= 0 THEN NULL
+        WHEN reps = 1 THEN weight
+        WHEN formula = 'brzycki' THEN CASE WHEN reps >= 37 THEN NULL ELSE weight * 36 / (37 - reps) END
+        WHEN formula = 'lombardi' THEN weight * power(reps, 0.10)
+        WHEN formula = 'wathan' THEN 100 * weight / (48.8 + 53.8 * exp(-0.075 * reps))
+        ELSE weight * (1 + reps / 30.0)
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION entry_volume(sets INTEGER, reps INTEGER, weight NUMERIC) RETURNS NUMERIC AS $$
+    SELECT COALESCE(sets * reps * weight, 0)
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- +goose StatementEnd